@@ -1,27 +1,77 @@
 package main
 
 import (
+	"context"
 	"ecommerce-platform/internal/config"
+	"ecommerce-platform/internal/middleware"
+	"ecommerce-platform/internal/models"
+	"ecommerce-platform/internal/payment"
 	"ecommerce-platform/internal/routes"
+	"ecommerce-platform/internal/scheduler"
+	"ecommerce-platform/internal/storage"
+	"ecommerce-platform/internal/utils"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 func main() {
+	unpaidTimeout := flag.Duration("unpaid-timeout", scheduler.DefaultConfig.UnpaidTimeout, "下单后超过该时长未支付自动取消，0 为不自动取消")
+	autoReceiveDays := flag.Int("auto-receive-days", int(scheduler.DefaultConfig.AutoReceiveAfter/(24*time.Hour)), "发货后超过该天数自动确认收货，0 为不自动确认")
+	publicURL := flag.String("public-url", config.PublicURL, "平台对外访问的根地址，用于生成支付渠道异步通知地址")
+	mockPayment := flag.Bool("mock-payment", false, "启用模拟支付渠道，仅用于开发和测试，签名密钥取自环境变量 MOCK_PAYMENT_SECRET")
+	flag.Parse()
+	config.PublicURL = *publicURL
+
 	// 初始化数据库
 	config.InitDatabase()
 	defer config.CloseDatabase()
 
-	// 确保上传目录存在
-	os.MkdirAll("./uploads", 0755)
+	// 初始化文件存储，上传文件保存在 ./uploads 并通过 /uploads/ 访问
+	if err := storage.Init("./uploads", "/uploads/"); err != nil {
+		log.Fatal("文件存储初始化失败:", err)
+	}
+
+	// 注册支付渠道，接入真实渠道时在此注册对应的 payment.Provider 实现
+	if *mockPayment {
+		secret := os.Getenv("MOCK_PAYMENT_SECRET")
+		if secret == "" {
+			// 未配置时使用随机密钥，重启后之前发起的模拟支付无法再通知
+			secret = utils.GenerateToken()
+		}
+		payment.Register(payment.NewMockProvider(secret))
+		log.Println("⚠️  已启用模拟支付渠道，仅可用于开发和测试环境")
+	}
+	if len(payment.Providers()) == 0 {
+		log.Fatal("未配置支付渠道：生产环境请注册真实支付渠道，开发和测试环境可使用 -mock-payment 启用模拟支付")
+	}
 
 	// 初始化示例数据
 	initSampleData()
 
+	// 商品搜索索引与商品表不一致时重建
+	if err := models.SyncSearchIndex(); err != nil {
+		log.Println("重建商品搜索索引失败:", err)
+	}
+
+	// 启动订单定时任务
+	sched := scheduler.New(scheduler.Config{
+		Interval:         scheduler.DefaultConfig.Interval,
+		UnpaidTimeout:    *unpaidTimeout,
+		AutoReceiveAfter: time.Duration(*autoReceiveDays) * 24 * time.Hour,
+	})
+	sched.Start()
+
+	// 定期清理过期会话
+	stopJanitor := middleware.StartSessionJanitor(time.Hour)
+
 	// 设置路由
 	handler := routes.SetupRoutes()
 
@@ -34,7 +84,26 @@ func main() {
 	fmt.Printf("🛒 顾客账号: customer / customer123\n")
 	fmt.Println("----------------------------------------")
 
-	log.Fatal(http.ListenAndServe(port, handler))
+	server := &http.Server{Addr: port, Handler: handler}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	// 收到退出信号后停止接收新请求，等待定时任务和进行中的请求结束
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("正在关闭服务...")
+
+	sched.Stop()
+	stopJanitor()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Println("关闭服务失败:", err)
+	}
 }
 
 func initSampleData() {
@@ -147,4 +216,3 @@ func hashPassword(password string) (string, error) {
 }
 
 // 引入bcrypt包
-
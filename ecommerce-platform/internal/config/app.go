@@ -0,0 +1,12 @@
+package config
+
+import "strings"
+
+// PublicURL 平台对外访问的根地址，生成支付渠道异步通知等回调地址时使用，由启动参数 -public-url 设置。
+// 回调地址不能取自请求的 Host 头，否则客户端可以让渠道把通知发到任意地址
+var PublicURL = "http://localhost:8080"
+
+// CallbackURL 拼接对外访问的回调地址
+func CallbackURL(path string) string {
+	return strings.TrimRight(PublicURL, "/") + path
+}
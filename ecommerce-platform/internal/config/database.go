@@ -11,6 +11,9 @@ import (
 
 var DB *sql.DB
 
+// FTSEnabled SQLite 是否支持 FTS5 全文索引（需要以 -tags sqlite_fts5 编译），不支持时商品搜索使用 LIKE 查询
+var FTSEnabled bool
+
 func InitDatabase() {
 	// 确保数据目录存在
 	dataDir := "./data"
@@ -18,9 +21,14 @@ func InitDatabase() {
 		os.MkdirAll(dataDir, 0755)
 	}
 
-	dbPath := filepath.Join(dataDir, "ecommerce.db")
+	OpenDatabase(filepath.Join(dataDir, "ecommerce.db"))
+}
+
+// OpenDatabase 打开指定路径的数据库并建表。
+// 事务以 IMMEDIATE 方式开始，并发写入时排队等待写锁，而不是在提交时才因锁冲突失败
+func OpenDatabase(dbPath string) {
 	var err error
-	DB, err = sql.Open("sqlite3", dbPath+"?_foreign_keys=on")
+	DB, err = sql.Open("sqlite3", dbPath+"?_foreign_keys=on&_txlock=immediate&_busy_timeout=10000")
 	if err != nil {
 		log.Fatal("数据库连接失败:", err)
 	}
@@ -31,6 +39,7 @@ func InitDatabase() {
 
 	// 创建表
 	createTables()
+	migrateTables()
 }
 
 func createTables() {
@@ -99,6 +108,8 @@ func createTables() {
 			sales INTEGER DEFAULT 0,
 			images TEXT,
 			brand VARCHAR(100),
+			weight DECIMAL(10,3) DEFAULT 0,
+			shipping_template_id INTEGER DEFAULT 0,
 			status INTEGER DEFAULT 0,
 			rating DECIMAL(2,1) DEFAULT 5.0,
 			rating_count INTEGER DEFAULT 0,
@@ -112,19 +123,58 @@ func createTables() {
 		log.Fatal("创建商品表失败:", err)
 	}
 
+	// 商品规格项表（如颜色、尺码），规格值以逗号分隔
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS product_options (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			product_id INTEGER NOT NULL,
+			name VARCHAR(50) NOT NULL,
+			option_values TEXT NOT NULL,
+			sort_order INTEGER DEFAULT 0,
+			FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		log.Fatal("创建商品规格项表失败:", err)
+	}
+
+	// 商品SKU表，specs 为规格项到规格值的JSON
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS product_skus (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			product_id INTEGER NOT NULL,
+			sku_code VARCHAR(64),
+			specs TEXT NOT NULL,
+			price DECIMAL(10,2) NOT NULL,
+			original_price DECIMAL(10,2) DEFAULT 0,
+			stock INTEGER DEFAULT 0,
+			sales INTEGER DEFAULT 0,
+			image VARCHAR(255),
+			status INTEGER DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+			UNIQUE(product_id, specs)
+		)
+	`)
+	if err != nil {
+		log.Fatal("创建商品SKU表失败:", err)
+	}
+
 	// 购物车表
 	_, err = DB.Exec(`
 		CREATE TABLE IF NOT EXISTS cart_items (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			user_id INTEGER NOT NULL,
 			product_id INTEGER NOT NULL,
+			sku_id INTEGER DEFAULT 0,
 			quantity INTEGER DEFAULT 1,
 			selected INTEGER DEFAULT 1,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (user_id) REFERENCES users(id),
 			FOREIGN KEY (product_id) REFERENCES products(id),
-			UNIQUE(user_id, product_id)
+			UNIQUE(user_id, product_id, sku_id)
 		)
 	`)
 	if err != nil {
@@ -161,6 +211,8 @@ func createTables() {
 			total_amount DECIMAL(10,2) NOT NULL,
 			pay_amount DECIMAL(10,2) NOT NULL,
 			freight_amount DECIMAL(10,2) DEFAULT 0,
+			discount_amount DECIMAL(10,2) DEFAULT 0,
+			user_coupon_id INTEGER DEFAULT 0,
 			status INTEGER DEFAULT 0,
 			pay_type VARCHAR(20),
 			pay_time DATETIME,
@@ -190,9 +242,12 @@ func createTables() {
 			product_id INTEGER NOT NULL,
 			product_name VARCHAR(200),
 			product_image VARCHAR(255),
+			sku_id INTEGER DEFAULT 0,
+			sku_specs VARCHAR(255) DEFAULT '',
 			price DECIMAL(10,2) NOT NULL,
 			quantity INTEGER NOT NULL,
 			total_price DECIMAL(10,2) NOT NULL,
+			refunded_quantity INTEGER DEFAULT 0,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (order_id) REFERENCES orders(id),
 			FOREIGN KEY (product_id) REFERENCES products(id)
@@ -242,9 +297,259 @@ func createTables() {
 		log.Fatal("创建消息表失败:", err)
 	}
 
+	// 支付记录表，每次发起支付生成一条记录，payment_no 为本地支付流水号
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS payments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			payment_no VARCHAR(64) UNIQUE NOT NULL,
+			order_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			provider VARCHAR(20) NOT NULL,
+			amount DECIMAL(10,2) NOT NULL,
+			refunded_amount DECIMAL(10,2) DEFAULT 0,
+			status INTEGER DEFAULT 0,
+			trade_no VARCHAR(64) DEFAULT '',
+			pay_url VARCHAR(500) DEFAULT '',
+			refund_no VARCHAR(64) DEFAULT '',
+			paid_at DATETIME,
+			refunded_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (order_id) REFERENCES orders(id),
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)
+	`)
+	if err != nil {
+		log.Fatal("创建支付记录表失败:", err)
+	}
+
+	// 售后单表，每个订单商品同时只能有一个进行中的售后单
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS after_sales (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			after_sale_no VARCHAR(50) UNIQUE NOT NULL,
+			order_id INTEGER NOT NULL,
+			order_item_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			seller_id INTEGER NOT NULL,
+			type VARCHAR(20) NOT NULL,
+			reason VARCHAR(100) NOT NULL,
+			description TEXT,
+			images TEXT,
+			quantity INTEGER NOT NULL,
+			amount DECIMAL(10,2) NOT NULL,
+			status INTEGER DEFAULT 0,
+			reject_reason TEXT,
+			return_tracking_no VARCHAR(50),
+			arbitration_reason TEXT,
+			admin_remark TEXT,
+			refund_no VARCHAR(64),
+			finished_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (order_id) REFERENCES orders(id),
+			FOREIGN KEY (order_item_id) REFERENCES order_items(id),
+			FOREIGN KEY (user_id) REFERENCES users(id),
+			FOREIGN KEY (seller_id) REFERENCES sellers(id)
+		)
+	`)
+	if err != nil {
+		log.Fatal("创建售后单表失败:", err)
+	}
+
+	// 运费模板表
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS shipping_templates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			seller_id INTEGER NOT NULL,
+			name VARCHAR(100) NOT NULL,
+			charge_type VARCHAR(20) DEFAULT 'item',
+			free_threshold DECIMAL(10,2) DEFAULT 0,
+			is_default INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (seller_id) REFERENCES sellers(id)
+		)
+	`)
+	if err != nil {
+		log.Fatal("创建运费模板表失败:", err)
+	}
+
+	// 运费规则表，provinces 为逗号分隔的省份，为空表示默认规则
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS shipping_rules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			template_id INTEGER NOT NULL,
+			provinces TEXT DEFAULT '',
+			first_unit DECIMAL(10,3) DEFAULT 1,
+			first_fee DECIMAL(10,2) DEFAULT 0,
+			additional_unit DECIMAL(10,3) DEFAULT 1,
+			additional_fee DECIMAL(10,2) DEFAULT 0,
+			FOREIGN KEY (template_id) REFERENCES shipping_templates(id)
+		)
+	`)
+	if err != nil {
+		log.Fatal("创建运费规则表失败:", err)
+	}
+
+	// 优惠券表，seller_id 为 0 表示平台券
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS coupons (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			seller_id INTEGER DEFAULT 0,
+			name VARCHAR(100) NOT NULL,
+			type VARCHAR(20) NOT NULL,
+			value DECIMAL(10,2) NOT NULL,
+			min_amount DECIMAL(10,2) DEFAULT 0,
+			max_discount DECIMAL(10,2) DEFAULT 0,
+			category_id INTEGER DEFAULT 0,
+			total_count INTEGER DEFAULT 0,
+			claimed_count INTEGER DEFAULT 0,
+			per_user_limit INTEGER DEFAULT 1,
+			start_time DATETIME NOT NULL,
+			end_time DATETIME NOT NULL,
+			status INTEGER DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		log.Fatal("创建优惠券表失败:", err)
+	}
+
+	// 用户优惠券表（券包）
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS user_coupons (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			coupon_id INTEGER NOT NULL,
+			status INTEGER DEFAULT 0,
+			order_id INTEGER,
+			claimed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			used_at DATETIME,
+			FOREIGN KEY (user_id) REFERENCES users(id),
+			FOREIGN KEY (coupon_id) REFERENCES coupons(id)
+		)
+	`)
+	if err != nil {
+		log.Fatal("创建用户优惠券表失败:", err)
+	}
+
+	// 登录会话表，只保存令牌的哈希值
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS sessions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			token_hash VARCHAR(64) UNIQUE NOT NULL,
+			user_id INTEGER NOT NULL,
+			username VARCHAR(50) NOT NULL,
+			role VARCHAR(20) NOT NULL,
+			seller_id INTEGER DEFAULT 0,
+			expires_at DATETIME NOT NULL,
+			last_seen_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)
+	`)
+	if err != nil {
+		log.Fatal("创建会话表失败:", err)
+	}
+
+	// 商品全文索引，rowid 为商品ID，内容为分词后的文本
+	_, err = DB.Exec(`
+		CREATE VIRTUAL TABLE IF NOT EXISTS products_fts USING fts5(
+			name, description, brand, category,
+			tokenize = 'unicode61'
+		)
+	`)
+	if err == nil {
+		// 索引表已存在时建表语句不会加载 FTS5 模块，需要实际查询一次
+		// 确认当前程序支持 FTS5
+		_, err = DB.Exec(`SELECT * FROM products_fts LIMIT 0`)
+	}
+	FTSEnabled = err == nil
+	if err != nil {
+		log.Println("SQLite 不支持 FTS5，商品搜索使用 LIKE 查询:", err)
+	}
+
 	log.Println("数据库表创建成功")
 }
 
+// migrateTables 为旧版本数据库补充新增的字段
+func migrateTables() {
+	// 购物车按SKU区分，需要重建唯一索引
+	if !hasColumn("cart_items", "sku_id") {
+		tx, err := DB.Begin()
+		if err != nil {
+			log.Fatal("迁移购物车表失败:", err)
+		}
+		for _, stmt := range []string{
+			`ALTER TABLE cart_items RENAME TO cart_items_old`,
+			`CREATE TABLE cart_items (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				product_id INTEGER NOT NULL,
+				sku_id INTEGER DEFAULT 0,
+				quantity INTEGER DEFAULT 1,
+				selected INTEGER DEFAULT 1,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id),
+				FOREIGN KEY (product_id) REFERENCES products(id),
+				UNIQUE(user_id, product_id, sku_id)
+			)`,
+			`INSERT INTO cart_items (id, user_id, product_id, quantity, selected, created_at, updated_at)
+				SELECT id, user_id, product_id, quantity, selected, created_at, updated_at FROM cart_items_old`,
+			`DROP TABLE cart_items_old`,
+		} {
+			if _, err := tx.Exec(stmt); err != nil {
+				tx.Rollback()
+				log.Fatal("迁移购物车表失败:", err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			log.Fatal("迁移购物车表失败:", err)
+		}
+	}
+
+	addColumnIfNotExists("order_items", "sku_id", "INTEGER DEFAULT 0")
+	addColumnIfNotExists("order_items", "sku_specs", "VARCHAR(255) DEFAULT ''")
+	addColumnIfNotExists("orders", "discount_amount", "DECIMAL(10,2) DEFAULT 0")
+	addColumnIfNotExists("orders", "user_coupon_id", "INTEGER DEFAULT 0")
+	addColumnIfNotExists("products", "weight", "DECIMAL(10,3) DEFAULT 0")
+	addColumnIfNotExists("order_items", "refunded_quantity", "INTEGER DEFAULT 0")
+	addColumnIfNotExists("payments", "refunded_amount", "DECIMAL(10,2) DEFAULT 0")
+	addColumnIfNotExists("products", "shipping_template_id", "INTEGER DEFAULT 0")
+}
+
+func hasColumn(table, column string) bool {
+	rows, err := DB.Query("PRAGMA table_info(" + table + ")")
+	if err != nil {
+		log.Fatal("读取表结构失败:", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue interface{}
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			log.Fatal("读取表结构失败:", err)
+		}
+		if name == column {
+			return true
+		}
+	}
+	return false
+}
+
+func addColumnIfNotExists(table, column, definition string) {
+	if hasColumn(table, column) {
+		return
+	}
+	if _, err := DB.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition); err != nil {
+		log.Fatal("添加字段"+table+"."+column+"失败:", err)
+	}
+}
+
 func CloseDatabase() {
 	if DB != nil {
 		DB.Close()
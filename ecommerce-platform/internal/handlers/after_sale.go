@@ -0,0 +1,441 @@
+package handlers
+
+import (
+	"database/sql"
+	"ecommerce-platform/internal/middleware"
+	"ecommerce-platform/internal/models"
+	"ecommerce-platform/internal/payment"
+	"ecommerce-platform/internal/utils"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// CreateAfterSale 买家对订单中的一个商品申请仅退款或退货退款
+func CreateAfterSale(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetCurrentSession(r)
+
+	var req struct {
+		OrderItemID int64   `json:"order_item_id"`
+		Type        string  `json:"type"`
+		Reason      string  `json:"reason"`
+		Description string  `json:"description"`
+		Images      string  `json:"images"`
+		Quantity    int     `json:"quantity"`
+		Amount      float64 `json:"amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "请求参数错误")
+		return
+	}
+
+	if req.Type != models.AfterSaleRefund && req.Type != models.AfterSaleReturn {
+		utils.BadRequest(w, "售后类型错误")
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		utils.BadRequest(w, "请填写售后原因")
+		return
+	}
+
+	item, err := models.GetOrderItemByID(req.OrderItemID)
+	if err != nil {
+		utils.NotFound(w, "订单商品不存在")
+		return
+	}
+	order, err := models.GetOrderByID(item.OrderID)
+	if err != nil || order.UserID != session.UserID {
+		utils.NotFound(w, "订单商品不存在")
+		return
+	}
+
+	switch order.Status {
+	case 1:
+		if req.Type == models.AfterSaleReturn {
+			utils.BadRequest(w, "订单未发货，请申请仅退款")
+			return
+		}
+	case 2, 3:
+	default:
+		utils.BadRequest(w, "订单状态不允许申请售后")
+		return
+	}
+
+	remaining := item.Quantity - item.RefundedQuantity
+	if req.Quantity <= 0 {
+		req.Quantity = remaining
+	}
+	if req.Quantity > remaining {
+		utils.BadRequest(w, "申请数量超过可退数量")
+		return
+	}
+
+	// 未发货订单退掉整个订单剩余的全部商品时一并退还运费
+	includeFreight := order.Status == 1 && order.RefundsAllRemaining(item.ID, req.Quantity)
+	maxAmount := order.RefundableAmount(item, req.Quantity, includeFreight)
+	if req.Amount <= 0 {
+		req.Amount = maxAmount
+	}
+	if req.Amount > maxAmount {
+		utils.BadRequest(w, "退款金额超过可退金额")
+		return
+	}
+
+	as := &models.AfterSale{
+		AfterSaleNo: "AS" + models.GenerateOrderNo(),
+		OrderID:     order.ID,
+		OrderItemID: item.ID,
+		UserID:      session.UserID,
+		SellerID:    order.SellerID,
+		Type:        req.Type,
+		Reason:      req.Reason,
+		Description: req.Description,
+		Images:      req.Images,
+		Quantity:    req.Quantity,
+		Amount:      req.Amount,
+	}
+	id, err := models.CreateAfterSale(as)
+	if errors.Is(err, models.ErrAfterSaleExists) {
+		utils.BadRequest(w, err.Error())
+		return
+	}
+	if err != nil {
+		utils.InternalError(w, "申请失败")
+		return
+	}
+
+	as, _ = models.GetAfterSaleByID(id)
+	utils.Success(w, as)
+}
+
+// GetAfterSales 买家获取售后列表
+func GetAfterSales(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetCurrentSession(r)
+
+	query := r.URL.Query()
+	page := utils.ParseInt(query.Get("page"), 1)
+	size := utils.ParseInt(query.Get("size"), 10)
+	status := utils.ParseInt(query.Get("status"), -1)
+
+	list, total, err := models.GetAfterSales(page, size, session.UserID, 0, status)
+	if err != nil {
+		utils.InternalError(w, "获取失败")
+		return
+	}
+
+	utils.SuccessPage(w, list, total, page, size)
+}
+
+// GetAfterSale 获取售后详情，买家、对应商家和管理员可以查看
+func GetAfterSale(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetCurrentSession(r)
+
+	as, err := models.GetAfterSaleByID(utils.ParseInt64(r.URL.Query().Get("id"), 0))
+	if err != nil {
+		utils.NotFound(w, "售后单不存在")
+		return
+	}
+
+	if as.UserID != session.UserID && session.Role != "admin" {
+		seller, _ := models.GetSellerByUserID(session.UserID)
+		if seller == nil || as.SellerID != seller.ID {
+			utils.Forbidden(w, "没有权限")
+			return
+		}
+	}
+
+	utils.Success(w, as)
+}
+
+// getBuyerAfterSale 获取当前买家的售后单，不存在或不属于当前用户时写入错误响应并返回 nil
+func getBuyerAfterSale(w http.ResponseWriter, r *http.Request, id int64) *models.AfterSale {
+	session := middleware.GetCurrentSession(r)
+
+	as, err := models.GetAfterSaleByID(id)
+	if err != nil {
+		utils.NotFound(w, "售后单不存在")
+		return nil
+	}
+	if as.UserID != session.UserID {
+		utils.Forbidden(w, "没有权限")
+		return nil
+	}
+	return as
+}
+
+// getSellerAfterSale 获取当前商家的售后单，不存在或不属于当前商家时写入错误响应并返回 nil
+func getSellerAfterSale(w http.ResponseWriter, r *http.Request, id int64) *models.AfterSale {
+	session := middleware.GetCurrentSession(r)
+
+	as, err := models.GetAfterSaleByID(id)
+	if err != nil {
+		utils.NotFound(w, "售后单不存在")
+		return nil
+	}
+	seller, _ := models.GetSellerByUserID(session.UserID)
+	if session.Role != "admin" && (seller == nil || as.SellerID != seller.ID) {
+		utils.Forbidden(w, "没有权限")
+		return nil
+	}
+	return as
+}
+
+// writeAfterSaleResult 根据售后操作的结果写入响应
+func writeAfterSaleResult(w http.ResponseWriter, err error, message string) {
+	switch {
+	case err == nil:
+		utils.SuccessMessage(w, message)
+	case errors.Is(err, models.ErrAfterSaleStatus):
+		utils.BadRequest(w, err.Error())
+	default:
+		log.Printf("售后处理失败: %v", err)
+		utils.InternalError(w, "操作失败")
+	}
+}
+
+// CancelAfterSale 买家撤销售后申请
+func CancelAfterSale(w http.ResponseWriter, r *http.Request) {
+	as := getBuyerAfterSale(w, r, utils.ParseInt64(r.URL.Query().Get("id"), 0))
+	if as == nil {
+		return
+	}
+
+	writeAfterSaleResult(w, models.CancelAfterSale(as.ID), "撤销成功")
+}
+
+// SubmitAfterSaleReturn 买家寄回商品后填写退货物流单号
+func SubmitAfterSaleReturn(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID         int64  `json:"id"`
+		TrackingNo string `json:"tracking_no"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "请求参数错误")
+		return
+	}
+	if strings.TrimSpace(req.TrackingNo) == "" {
+		utils.BadRequest(w, "请填写退货物流单号")
+		return
+	}
+
+	as := getBuyerAfterSale(w, r, req.ID)
+	if as == nil {
+		return
+	}
+
+	writeAfterSaleResult(w, models.SubmitReturn(as.ID, req.TrackingNo), "提交成功")
+}
+
+// RequestAfterSaleArbitration 商家拒绝后买家申请平台介入
+func RequestAfterSaleArbitration(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     int64  `json:"id"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "请求参数错误")
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		utils.BadRequest(w, "请填写申请平台介入的原因")
+		return
+	}
+
+	as := getBuyerAfterSale(w, r, req.ID)
+	if as == nil {
+		return
+	}
+
+	writeAfterSaleResult(w, models.RequestArbitration(as.ID, req.Reason), "已提交平台处理")
+}
+
+// GetSellerAfterSales 商家获取售后列表
+func GetSellerAfterSales(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetCurrentSession(r)
+
+	seller, err := models.GetSellerByUserID(session.UserID)
+	if err != nil {
+		utils.Forbidden(w, "您还不是商家")
+		return
+	}
+
+	query := r.URL.Query()
+	page := utils.ParseInt(query.Get("page"), 1)
+	size := utils.ParseInt(query.Get("size"), 10)
+	status := utils.ParseInt(query.Get("status"), -1)
+
+	list, total, err := models.GetAfterSales(page, size, 0, seller.ID, status)
+	if err != nil {
+		utils.InternalError(w, "获取失败")
+		return
+	}
+
+	utils.SuccessPage(w, list, total, page, size)
+}
+
+// ApproveAfterSale 商家同意售后申请：仅退款直接退款，退货退款等待买家寄回商品；
+// 退款中的售后单重新发起退款
+func ApproveAfterSale(w http.ResponseWriter, r *http.Request) {
+	as := getSellerAfterSale(w, r, utils.ParseInt64(r.URL.Query().Get("id"), 0))
+	if as == nil {
+		return
+	}
+	if as.Status == models.AfterSaleRefunding {
+		// 上次退款失败，重新退款
+		writeAfterSaleResult(w, refundAfterSale(as, models.AfterSaleRefunding), "退款成功")
+		return
+	}
+	if as.Status != models.AfterSalePending {
+		utils.BadRequest(w, models.ErrAfterSaleStatus.Error())
+		return
+	}
+
+	if as.Type == models.AfterSaleReturn {
+		writeAfterSaleResult(w, models.ApproveReturn(as.ID, models.AfterSalePending), "已同意退货")
+		return
+	}
+	writeAfterSaleResult(w, refundAfterSale(as, models.AfterSalePending), "退款成功")
+}
+
+// RejectAfterSale 商家拒绝售后申请
+func RejectAfterSale(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     int64  `json:"id"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "请求参数错误")
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		utils.BadRequest(w, "请填写拒绝原因")
+		return
+	}
+
+	as := getSellerAfterSale(w, r, req.ID)
+	if as == nil {
+		return
+	}
+
+	writeAfterSaleResult(w, models.RejectAfterSale(as.ID, req.Reason), "已拒绝")
+}
+
+// ReceiveAfterSaleReturn 商家确认收到退货并退款
+func ReceiveAfterSaleReturn(w http.ResponseWriter, r *http.Request) {
+	as := getSellerAfterSale(w, r, utils.ParseInt64(r.URL.Query().Get("id"), 0))
+	if as == nil {
+		return
+	}
+	if as.Status != models.AfterSaleReturned && as.Status != models.AfterSaleRefunding {
+		utils.BadRequest(w, models.ErrAfterSaleStatus.Error())
+		return
+	}
+
+	writeAfterSaleResult(w, refundAfterSale(as, as.Status), "退款成功")
+}
+
+// AdminGetAfterSales 管理员获取售后列表，status=5 为待平台处理的申请
+func AdminGetAfterSales(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page := utils.ParseInt(query.Get("page"), 1)
+	size := utils.ParseInt(query.Get("size"), 20)
+	status := utils.ParseInt(query.Get("status"), -1)
+
+	list, total, err := models.GetAfterSales(page, size, 0, 0, status)
+	if err != nil {
+		utils.InternalError(w, "获取失败")
+		return
+	}
+
+	utils.SuccessPage(w, list, total, page, size)
+}
+
+// AdminResolveAfterSale 平台处理买家申请介入的售后单：支持买家时退款（退货退款未寄回商品的转为待买家退货），
+// 否则关闭售后单
+func AdminResolveAfterSale(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID      int64  `json:"id"`
+		Approve bool   `json:"approve"`
+		Remark  string `json:"remark"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "请求参数错误")
+		return
+	}
+
+	as, err := models.GetAfterSaleByID(req.ID)
+	if err != nil {
+		utils.NotFound(w, "售后单不存在")
+		return
+	}
+	if as.Status == models.AfterSaleRefunding && req.Approve {
+		writeAfterSaleResult(w, refundAfterSale(as, models.AfterSaleRefunding), "退款成功")
+		return
+	}
+	if as.Status != models.AfterSaleArbitration {
+		utils.BadRequest(w, models.ErrAfterSaleStatus.Error())
+		return
+	}
+
+	if !req.Approve {
+		writeAfterSaleResult(w, models.CloseArbitration(as.ID, req.Remark), "已驳回")
+		return
+	}
+
+	if err := models.SetAfterSaleAdminRemark(as.ID, req.Remark); err != nil {
+		utils.InternalError(w, "操作失败")
+		return
+	}
+	if as.Type == models.AfterSaleReturn && as.ReturnTrackingNo == "" {
+		writeAfterSaleResult(w, models.ApproveReturn(as.ID, models.AfterSaleArbitration), "已通知买家退货")
+		return
+	}
+	writeAfterSaleResult(w, refundAfterSale(as, models.AfterSaleArbitration), "退款成功")
+}
+
+// refundAfterSale 退款并完成售后单。先将售后单从 from 状态置为退款中，再通过原支付渠道退款，
+// 最后完成售后单；渠道退款失败时售后单停留在退款中，可以重新发起。
+// 以售后单号作为退款流水号，重复请求时渠道不会重复退款
+func refundAfterSale(as *models.AfterSale, from int) error {
+	if from != models.AfterSaleRefunding {
+		if err := models.StartAfterSaleRefund(as.ID, from, as.AfterSaleNo); err != nil {
+			return err
+		}
+	}
+
+	order, err := models.GetOrderByID(as.OrderID)
+	if err != nil {
+		return err
+	}
+	// 退货或尚未发货时商品回到仓库，恢复库存
+	restock := as.Type == models.AfterSaleReturn || order.Status == 1
+
+	var paymentID int64
+	p, err := models.GetPaidPayment(order.ID)
+	switch {
+	case err == sql.ErrNoRows:
+		// 没有线上支付记录的订单线下退款
+	case err != nil:
+		return err
+	default:
+		provider, err := payment.Get(p.Provider)
+		if err != nil {
+			return err
+		}
+		_, err = provider.Refund(&payment.RefundRequest{
+			PaymentNo: p.PaymentNo,
+			TradeNo:   p.TradeNo,
+			RefundNo:  as.AfterSaleNo,
+			Amount:    as.Amount,
+			Reason:    as.Reason,
+		})
+		if err != nil {
+			return err
+		}
+		paymentID = p.ID
+	}
+
+	return models.CompleteAfterSale(as, paymentID, restock)
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"context"
+	"ecommerce-platform/internal/middleware"
+	"ecommerce-platform/internal/models"
+	"ecommerce-platform/internal/payment"
+	"ecommerce-platform/internal/testutil"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// callHandler 以指定身份调用接口，返回响应码
+func callHandler(t *testing.T, handler http.HandlerFunc, session *middleware.Session, target, body string) int {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	r = r.WithContext(context.WithValue(r.Context(), middleware.SessionKey, session))
+	w := httptest.NewRecorder()
+	handler(w, r)
+
+	var resp struct {
+		Code int `json:"code"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Code
+}
+
+// setupAfterSaleTest 创建并支付一个购买 2 件商品、金额 100 的订单，返回订单ID和订单商品ID
+func setupAfterSaleTest(t *testing.T) (orderID int64, itemID string) {
+	t.Helper()
+	mock, _, orderID := setupPaymentTest(t)
+	if err := mock.Pay(startPayment(t, orderID)); err != nil {
+		t.Fatal(err)
+	}
+	items, _ := models.GetOrderItems(orderID)
+	return orderID, strconv.FormatInt(items[0].ID, 10)
+}
+
+func TestAfterSaleRefundAndReturn(t *testing.T) {
+	orderID, itemID := setupAfterSaleTest(t)
+	buyer := &middleware.Session{UserID: 1, Role: "customer"}
+	seller := &middleware.Session{UserID: 2, Role: "seller"}
+
+	// 未发货时仅退款 1 件：恢复库存和销量，按支付记录退款
+	if code := callHandler(t, CreateAfterSale, buyer, "/api/after-sale/create",
+		`{"order_item_id": `+itemID+`, "type": "refund", "reason": "不想要了", "quantity": 1}`); code != 200 {
+		t.Fatalf("申请仅退款失败: %d", code)
+	}
+	// 售后处理完之前不能发货
+	if code := callHandler(t, ShipOrder, seller, "/api/seller/order/ship",
+		`{"order_id": `+strconv.FormatInt(orderID, 10)+`, "tracking_no": "SF0"}`); code != 400 {
+		t.Errorf("有进行中的售后时发货应失败，实际 %d", code)
+	}
+	if code := callHandler(t, ApproveAfterSale, seller, "/api/seller/after-sale/approve?id=1", ""); code != 200 {
+		t.Fatalf("商家同意退款失败: %d", code)
+	}
+	if code := callHandler(t, ApproveAfterSale, seller, "/api/seller/after-sale/approve?id=1", ""); code != 400 {
+		t.Errorf("重复同意应失败，实际 %d", code)
+	}
+	if got := testutil.QueryInt(t, `SELECT stock FROM products WHERE id = 1`); got != 9 {
+		t.Errorf("仅退款后库存应为 9，实际 %d", got)
+	}
+	if got := testutil.QueryInt(t, `SELECT refunded_amount FROM payments WHERE order_id = ?`, orderID); got != 50 {
+		t.Errorf("支付记录退款金额应为 50，实际 %d", got)
+	}
+
+	// 发货后退货退款剩余 1 件，买家寄回后商家确认收货退款
+	if err := models.ShipOrder(orderID, "SF1"); err != nil {
+		t.Fatal(err)
+	}
+	if code := callHandler(t, CreateAfterSale, buyer, "/api/after-sale/create",
+		`{"order_item_id": `+itemID+`, "type": "return", "reason": "质量问题", "quantity": 2}`); code != 400 {
+		t.Errorf("申请数量超过可退数量应失败，实际 %d", code)
+	}
+	if code := callHandler(t, CreateAfterSale, buyer, "/api/after-sale/create",
+		`{"order_item_id": `+itemID+`, "type": "return", "reason": "质量问题"}`); code != 200 {
+		t.Fatalf("申请退货退款失败: %d", code)
+	}
+	if code := callHandler(t, ReceiveAfterSaleReturn, seller, "/api/seller/after-sale/receive?id=2", ""); code != 400 {
+		t.Errorf("买家未退货时商家不能确认收货，实际 %d", code)
+	}
+	if code := callHandler(t, ApproveAfterSale, seller, "/api/seller/after-sale/approve?id=2", ""); code != 200 {
+		t.Fatalf("商家同意退货失败: %d", code)
+	}
+	if code := callHandler(t, SubmitAfterSaleReturn, buyer, "/api/after-sale/return", `{"id": 2, "tracking_no": "YT1"}`); code != 200 {
+		t.Fatalf("填写退货单号失败: %d", code)
+	}
+	if code := callHandler(t, ReceiveAfterSaleReturn, seller, "/api/seller/after-sale/receive?id=2", ""); code != 200 {
+		t.Fatalf("商家确认收货失败: %d", code)
+	}
+
+	if got := testutil.QueryInt(t, `SELECT stock FROM products WHERE id = 1`); got != 10 {
+		t.Errorf("退货后库存应恢复为 10，实际 %d", got)
+	}
+	if got := testutil.QueryInt(t, `SELECT sales FROM products WHERE id = 1`); got != 0 {
+		t.Errorf("全部退款后销量应为 0，实际 %d", got)
+	}
+	if got := getOrderStatus(t, orderID); got != 6 {
+		t.Errorf("全部商品退款后订单应为已退款，实际 %d", got)
+	}
+}
+
+func TestAfterSaleArbitration(t *testing.T) {
+	orderID, itemID := setupAfterSaleTest(t)
+	buyer := &middleware.Session{UserID: 1, Role: "customer"}
+	seller := &middleware.Session{UserID: 2, Role: "seller"}
+	admin := &middleware.Session{UserID: 99, Role: "admin"}
+
+	callHandler(t, CreateAfterSale, buyer, "/api/after-sale/create",
+		`{"order_item_id": `+itemID+`, "type": "refund", "reason": "不想要了"}`)
+	if code := callHandler(t, RequestAfterSaleArbitration, buyer, "/api/after-sale/arbitrate", `{"id": 1, "reason": "商家不处理"}`); code != 400 {
+		t.Errorf("商家拒绝前不能申请平台介入，实际 %d", code)
+	}
+	if code := callHandler(t, RejectAfterSale, seller, "/api/seller/after-sale/reject", `{"id": 1, "reason": "已发货"}`); code != 200 {
+		t.Fatalf("商家拒绝失败: %d", code)
+	}
+	if code := callHandler(t, RequestAfterSaleArbitration, buyer, "/api/after-sale/arbitrate", `{"id": 1, "reason": "并未发货"}`); code != 200 {
+		t.Fatalf("申请平台介入失败: %d", code)
+	}
+	if code := callHandler(t, AdminResolveAfterSale, admin, "/api/admin/after-sale/resolve", `{"id": 1, "approve": true}`); code != 200 {
+		t.Fatalf("平台处理失败: %d", code)
+	}
+
+	as, _ := models.GetAfterSaleByID(1)
+	if as.Status != models.AfterSaleRefunded || as.Amount != 100 {
+		t.Errorf("平台支持买家后应全额退款，实际状态 %d 金额 %.2f", as.Status, as.Amount)
+	}
+	if got := getOrderStatus(t, orderID); got != 6 {
+		t.Errorf("全部商品退款后订单应为已退款，实际 %d", got)
+	}
+}
+
+func TestAfterSaleFreightRefundedOnce(t *testing.T) {
+	mock, _, _ := setupPaymentTest(t)
+	testutil.MustExec(t, `INSERT INTO products (id, seller_id, name, price, stock, status) VALUES (2, 1, 'q', 30, 10, 1)`)
+	// 两个商品、运费 10 的订单
+	orderID, err := models.CreateOrder(&models.Order{
+		OrderNo:       models.GenerateOrderNo(),
+		UserID:        1,
+		SellerID:      1,
+		TotalAmount:   80,
+		FreightAmount: 10,
+		PayAmount:     90,
+		Items: []*models.OrderItem{
+			{ProductID: 1, ProductName: "p", Price: 50, Quantity: 1, TotalPrice: 50},
+			{ProductID: 2, ProductName: "q", Price: 30, Quantity: 1, TotalPrice: 30},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.Pay(startPayment(t, orderID)); err != nil {
+		t.Fatal(err)
+	}
+	items, _ := models.GetOrderItems(orderID)
+	buyer := &middleware.Session{UserID: 1, Role: "customer"}
+	seller := &middleware.Session{UserID: 2, Role: "seller"}
+
+	for i, want := range []float64{50, 40} {
+		if code := callHandler(t, CreateAfterSale, buyer, "/api/after-sale/create",
+			`{"order_item_id": `+strconv.FormatInt(items[i].ID, 10)+`, "type": "refund", "reason": "不想要了"}`); code != 200 {
+			t.Fatalf("申请仅退款失败: %d", code)
+		}
+		id := strconv.Itoa(i + 1)
+		as, _ := models.GetAfterSaleByID(int64(i + 1))
+		if as.Amount != want {
+			t.Errorf("第 %d 个商品可退金额应为 %.2f，实际 %.2f", i+1, want, as.Amount)
+		}
+		if code := callHandler(t, ApproveAfterSale, seller, "/api/seller/after-sale/approve?id="+id, ""); code != 200 {
+			t.Fatalf("商家同意退款失败: %d", code)
+		}
+	}
+	if got := testutil.QueryInt(t, `SELECT refunded_amount FROM payments WHERE order_id = ?`, orderID); got != 90 {
+		t.Errorf("运费只应退还一次，支付记录退款金额应为 90，实际 %d", got)
+	}
+}
+
+func TestAfterSaleRetriesFailedRefund(t *testing.T) {
+	orderID, itemID := setupAfterSaleTest(t)
+	provider, _ := payment.Get("mock")
+	payment.Register(&flakyRefundProvider{MockProvider: provider.(*payment.MockProvider), failures: 1})
+	buyer := &middleware.Session{UserID: 1, Role: "customer"}
+	seller := &middleware.Session{UserID: 2, Role: "seller"}
+
+	callHandler(t, CreateAfterSale, buyer, "/api/after-sale/create",
+		`{"order_item_id": `+itemID+`, "type": "refund", "reason": "不想要了"}`)
+	// 渠道退款失败时售后单停留在退款中，不能撤销，也不能重复申请
+	if code := callHandler(t, ApproveAfterSale, seller, "/api/seller/after-sale/approve?id=1", ""); code != 500 {
+		t.Fatalf("渠道退款失败时应返回错误，实际 %d", code)
+	}
+	if as, _ := models.GetAfterSaleByID(1); as.Status != models.AfterSaleRefunding {
+		t.Fatalf("退款失败后售后单应为退款中，实际 %d", as.Status)
+	}
+	if code := callHandler(t, CancelAfterSale, buyer, "/api/after-sale/cancel?id=1", ""); code != 400 {
+		t.Errorf("退款中的售后单不能撤销，实际 %d", code)
+	}
+	if got := testutil.QueryInt(t, `SELECT refunded_amount FROM payments WHERE order_id = ?`, orderID); got != 0 {
+		t.Errorf("退款失败时不应记录退款金额，实际 %d", got)
+	}
+
+	// 商家再次同意时重新退款
+	if code := callHandler(t, ApproveAfterSale, seller, "/api/seller/after-sale/approve?id=1", ""); code != 200 {
+		t.Fatalf("重新退款失败: %d", code)
+	}
+	if as, _ := models.GetAfterSaleByID(1); as.Status != models.AfterSaleRefunded || as.RefundNo != as.AfterSaleNo {
+		t.Errorf("重新退款后售后单应为已退款，实际状态 %d 退款流水号 %s", as.Status, as.RefundNo)
+	}
+	if got := testutil.QueryInt(t, `SELECT refunded_amount FROM payments WHERE order_id = ?`, orderID); got != 100 {
+		t.Errorf("支付记录退款金额应为 100，实际 %d", got)
+	}
+}
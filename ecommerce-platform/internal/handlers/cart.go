@@ -42,6 +42,7 @@ func AddToCart(w http.ResponseWriter, r *http.Request) {
 
 	var req struct {
 		ProductID int64 `json:"product_id"`
+		SKUID     int64 `json:"sku_id"`
 		Quantity  int   `json:"quantity"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -65,12 +66,40 @@ func AddToCart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if product.Stock < req.Quantity {
+	// 多规格商品必须选择SKU，库存以SKU为准
+	stock := product.Stock
+	skus, err := models.GetProductSKUs(product.ID, true)
+	if err != nil {
+		utils.InternalError(w, "添加失败")
+		return
+	}
+	if len(skus) > 0 {
+		if req.SKUID == 0 {
+			utils.BadRequest(w, "请选择商品规格")
+			return
+		}
+		var selected *models.ProductSKU
+		for _, sku := range skus {
+			if sku.ID == req.SKUID {
+				selected = sku
+				break
+			}
+		}
+		if selected == nil {
+			utils.BadRequest(w, "商品规格不存在")
+			return
+		}
+		stock = selected.Stock
+	} else {
+		req.SKUID = 0
+	}
+
+	if stock < req.Quantity {
 		utils.BadRequest(w, "库存不足")
 		return
 	}
 
-	if err := models.AddToCart(session.UserID, req.ProductID, req.Quantity); err != nil {
+	if err := models.AddToCart(session.UserID, req.ProductID, req.SKUID, req.Quantity); err != nil {
 		utils.InternalError(w, "添加失败")
 		return
 	}
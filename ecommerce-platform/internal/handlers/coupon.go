@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"ecommerce-platform/internal/middleware"
+	"ecommerce-platform/internal/models"
+	"ecommerce-platform/internal/utils"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// GetCoupons 获取可领取的优惠券，可按商家筛选（seller_id=0 为平台券）
+func GetCoupons(w http.ResponseWriter, r *http.Request) {
+	sellerID := utils.ParseInt64(r.URL.Query().Get("seller_id"), -1)
+
+	coupons, _, err := models.GetCoupons(1, 100, sellerID, true)
+	if err != nil {
+		utils.InternalError(w, "获取失败")
+		return
+	}
+
+	// 只返回在有效期内且未领完的优惠券
+	now := time.Now()
+	list := []*models.Coupon{}
+	for _, coupon := range coupons {
+		if coupon.Available(now) && (coupon.TotalCount == 0 || coupon.ClaimedCount < coupon.TotalCount) {
+			list = append(list, coupon)
+		}
+	}
+
+	utils.Success(w, list)
+}
+
+// ClaimCoupon 领取优惠券
+func ClaimCoupon(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetCurrentSession(r)
+
+	var req struct {
+		CouponID int64 `json:"coupon_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "请求参数错误")
+		return
+	}
+
+	id, err := models.ClaimCoupon(session.UserID, req.CouponID)
+	if err != nil {
+		utils.BadRequest(w, err.Error())
+		return
+	}
+
+	utils.Success(w, map[string]int64{
+		"user_coupon_id": id,
+	})
+}
+
+// GetUserCoupons 获取我的券包，status: 0未使用 1已使用
+func GetUserCoupons(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetCurrentSession(r)
+
+	status := utils.ParseInt(r.URL.Query().Get("status"), -1)
+	coupons, err := models.GetUserCoupons(session.UserID, status)
+	if err != nil {
+		utils.InternalError(w, "获取失败")
+		return
+	}
+
+	utils.Success(w, coupons)
+}
+
+// SellerGetCoupons 获取本店优惠券
+func SellerGetCoupons(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetCurrentSession(r)
+
+	seller, err := models.GetSellerByUserID(session.UserID)
+	if err != nil {
+		utils.Forbidden(w, "您还不是商家")
+		return
+	}
+
+	query := r.URL.Query()
+	page := utils.ParseInt(query.Get("page"), 1)
+	size := utils.ParseInt(query.Get("size"), 20)
+
+	coupons, total, err := models.GetCoupons(page, size, seller.ID, false)
+	if err != nil {
+		utils.InternalError(w, "获取失败")
+		return
+	}
+
+	utils.SuccessPage(w, coupons, total, page, size)
+}
+
+// SellerCreateCoupon 创建本店优惠券
+func SellerCreateCoupon(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetCurrentSession(r)
+
+	seller, err := models.GetSellerByUserID(session.UserID)
+	if err != nil {
+		utils.Forbidden(w, "您还不是商家")
+		return
+	}
+	if seller.Status != 1 {
+		utils.Forbidden(w, "商家账号未审核通过")
+		return
+	}
+
+	createCoupon(w, r, seller.ID)
+}
+
+// SellerUpdateCouponStatus 启用/停用本店优惠券
+func SellerUpdateCouponStatus(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetCurrentSession(r)
+
+	seller, err := models.GetSellerByUserID(session.UserID)
+	if err != nil {
+		utils.Forbidden(w, "您还不是商家")
+		return
+	}
+
+	updateCouponStatus(w, r, seller.ID)
+}
+
+// AdminGetCoupons 管理员获取优惠券，seller_id=0 为平台券，不传则返回全部
+func AdminGetCoupons(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page := utils.ParseInt(query.Get("page"), 1)
+	size := utils.ParseInt(query.Get("size"), 20)
+	sellerID := utils.ParseInt64(query.Get("seller_id"), -1)
+
+	coupons, total, err := models.GetCoupons(page, size, sellerID, false)
+	if err != nil {
+		utils.InternalError(w, "获取失败")
+		return
+	}
+
+	utils.SuccessPage(w, coupons, total, page, size)
+}
+
+// AdminCreateCoupon 创建平台优惠券
+func AdminCreateCoupon(w http.ResponseWriter, r *http.Request) {
+	createCoupon(w, r, 0)
+}
+
+// AdminUpdateCouponStatus 启用/停用任意优惠券
+func AdminUpdateCouponStatus(w http.ResponseWriter, r *http.Request) {
+	updateCouponStatus(w, r, -1)
+}
+
+func createCoupon(w http.ResponseWriter, r *http.Request, sellerID int64) {
+	var coupon models.Coupon
+	if err := json.NewDecoder(r.Body).Decode(&coupon); err != nil {
+		utils.BadRequest(w, "请求参数错误")
+		return
+	}
+
+	coupon.SellerID = sellerID
+	coupon.Status = 1
+	if coupon.PerUserLimit <= 0 {
+		coupon.PerUserLimit = 1
+	}
+
+	if msg := validateCoupon(&coupon); msg != "" {
+		utils.BadRequest(w, msg)
+		return
+	}
+
+	id, err := models.CreateCoupon(&coupon)
+	if err != nil {
+		utils.InternalError(w, "创建失败: "+err.Error())
+		return
+	}
+	coupon.ID = id
+
+	utils.Success(w, coupon)
+}
+
+// validateCoupon 校验优惠券参数，返回错误提示
+func validateCoupon(coupon *models.Coupon) string {
+	if coupon.Name == "" {
+		return "优惠券名称不能为空"
+	}
+	switch coupon.Type {
+	case models.CouponFixed:
+	case models.CouponPercent:
+		if coupon.Value >= 100 {
+			return "折扣比例必须小于100"
+		}
+	case models.CouponThreshold:
+		if coupon.MinAmount <= coupon.Value {
+			return "满减券的使用门槛必须大于优惠金额"
+		}
+	default:
+		return "优惠券类型错误"
+	}
+	if coupon.Value <= 0 || coupon.MinAmount < 0 || coupon.MaxDiscount < 0 || coupon.TotalCount < 0 {
+		return "优惠金额参数错误"
+	}
+	if coupon.StartTime.IsZero() || !coupon.EndTime.After(coupon.StartTime) {
+		return "请设置正确的有效期"
+	}
+	if coupon.CategoryID > 0 {
+		if _, err := models.GetCategoryByID(coupon.CategoryID); err != nil {
+			return "分类不存在"
+		}
+	}
+	return ""
+}
+
+// updateCouponStatus 更新优惠券状态，sellerID 为 -1 时不校验归属
+func updateCouponStatus(w http.ResponseWriter, r *http.Request, sellerID int64) {
+	var req struct {
+		ID     int64 `json:"id"`
+		Status int   `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "请求参数错误")
+		return
+	}
+
+	coupon, err := models.GetCouponByID(req.ID)
+	if err != nil {
+		utils.NotFound(w, "优惠券不存在")
+		return
+	}
+	if sellerID >= 0 && coupon.SellerID != sellerID {
+		utils.Forbidden(w, "没有权限")
+		return
+	}
+
+	if err := models.UpdateCouponStatus(req.ID, req.Status); err != nil {
+		utils.InternalError(w, "更新失败")
+		return
+	}
+
+	utils.SuccessMessage(w, "更新成功")
+}
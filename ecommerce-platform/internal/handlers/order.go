@@ -1,21 +1,189 @@
 package handlers
 
 import (
-	"encoding/json"
 	"ecommerce-platform/internal/middleware"
 	"ecommerce-platform/internal/models"
+	"ecommerce-platform/internal/payment"
 	"ecommerce-platform/internal/utils"
+	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
+	"sort"
 	"strconv"
+	"time"
 )
 
+// checkoutOrder 结算时按商家拆分的订单及其金额明细
+type checkoutOrder struct {
+	SellerID         int64              `json:"seller_id"`
+	SellerName       string             `json:"seller_name"`
+	Items            []*models.CartItem `json:"items"`
+	TotalAmount      float64            `json:"total_amount"`
+	FreightAmount    float64            `json:"freight_amount"`
+	DiscountAmount   float64            `json:"discount_amount"`
+	PayAmount        float64            `json:"pay_amount"`
+	UserCouponID     int64              `json:"user_coupon_id"`
+	CouponName       string             `json:"coupon_name"`
+	AvailableCoupons []availableCoupon  `json:"available_coupons"`
+}
+
+// availableCoupon 订单可用的优惠券及可优惠金额
+type availableCoupon struct {
+	UserCouponID int64   `json:"user_coupon_id"`
+	Name         string  `json:"name"`
+	Discount     float64 `json:"discount"`
+}
+
+// buildCheckout 将选中的购物车商品按商家拆分，计算商品金额、运费、可用优惠券和优惠金额。
+// province 为收货省份，用于匹配运费规则；coupons 为商家ID到用户优惠券ID的映射，每个订单最多使用一张优惠券
+func buildCheckout(userID int64, province string, cartItems []*models.CartItem, coupons map[int64]int64) ([]*checkoutOrder, error) {
+	// 按商家分组，商家顺序固定以便结果可复现
+	sellerOrders := make(map[int64]*checkoutOrder)
+	var sellerIDs []int64
+	for _, item := range cartItems {
+		// 多规格商品必须选择仍可售的SKU
+		if item.SKUID > 0 {
+			sku, _ := models.GetSKUByID(item.SKUID)
+			if sku == nil || sku.Status != 1 {
+				return nil, errors.New("商品" + item.Product.Name + "的规格已失效，请重新选择")
+			}
+		} else if hasSKUs, _ := models.HasProductSKUs(item.ProductID); hasSKUs {
+			return nil, errors.New("请为商品" + item.Product.Name + "选择规格")
+		}
+
+		co, ok := sellerOrders[item.Product.SellerID]
+		if !ok {
+			co = &checkoutOrder{SellerID: item.Product.SellerID, SellerName: item.Product.SellerName}
+			sellerOrders[item.Product.SellerID] = co
+			sellerIDs = append(sellerIDs, item.Product.SellerID)
+		}
+		co.Items = append(co.Items, item)
+		co.TotalAmount += item.Product.Price * float64(item.Quantity)
+	}
+	sort.Slice(sellerIDs, func(i, j int) bool { return sellerIDs[i] < sellerIDs[j] })
+
+	wallet, err := models.GetUserCoupons(userID, 0)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	usedCoupons := make(map[int64]bool)
+
+	var orders []*checkoutOrder
+	for _, sellerID := range sellerIDs {
+		co := sellerOrders[sellerID]
+		co.TotalAmount = math.Round(co.TotalAmount*100) / 100
+
+		co.FreightAmount, err = models.CalcFreight(sellerID, province, co.Items)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, uc := range wallet {
+			if !uc.Coupon.Available(now) {
+				continue
+			}
+			discount, err := uc.Coupon.Discount(sellerID, co.Items)
+			if err != nil {
+				continue
+			}
+			co.AvailableCoupons = append(co.AvailableCoupons, availableCoupon{
+				UserCouponID: uc.ID,
+				Name:         uc.Coupon.Name,
+				Discount:     discount,
+			})
+		}
+
+		if userCouponID := coupons[sellerID]; userCouponID > 0 {
+			if usedCoupons[userCouponID] {
+				return nil, errors.New("同一张优惠券不能用于多个订单")
+			}
+			uc, err := models.GetUserCouponByID(userID, userCouponID)
+			if err != nil || uc.Status != 0 || !uc.Coupon.Available(now) {
+				return nil, models.ErrCouponUnavailable
+			}
+			discount, err := uc.Coupon.Discount(sellerID, co.Items)
+			if err != nil {
+				return nil, err
+			}
+			usedCoupons[userCouponID] = true
+			co.UserCouponID = userCouponID
+			co.CouponName = uc.Coupon.Name
+			co.DiscountAmount = discount
+		}
+
+		co.PayAmount = math.Round((co.TotalAmount+co.FreightAmount-co.DiscountAmount)*100) / 100
+		orders = append(orders, co)
+	}
+	return orders, nil
+}
+
+// PreviewOrder 结算预览：按商家拆分订单，返回运费、可用优惠券和金额明细
+func PreviewOrder(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetCurrentSession(r)
+
+	var req struct {
+		AddressID int64           `json:"address_id"`
+		Coupons   map[int64]int64 `json:"coupons"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.BadRequest(w, "请求参数错误")
+			return
+		}
+	}
+
+	// 未选择地址时使用默认地址计算运费，没有地址时按默认运费规则计算
+	var province string
+	if req.AddressID > 0 {
+		address, err := models.GetAddressByID(req.AddressID)
+		if err != nil || address.UserID != session.UserID {
+			utils.BadRequest(w, "收货地址不存在")
+			return
+		}
+		province = address.Province
+	} else if address, err := models.GetDefaultAddress(session.UserID); err == nil && address != nil {
+		province = address.Province
+	}
+
+	cartItems, err := models.GetSelectedCartItems(session.UserID)
+	if err != nil || len(cartItems) == 0 {
+		utils.BadRequest(w, "请选择要购买的商品")
+		return
+	}
+
+	orders, err := buildCheckout(session.UserID, province, cartItems, req.Coupons)
+	if err != nil {
+		utils.BadRequest(w, err.Error())
+		return
+	}
+
+	var totalAmount, freightAmount, discountAmount, payAmount float64
+	for _, co := range orders {
+		totalAmount += co.TotalAmount
+		freightAmount += co.FreightAmount
+		discountAmount += co.DiscountAmount
+		payAmount += co.PayAmount
+	}
+
+	utils.Success(w, map[string]interface{}{
+		"orders":          orders,
+		"total_amount":    math.Round(totalAmount*100) / 100,
+		"freight_amount":  math.Round(freightAmount*100) / 100,
+		"discount_amount": math.Round(discountAmount*100) / 100,
+		"pay_amount":      math.Round(payAmount*100) / 100,
+	})
+}
+
 // CreateOrder 创建订单
 func CreateOrder(w http.ResponseWriter, r *http.Request) {
 	session := middleware.GetCurrentSession(r)
 
 	var req struct {
-		AddressID int64  `json:"address_id"`
-		Remark    string `json:"remark"`
+		AddressID int64           `json:"address_id"`
+		Remark    string          `json:"remark"`
+		Coupons   map[int64]int64 `json:"coupons"` // 商家ID -> 用户优惠券ID
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.BadRequest(w, "请求参数错误")
@@ -30,7 +198,7 @@ func CreateOrder(w http.ResponseWriter, r *http.Request) {
 	} else {
 		address, err = models.GetDefaultAddress(session.UserID)
 	}
-	if err != nil || address == nil {
+	if err != nil || address == nil || address.UserID != session.UserID {
 		utils.BadRequest(w, "请选择收货地址")
 		return
 	}
@@ -42,68 +210,63 @@ func CreateOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 按商家分组
-	sellerOrders := make(map[int64][]*models.CartItem)
-	for _, item := range cartItems {
-		sellerOrders[item.Product.SellerID] = append(sellerOrders[item.Product.SellerID], item)
+	checkout, err := buildCheckout(session.UserID, address.Province, cartItems, req.Coupons)
+	if err != nil {
+		utils.BadRequest(w, err.Error())
+		return
 	}
 
-	var orderIDs []int64
+	receiverAddress := address.Province + address.City + address.District + address.DetailAddress
 
-	// 为每个商家创建订单
-	for sellerID, items := range sellerOrders {
-		var totalAmount float64
+	// 为每个商家生成订单，库存和优惠券在创建订单的事务中扣减和核销
+	var orders []*models.Order
+	var cartItemIDs []int64
+	for _, co := range checkout {
 		var orderItems []*models.OrderItem
-
-		for _, item := range items {
-			// 检查库存
-			product, _ := models.GetProductByID(item.ProductID)
-			if product == nil || product.Stock < item.Quantity {
-				utils.BadRequest(w, "商品"+item.Product.Name+"库存不足")
-				return
-			}
-
+		for _, item := range co.Items {
 			itemTotal := item.Product.Price * float64(item.Quantity)
-			totalAmount += itemTotal
-
 			orderItems = append(orderItems, &models.OrderItem{
 				ProductID:    item.ProductID,
 				ProductName:  item.Product.Name,
 				ProductImage: utils.GetFirstImage(item.Product.Images),
+				SKUID:        item.SKUID,
+				SKUSpecs:     item.SKUSpecs,
 				Price:        item.Product.Price,
 				Quantity:     item.Quantity,
 				TotalPrice:   itemTotal,
 			})
+			cartItemIDs = append(cartItemIDs, item.ID)
 		}
 
-		receiverAddress := address.Province + address.City + address.District + address.DetailAddress
-
-		order := &models.Order{
+		orders = append(orders, &models.Order{
 			OrderNo:         models.GenerateOrderNo(),
 			UserID:          session.UserID,
-			SellerID:        sellerID,
-			TotalAmount:     totalAmount,
-			PayAmount:       totalAmount,
-			FreightAmount:   0,
+			SellerID:        co.SellerID,
+			TotalAmount:     co.TotalAmount,
+			PayAmount:       co.PayAmount,
+			FreightAmount:   co.FreightAmount,
+			DiscountAmount:  co.DiscountAmount,
+			UserCouponID:    co.UserCouponID,
 			Status:          0, // 待支付
 			ReceiverName:    address.ReceiverName,
 			ReceiverPhone:   address.Phone,
 			ReceiverAddress: receiverAddress,
 			Remark:          req.Remark,
 			Items:           orderItems,
-		}
+		})
+	}
 
-		orderID, err := models.CreateOrder(order)
-		if err != nil {
-			utils.InternalError(w, "创建订单失败: "+err.Error())
+	// 所有商家的订单和购物车清理在同一事务中完成
+	orderIDs, err := models.CreateOrders(orders, session.UserID, cartItemIDs)
+	if err != nil {
+		if errors.Is(err, models.ErrInsufficientStock) || errors.Is(err, models.ErrCouponUnavailable) {
+			utils.BadRequest(w, err.Error())
 			return
 		}
-		orderIDs = append(orderIDs, orderID)
+		utils.InternalError(w, "创建订单失败: "+err.Error())
+		return
 	}
 
-	// 清空已购买的购物车商品
-	models.ClearSelectedCart(session.UserID)
-
 	utils.Success(w, map[string]interface{}{
 		"order_ids": orderIDs,
 	})
@@ -153,19 +316,26 @@ func GetOrder(w http.ResponseWriter, r *http.Request) {
 	utils.Success(w, order)
 }
 
-// PayOrder 支付订单
+// PayOrder 发起支付：为订单创建支付记录并向支付渠道下单，返回支付地址。
+// 订单在收到渠道的异步通知后才变为已支付，见 PaymentNotify
 func PayOrder(w http.ResponseWriter, r *http.Request) {
 	session := middleware.GetCurrentSession(r)
 
 	var req struct {
 		OrderID int64  `json:"order_id"`
-		PayType string `json:"pay_type"`
+		PayType string `json:"pay_type"` // 支付渠道
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.BadRequest(w, "请求参数错误")
 		return
 	}
 
+	provider, err := payment.Get(req.PayType)
+	if err != nil {
+		utils.BadRequest(w, err.Error())
+		return
+	}
+
 	order, err := models.GetOrderByID(req.OrderID)
 	if err != nil {
 		utils.NotFound(w, "订单不存在")
@@ -182,13 +352,39 @@ func PayOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 模拟支付成功
-	if err := models.PayOrder(req.OrderID, req.PayType); err != nil {
-		utils.InternalError(w, "支付失败")
+	p := &models.Payment{
+		PaymentNo: models.GeneratePaymentNo(),
+		OrderID:   order.ID,
+		UserID:    order.UserID,
+		Provider:  provider.Name(),
+		Amount:    order.PayAmount,
+	}
+	if p.ID, err = models.CreatePayment(p); err != nil {
+		utils.InternalError(w, "发起支付失败")
 		return
 	}
 
-	utils.SuccessMessage(w, "支付成功")
+	result, err := provider.CreatePayment(&payment.PaymentRequest{
+		PaymentNo: p.PaymentNo,
+		Amount:    p.Amount,
+		Subject:   "订单" + order.OrderNo,
+		NotifyURL: notifyURL(provider.Name()),
+	})
+	if err != nil {
+		utils.InternalError(w, "发起支付失败: "+err.Error())
+		return
+	}
+	if err := models.UpdatePaymentTrade(p.ID, result.TradeNo, result.PayURL); err != nil {
+		utils.InternalError(w, "发起支付失败")
+		return
+	}
+
+	utils.Success(w, map[string]interface{}{
+		"payment_no": p.PaymentNo,
+		"provider":   p.Provider,
+		"amount":     p.Amount,
+		"pay_url":    result.PayURL,
+	})
 }
 
 // CancelOrder 取消订单
@@ -303,6 +499,10 @@ func ShipOrder(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := models.ShipOrder(req.OrderID, req.TrackingNo); err != nil {
+		if errors.Is(err, models.ErrOrderNotShippable) || errors.Is(err, models.ErrOrderAfterSaleOpen) {
+			utils.BadRequest(w, err.Error())
+			return
+		}
 		utils.InternalError(w, "发货失败")
 		return
 	}
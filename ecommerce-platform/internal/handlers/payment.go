@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"database/sql"
+	"ecommerce-platform/internal/config"
+	"ecommerce-platform/internal/middleware"
+	"ecommerce-platform/internal/models"
+	"ecommerce-platform/internal/payment"
+	"ecommerce-platform/internal/utils"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// GetPaymentProviders 获取可用的支付渠道
+func GetPaymentProviders(w http.ResponseWriter, r *http.Request) {
+	list := []map[string]string{}
+	for _, p := range payment.Providers() {
+		list = append(list, map[string]string{
+			"name":  p.Name(),
+			"title": p.Title(),
+		})
+	}
+	utils.Success(w, list)
+}
+
+// GetPayment 查询支付记录，用于支付后轮询支付结果
+func GetPayment(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetCurrentSession(r)
+
+	p, err := models.GetPaymentByNo(r.URL.Query().Get("payment_no"))
+	if err != nil {
+		utils.NotFound(w, "支付记录不存在")
+		return
+	}
+	if p.UserID != session.UserID && session.Role != "admin" {
+		utils.Forbidden(w, "没有权限")
+		return
+	}
+
+	utils.Success(w, p)
+}
+
+// PaymentNotify 支付渠道异步通知，路径为 /api/payment/notify/{渠道}。
+// 通知验签后将订单标记为已支付，重复通知不会重复处理；订单已取消或已支付时自动原路退款
+func PaymentNotify(w http.ResponseWriter, r *http.Request) {
+	provider, err := payment.Get(strings.TrimPrefix(r.URL.Path, "/api/payment/notify/"))
+	if err != nil {
+		utils.NotFound(w, err.Error())
+		return
+	}
+
+	notification, err := provider.VerifyCallback(r)
+	if err != nil {
+		utils.BadRequest(w, err.Error())
+		return
+	}
+	if !notification.Paid {
+		// 支付失败时支付记录保持待支付，用户可以重新发起支付
+		utils.SuccessMessage(w, "success")
+		return
+	}
+
+	p, err := models.CompletePayment(notification.PaymentNo, notification.TradeNo, notification.Amount)
+	switch {
+	case err == sql.ErrNoRows:
+		utils.NotFound(w, "支付记录不存在")
+		return
+	case errors.Is(err, models.ErrOrderNotPayable):
+		if err := refundPayment(provider, p, "订单已关闭，自动退款"); err != nil {
+			log.Printf("支付 %s 自动退款失败: %v", p.PaymentNo, err)
+			utils.InternalError(w, "退款失败")
+			return
+		}
+	case err != nil:
+		utils.BadRequest(w, err.Error())
+		return
+	}
+
+	utils.SuccessMessage(w, "success")
+}
+
+// refundPayment 通过支付渠道原路退还一笔已支付的支付记录。
+// 退款流水号由支付流水号生成，退款失败后重试时渠道可按同一流水号去重
+func refundPayment(provider payment.Provider, p *models.Payment, reason string) error {
+	refundNo := "R" + p.PaymentNo
+	_, err := provider.Refund(&payment.RefundRequest{
+		PaymentNo: p.PaymentNo,
+		TradeNo:   p.TradeNo,
+		RefundNo:  refundNo,
+		Amount:    p.Amount,
+		Reason:    reason,
+	})
+	if err != nil {
+		return err
+	}
+	return models.MarkPaymentRefunded(p.ID, refundNo)
+}
+
+// MockPay 模拟支付渠道的支付页：用户确认支付后，由模拟渠道异步发送支付成功通知
+func MockPay(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetCurrentSession(r)
+
+	p, err := models.GetPaymentByNo(r.URL.Query().Get("payment_no"))
+	if err != nil {
+		utils.NotFound(w, "支付记录不存在")
+		return
+	}
+	if p.UserID != session.UserID {
+		utils.Forbidden(w, "没有权限")
+		return
+	}
+	if p.Status != models.PaymentPending {
+		utils.BadRequest(w, "该笔支付已处理")
+		return
+	}
+
+	provider, _ := payment.Get(p.Provider)
+	mock, ok := provider.(*payment.MockProvider)
+	if !ok {
+		utils.BadRequest(w, "不是模拟支付订单")
+		return
+	}
+
+	go func() {
+		if err := mock.Pay(p.PaymentNo); err != nil {
+			log.Printf("模拟支付 %s 通知失败: %v", p.PaymentNo, err)
+		}
+	}()
+
+	utils.SuccessMessage(w, "支付已提交，等待支付结果")
+}
+
+// notifyURL 支付渠道异步通知地址，使用配置的对外地址，不使用请求头中的 Host
+func notifyURL(provider string) string {
+	return config.CallbackURL("/api/payment/notify/" + provider)
+}
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"context"
+	"ecommerce-platform/internal/config"
+	"ecommerce-platform/internal/middleware"
+	"ecommerce-platform/internal/models"
+	"ecommerce-platform/internal/payment"
+	"ecommerce-platform/internal/testutil"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// setupPaymentTest 使用临时数据库创建一个待支付订单，并启动只包含支付通知接口的测试服务
+func setupPaymentTest(t *testing.T) (mock *payment.MockProvider, server *httptest.Server, orderID int64) {
+	t.Helper()
+	testutil.OpenDB(t)
+
+	testutil.SeedBuyer(t)
+	testutil.SeedShop(t, 1)
+	testutil.SeedProduct(t, 1, 50, 10)
+
+	orderID, err := models.CreateOrder(&models.Order{
+		OrderNo:     models.GenerateOrderNo(),
+		UserID:      1,
+		SellerID:    1,
+		TotalAmount: 100,
+		PayAmount:   100,
+		Items:       []*models.OrderItem{{ProductID: 1, ProductName: "p", Price: 50, Quantity: 2, TotalPrice: 100}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock = payment.NewMockProvider("test-secret")
+	payment.Register(mock)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/payment/notify/", PaymentNotify)
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	// 渠道通知发送到测试服务
+	publicURL := config.PublicURL
+	config.PublicURL = server.URL
+	t.Cleanup(func() { config.PublicURL = publicURL })
+	return mock, server, orderID
+}
+
+// startPayment 以顾客身份调用 PayOrder 发起支付，返回支付流水号
+func startPayment(t *testing.T, orderID int64) string {
+	t.Helper()
+	body := strings.NewReader(`{"order_id": ` + strconv.FormatInt(orderID, 10) + `, "pay_type": "mock"}`)
+	r := httptest.NewRequest(http.MethodPost, "/api/order/pay", body)
+	r = r.WithContext(context.WithValue(r.Context(), middleware.SessionKey, &middleware.Session{UserID: 1, Role: "customer"}))
+	w := httptest.NewRecorder()
+	PayOrder(w, r)
+
+	var resp struct {
+		Code int `json:"code"`
+		Data struct {
+			PaymentNo string `json:"payment_no"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Code != 200 {
+		t.Fatalf("发起支付失败: %d %v", resp.Code, err)
+	}
+	return resp.Data.PaymentNo
+}
+
+func postNotify(t *testing.T, server *httptest.Server, form map[string][]string) int {
+	t.Helper()
+	resp, err := http.PostForm(server.URL+"/api/payment/notify/mock", form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func getOrderStatus(t *testing.T, orderID int64) int {
+	t.Helper()
+	order, err := models.GetOrderByID(orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return order.Status
+}
+
+func getPaymentStatus(t *testing.T, paymentNo string) int {
+	t.Helper()
+	p, err := models.GetPaymentByNo(paymentNo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.Status
+}
+
+func TestPaymentNotifyMarksOrderPaidIdempotently(t *testing.T) {
+	mock, server, orderID := setupPaymentTest(t)
+	paymentNo := startPayment(t, orderID)
+
+	if got := getOrderStatus(t, orderID); got != 0 {
+		t.Fatalf("收到支付通知前订单状态应为待支付，实际 %d", got)
+	}
+
+	if err := mock.Pay(paymentNo); err != nil {
+		t.Fatal(err)
+	}
+	if got := getOrderStatus(t, orderID); got != 1 {
+		t.Fatalf("支付后订单状态应为待发货，实际 %d", got)
+	}
+	if got := getPaymentStatus(t, paymentNo); got != models.PaymentPaid {
+		t.Fatalf("支付记录状态应为已支付，实际 %d", got)
+	}
+	order, _ := models.GetOrderByID(orderID)
+	if order.PayType != "mock" {
+		t.Errorf("订单支付方式应为 mock，实际 %q", order.PayType)
+	}
+
+	// 渠道重复通知不应重复处理
+	form := mock.NotifyForm(&payment.Notification{PaymentNo: paymentNo, TradeNo: "MOCK" + paymentNo, Amount: 100, Paid: true})
+	for i := 0; i < 3; i++ {
+		if code := postNotify(t, server, form); code != http.StatusOK {
+			t.Fatalf("重复通知应返回 200，实际 %d", code)
+		}
+	}
+	if got := getPaymentStatus(t, paymentNo); got != models.PaymentPaid {
+		t.Errorf("重复通知后支付记录状态应为已支付，实际 %d", got)
+	}
+	again, _ := models.GetOrderByID(orderID)
+	if !again.PayTime.Equal(*order.PayTime) {
+		t.Errorf("重复通知不应修改支付时间")
+	}
+}
+
+func TestPaymentNotifyRejectsInvalidCallbacks(t *testing.T) {
+	mock, server, orderID := setupPaymentTest(t)
+	paymentNo := startPayment(t, orderID)
+
+	form := mock.NotifyForm(&payment.Notification{PaymentNo: paymentNo, TradeNo: "MOCK" + paymentNo, Amount: 100, Paid: true})
+	form.Set("amount", "0.01")
+	if code := postNotify(t, server, form); code != http.StatusBadRequest {
+		t.Errorf("篡改金额后签名应校验失败，实际 %d", code)
+	}
+
+	other := payment.NewMockProvider("other-secret")
+	form = other.NotifyForm(&payment.Notification{PaymentNo: paymentNo, TradeNo: "MOCK" + paymentNo, Amount: 100, Paid: true})
+	if code := postNotify(t, server, form); code != http.StatusBadRequest {
+		t.Errorf("密钥错误的通知应被拒绝，实际 %d", code)
+	}
+
+	// 签名正确但金额与支付记录不一致
+	form = mock.NotifyForm(&payment.Notification{PaymentNo: paymentNo, TradeNo: "MOCK" + paymentNo, Amount: 1, Paid: true})
+	if code := postNotify(t, server, form); code != http.StatusBadRequest {
+		t.Errorf("金额不一致的通知应被拒绝，实际 %d", code)
+	}
+
+	if got := getOrderStatus(t, orderID); got != 0 {
+		t.Errorf("无效通知不应修改订单状态，实际 %d", got)
+	}
+}
+
+func TestPaymentNotifyRefundsUnpayableOrder(t *testing.T) {
+	mock, _, orderID := setupPaymentTest(t)
+
+	// 同一订单发起两次支付并都完成，第二笔支付应被退款
+	first := startPayment(t, orderID)
+	second := startPayment(t, orderID)
+	if err := mock.Pay(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.Pay(second); err != nil {
+		t.Fatal(err)
+	}
+	if got := getPaymentStatus(t, first); got != models.PaymentPaid {
+		t.Errorf("第一笔支付应为已支付，实际 %d", got)
+	}
+	if got := getPaymentStatus(t, second); got != models.PaymentRefunded {
+		t.Errorf("重复支付应已退款，实际 %d", got)
+	}
+}
+
+func TestPaymentNotifyRefundsCancelledOrder(t *testing.T) {
+	mock, _, orderID := setupPaymentTest(t)
+
+	paymentNo := startPayment(t, orderID)
+	if err := models.CancelOrder(orderID); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.Pay(paymentNo); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := getOrderStatus(t, orderID); got != 4 {
+		t.Errorf("已取消的订单不应变为已支付，实际状态 %d", got)
+	}
+	p, _ := models.GetPaymentByNo(paymentNo)
+	if p.Status != models.PaymentRefunded || p.RefundNo == "" {
+		t.Errorf("取消后完成的支付应自动退款，实际状态 %d", p.Status)
+	}
+}
+
+// flakyRefundProvider 前若干次退款失败的模拟渠道
+type flakyRefundProvider struct {
+	*payment.MockProvider
+	failures int
+}
+
+func (p *flakyRefundProvider) Refund(req *payment.RefundRequest) (*payment.RefundResult, error) {
+	if p.failures > 0 {
+		p.failures--
+		return nil, errors.New("渠道暂时不可用")
+	}
+	return p.MockProvider.Refund(req)
+}
+
+func TestPaymentNotifyRetriesFailedRefund(t *testing.T) {
+	mock, _, orderID := setupPaymentTest(t)
+	flaky := &flakyRefundProvider{MockProvider: mock, failures: 1}
+	payment.Register(flaky)
+
+	paymentNo := startPayment(t, orderID)
+	if err := models.CancelOrder(orderID); err != nil {
+		t.Fatal(err)
+	}
+
+	// 第一次通知退款失败，返回错误让渠道重新通知
+	if err := mock.Pay(paymentNo); err == nil {
+		t.Fatal("退款失败时通知应返回错误")
+	}
+	if got := getPaymentStatus(t, paymentNo); got != models.PaymentRefunding {
+		t.Fatalf("退款失败后支付记录应为待退款，实际 %d", got)
+	}
+
+	// 渠道重新通知时再次退款
+	if err := mock.Pay(paymentNo); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := models.GetPaymentByNo(paymentNo)
+	if p.Status != models.PaymentRefunded || p.RefundNo != "R"+paymentNo {
+		t.Errorf("重新通知后应完成退款，实际状态 %d 退款流水号 %q", p.Status, p.RefundNo)
+	}
+	if got := getOrderStatus(t, orderID); got != 4 {
+		t.Errorf("订单应保持已取消，实际 %d", got)
+	}
+}
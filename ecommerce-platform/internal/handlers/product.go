@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
 	"ecommerce-platform/internal/middleware"
 	"ecommerce-platform/internal/models"
+	"ecommerce-platform/internal/storage"
 	"ecommerce-platform/internal/utils"
+	"io"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -75,21 +79,63 @@ func DeleteCategory(w http.ResponseWriter, r *http.Request) {
 // GetProducts 获取商品列表
 func GetProducts(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
-	page := utils.ParseInt(query.Get("page"), 1)
-	size := utils.ParseInt(query.Get("size"), 20)
-	categoryID := utils.ParseInt64(query.Get("category_id"), 0)
-	sellerID := utils.ParseInt64(query.Get("seller_id"), 0)
-	keyword := query.Get("keyword")
-	sortBy := query.Get("sort")
-	status := utils.ParseInt(query.Get("status"), 1) // 默认只显示上架商品
+	q := &models.ProductQuery{
+		Page:       utils.ParseInt(query.Get("page"), 1),
+		PageSize:   utils.ParseInt(query.Get("size"), 20),
+		CategoryID: utils.ParseInt64(query.Get("category_id"), 0),
+		SellerID:   utils.ParseInt64(query.Get("seller_id"), 0),
+		Keyword:    strings.TrimSpace(query.Get("keyword")),
+		Brand:      query.Get("brand"),
+		MinPrice:   utils.ParseFloat64(query.Get("min_price"), 0),
+		MaxPrice:   utils.ParseFloat64(query.Get("max_price"), 0),
+		MinRating:  utils.ParseFloat64(query.Get("min_rating"), 0),
+		Status:     utils.ParseInt(query.Get("status"), 1), // 默认只显示上架商品
+		SortBy:     query.Get("sort"),
+	}
+
+	products, total, err := models.SearchProducts(q)
+	if err != nil {
+		utils.InternalError(w, "获取失败")
+		return
+	}
 
-	products, total, err := models.GetProducts(page, size, categoryID, sellerID, keyword, status, sortBy)
+	facets, err := models.GetProductFacets(q)
 	if err != nil {
 		utils.InternalError(w, "获取失败")
 		return
 	}
 
-	utils.SuccessPage(w, products, total, page, size)
+	utils.JSON(w, http.StatusOK, struct {
+		utils.PageResponse
+		Facets *models.ProductFacets `json:"facets"`
+	}{
+		PageResponse: utils.PageResponse{
+			Code:    200,
+			Message: "success",
+			Data:    products,
+			Total:   total,
+			Page:    q.Page,
+			Size:    q.PageSize,
+		},
+		Facets: facets,
+	})
+}
+
+// SuggestProducts 搜索建议，根据输入内容返回匹配的商品名称
+func SuggestProducts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := utils.ParseInt(query.Get("limit"), 10)
+	if limit <= 0 || limit > 20 {
+		limit = 10
+	}
+
+	names, err := models.SuggestProducts(query.Get("keyword"), limit)
+	if err != nil {
+		utils.InternalError(w, "获取失败")
+		return
+	}
+
+	utils.Success(w, names)
 }
 
 // GetProduct 获取商品详情
@@ -103,6 +149,17 @@ func GetProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 附带多规格信息
+	skus, err := models.GetProductSKUs(id, true)
+	if err != nil {
+		utils.InternalError(w, "获取失败")
+		return
+	}
+	if len(skus) > 0 {
+		product.SKUs = skus
+		product.Options, _ = models.GetProductOptions(id)
+	}
+
 	utils.Success(w, product)
 }
 
@@ -154,6 +211,11 @@ func CreateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if msg := validateProductShipping(&product, seller.ID); msg != "" {
+		utils.BadRequest(w, msg)
+		return
+	}
+
 	product.SellerID = seller.ID
 	product.Status = 0 // 待审核
 
@@ -193,6 +255,11 @@ func UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	// 保留原有的seller_id
 	product.SellerID = existProduct.SellerID
 
+	if msg := validateProductShipping(&product, existProduct.SellerID); msg != "" {
+		utils.BadRequest(w, msg)
+		return
+	}
+
 	if err := models.UpdateProduct(&product); err != nil {
 		utils.InternalError(w, "更新失败")
 		return
@@ -201,6 +268,20 @@ func UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	utils.SuccessMessage(w, "更新成功")
 }
 
+// validateProductShipping 校验商品重量和运费模板，运费模板必须属于商品所在店铺
+func validateProductShipping(product *models.Product, sellerID int64) string {
+	if product.Weight < 0 {
+		return "商品重量不能为负数"
+	}
+	if product.ShippingTemplateID > 0 {
+		template, err := models.GetShippingTemplateByID(product.ShippingTemplateID)
+		if err != nil || template.SellerID != sellerID {
+			return "运费模板不存在"
+		}
+	}
+	return ""
+}
+
 // DeleteProduct 删除商品（商家）
 func DeleteProduct(w http.ResponseWriter, r *http.Request) {
 	session := middleware.GetCurrentSession(r)
@@ -220,10 +301,13 @@ func DeleteProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// SKU随商品级联删除，先记下SKU图片
+	images := product.Images + "," + skuImages(id)
 	if err := models.DeleteProduct(id); err != nil {
 		utils.InternalError(w, "删除失败")
 		return
 	}
+	removeOrphanImages(images)
 
 	utils.SuccessMessage(w, "删除成功")
 }
@@ -259,6 +343,7 @@ func UpdateProductStock(w http.ResponseWriter, r *http.Request) {
 
 	var req struct {
 		ProductID int64 `json:"product_id"`
+		SKUID     int64 `json:"sku_id"`
 		Quantity  int   `json:"quantity"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -278,6 +363,24 @@ func UpdateProductStock(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 多规格商品按SKU调整库存
+	if req.SKUID > 0 {
+		if err := models.UpdateSKUStock(req.ProductID, req.SKUID, req.Quantity); err != nil {
+			if err == sql.ErrNoRows {
+				utils.NotFound(w, "商品规格不存在")
+				return
+			}
+			utils.InternalError(w, "更新失败")
+			return
+		}
+		utils.SuccessMessage(w, "更新成功")
+		return
+	}
+	if hasSKUs, _ := models.HasProductSKUs(req.ProductID); hasSKUs {
+		utils.BadRequest(w, "请选择要调整库存的商品规格")
+		return
+	}
+
 	if err := models.UpdateProductStock(req.ProductID, req.Quantity); err != nil {
 		utils.InternalError(w, "更新失败")
 		return
@@ -286,6 +389,48 @@ func UpdateProductStock(w http.ResponseWriter, r *http.Request) {
 	utils.SuccessMessage(w, "更新成功")
 }
 
+// SaveProductSKUs 设置商品规格和SKU（商家）
+func SaveProductSKUs(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetCurrentSession(r)
+
+	var req struct {
+		ProductID int64                   `json:"product_id"`
+		Options   []*models.ProductOption `json:"options"`
+		SKUs      []*models.ProductSKU    `json:"skus"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "请求参数错误")
+		return
+	}
+
+	product, err := models.GetProductByID(req.ProductID)
+	if err != nil {
+		utils.NotFound(w, "商品不存在")
+		return
+	}
+
+	seller, _ := models.GetSellerByUserID(session.UserID)
+	if session.Role != "admin" && (seller == nil || product.SellerID != seller.ID) {
+		utils.Forbidden(w, "没有权限")
+		return
+	}
+
+	oldImages := skuImages(req.ProductID)
+	if err := models.SaveProductSKUs(req.ProductID, req.Options, req.SKUs); err != nil {
+		utils.BadRequest(w, err.Error())
+		return
+	}
+	// 被替换的SKU图片不再被引用时删除
+	removeOrphanImages(oldImages)
+
+	skus, _ := models.GetProductSKUs(req.ProductID, true)
+	options, _ := models.GetProductOptions(req.ProductID)
+	utils.Success(w, map[string]interface{}{
+		"options": options,
+		"skus":    skus,
+	})
+}
+
 // AdminGetProducts 管理员获取所有商品
 func AdminGetProducts(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
@@ -322,38 +467,78 @@ func AdminUpdateProductStatus(w http.ResponseWriter, r *http.Request) {
 	utils.SuccessMessage(w, "更新成功")
 }
 
-// UploadImage 上传图片
+// UploadImage 上传图片，按文件内容识别类型并生成缩略图
 func UploadImage(w http.ResponseWriter, r *http.Request) {
-	r.ParseMultipartForm(10 << 20) // 10MB
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize+1<<20)
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+		utils.BadRequest(w, "文件过大，图片不能超过10MB")
+		return
+	}
 
-	file, handler, err := r.FormFile("file")
+	file, _, err := r.FormFile("file")
 	if err != nil {
 		utils.BadRequest(w, "文件上传失败")
 		return
 	}
 	defer file.Close()
 
-	// 检查文件类型
-	contentType := handler.Header.Get("Content-Type")
-	if !strings.HasPrefix(contentType, "image/") {
-		utils.BadRequest(w, "只能上传图片文件")
+	data, err := io.ReadAll(io.LimitReader(file, maxUploadSize+1))
+	if err != nil {
+		utils.BadRequest(w, "文件上传失败")
+		return
+	}
+	if len(data) > maxUploadSize {
+		utils.BadRequest(w, "文件过大，图片不能超过10MB")
 		return
 	}
 
-	// 生成文件名
-	ext := ".jpg"
-	if strings.Contains(handler.Filename, ".") {
-		parts := strings.Split(handler.Filename, ".")
-		ext = "." + parts[len(parts)-1]
+	result, err := storage.SaveImage(storage.Default, utils.GenerateToken()[:16], data)
+	if err != nil {
+		utils.BadRequest(w, err.Error())
+		return
 	}
-	filename := utils.GenerateToken()[:16] + ext
 
-	// 保存文件
-	// 这里简化处理，实际应该保存到文件服务器或云存储
-	// 返回一个模拟的URL
-	imageURL := "/uploads/" + filename
+	utils.Success(w, result)
+}
 
-	utils.Success(w, map[string]string{
-		"url": imageURL,
-	})
+// maxUploadSize 上传图片大小上限
+const maxUploadSize = 10 << 20
+
+// skuImages 返回商品所有SKU（含已停用）的图片，以逗号分隔
+func skuImages(productID int64) string {
+	skus, err := models.GetProductSKUs(productID, false)
+	if err != nil {
+		log.Printf("获取商品SKU失败 %d: %v", productID, err)
+		return ""
+	}
+	images := make([]string, 0, len(skus))
+	for _, sku := range skus {
+		images = append(images, sku.Image)
+	}
+	return strings.Join(images, ",")
+}
+
+// removeOrphanImages 删除不再被商品、SKU、订单或评价引用的本地图片
+func removeOrphanImages(images string) {
+	for _, url := range strings.Split(images, ",") {
+		url = strings.TrimSpace(url)
+		if url == "" {
+			continue
+		}
+		if _, ok := storage.Default.Name(url); !ok {
+			continue
+		}
+
+		referenced, err := models.IsImageReferenced(url)
+		if err != nil {
+			log.Printf("检查图片引用失败 %s: %v", url, err)
+			continue
+		}
+		if referenced {
+			continue
+		}
+		if err := storage.DeleteImage(storage.Default, url); err != nil {
+			log.Printf("删除图片失败 %s: %v", url, err)
+		}
+	}
 }
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"ecommerce-platform/internal/middleware"
+	"ecommerce-platform/internal/storage"
+	"ecommerce-platform/internal/testutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSKUImageCleanup(t *testing.T) {
+	testutil.OpenDB(t)
+	dir := t.TempDir()
+	defaultStorage := storage.Default
+	t.Cleanup(func() { storage.Default = defaultStorage })
+	if err := storage.Init(dir, "/uploads/"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.jpg", "b.jpg", "shared.jpg"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+
+	sellerUserID := testutil.SeedShop(t, 1)
+	testutil.SeedProduct(t, 1, 50, 10)
+	// 其他商品的SKU也使用 shared.jpg
+	other := testutil.SeedProduct(t, 1, 50, 10)
+	testutil.MustExec(t, `INSERT INTO product_skus (product_id, specs, price, image) VALUES (?, '{}', 50, '/uploads/shared.jpg')`, other)
+	seller := &middleware.Session{UserID: sellerUserID, Role: "seller"}
+
+	saveSKUs := func(images ...string) {
+		t.Helper()
+		body := `{"product_id": 1, "options": [{"name": "颜色", "values": ["黑", "白"]}], "skus": [` +
+			`{"specs": {"颜色": "黑"}, "price": 50, "stock": 1, "image": "` + images[0] + `"},` +
+			`{"specs": {"颜色": "白"}, "price": 50, "stock": 1, "image": "` + images[1] + `"}]}`
+		if code := callHandler(t, SaveProductSKUs, seller, "/api/seller/product/skus", body); code != 200 {
+			t.Fatalf("保存SKU失败: %d", code)
+		}
+	}
+	saveSKUs("/uploads/a.jpg", "/uploads/shared.jpg")
+	saveSKUs("/uploads/b.jpg", "/uploads/shared.jpg")
+	if exists("a.jpg") {
+		t.Error("被替换的SKU图片应删除")
+	}
+	if !exists("b.jpg") || !exists("shared.jpg") {
+		t.Error("仍在使用的SKU图片不应删除")
+	}
+
+	if code := callHandler(t, DeleteProduct, seller, "/api/seller/product/delete?id=1", ""); code != 200 {
+		t.Fatalf("删除商品失败: %d", code)
+	}
+	if exists("b.jpg") {
+		t.Error("删除商品后SKU图片应删除")
+	}
+	if !exists("shared.jpg") {
+		t.Error("其他商品SKU引用的图片不应删除")
+	}
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"ecommerce-platform/internal/middleware"
+	"ecommerce-platform/internal/models"
+	"ecommerce-platform/internal/utils"
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// GetShippingTemplates 获取本店运费模板
+func GetShippingTemplates(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetCurrentSession(r)
+
+	seller, err := models.GetSellerByUserID(session.UserID)
+	if err != nil {
+		utils.Forbidden(w, "您还不是商家")
+		return
+	}
+
+	templates, err := models.GetShippingTemplates(seller.ID)
+	if err != nil {
+		utils.InternalError(w, "获取失败")
+		return
+	}
+
+	utils.Success(w, templates)
+}
+
+// CreateShippingTemplate 创建运费模板
+func CreateShippingTemplate(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetCurrentSession(r)
+
+	seller, err := models.GetSellerByUserID(session.UserID)
+	if err != nil {
+		utils.Forbidden(w, "您还不是商家")
+		return
+	}
+
+	var template models.ShippingTemplate
+	if err := json.NewDecoder(r.Body).Decode(&template); err != nil {
+		utils.BadRequest(w, "请求参数错误")
+		return
+	}
+
+	if msg := validateShippingTemplate(&template); msg != "" {
+		utils.BadRequest(w, msg)
+		return
+	}
+	template.SellerID = seller.ID
+
+	id, err := models.CreateShippingTemplate(&template)
+	if err != nil {
+		utils.InternalError(w, "创建失败: "+err.Error())
+		return
+	}
+
+	created, err := models.GetShippingTemplateByID(id)
+	if err != nil {
+		utils.InternalError(w, "获取失败")
+		return
+	}
+
+	utils.Success(w, created)
+}
+
+// UpdateShippingTemplate 更新运费模板
+func UpdateShippingTemplate(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetCurrentSession(r)
+
+	var template models.ShippingTemplate
+	if err := json.NewDecoder(r.Body).Decode(&template); err != nil {
+		utils.BadRequest(w, "请求参数错误")
+		return
+	}
+
+	exist, err := models.GetShippingTemplateByID(template.ID)
+	if err != nil {
+		utils.NotFound(w, "运费模板不存在")
+		return
+	}
+
+	seller, _ := models.GetSellerByUserID(session.UserID)
+	if seller == nil || exist.SellerID != seller.ID {
+		utils.Forbidden(w, "没有权限")
+		return
+	}
+
+	if msg := validateShippingTemplate(&template); msg != "" {
+		utils.BadRequest(w, msg)
+		return
+	}
+	template.SellerID = exist.SellerID
+
+	if err := models.UpdateShippingTemplate(&template); err != nil {
+		utils.InternalError(w, "更新失败")
+		return
+	}
+
+	utils.SuccessMessage(w, "更新成功")
+}
+
+// DeleteShippingTemplate 删除运费模板
+func DeleteShippingTemplate(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetCurrentSession(r)
+
+	id := utils.ParseInt64(r.URL.Query().Get("id"), 0)
+	template, err := models.GetShippingTemplateByID(id)
+	if err != nil {
+		utils.NotFound(w, "运费模板不存在")
+		return
+	}
+
+	seller, _ := models.GetSellerByUserID(session.UserID)
+	if seller == nil || template.SellerID != seller.ID {
+		utils.Forbidden(w, "没有权限")
+		return
+	}
+
+	if err := models.DeleteShippingTemplate(id); err != nil {
+		utils.InternalError(w, "删除失败")
+		return
+	}
+
+	utils.SuccessMessage(w, "删除成功")
+}
+
+// validateShippingTemplate 校验运费模板参数，返回错误提示
+func validateShippingTemplate(template *models.ShippingTemplate) string {
+	if template.Name == "" {
+		return "模板名称不能为空"
+	}
+	if template.ChargeType == "" {
+		template.ChargeType = models.ChargeByItem
+	}
+	if template.ChargeType != models.ChargeByItem && template.ChargeType != models.ChargeByWeight {
+		return "计费方式错误"
+	}
+	if template.FreeThreshold < 0 {
+		return "包邮门槛不能为负数"
+	}
+	if template.IsDefault != 0 {
+		template.IsDefault = 1
+	}
+	if len(template.Rules) == 0 {
+		return "请至少设置一条运费规则"
+	}
+
+	seen := make(map[string]bool)
+	hasFallback := false
+	for _, rule := range template.Rules {
+		if rule.FirstUnit <= 0 || rule.AdditionalUnit <= 0 {
+			return "首件(首重)和续件(续重)必须大于0"
+		}
+		if rule.FirstFee < 0 || rule.AdditionalFee < 0 {
+			return "运费不能为负数"
+		}
+
+		var provinces []string
+		for _, province := range rule.Provinces {
+			province = strings.TrimSpace(province)
+			if province == "" {
+				continue
+			}
+			if strings.Contains(province, ",") {
+				return "省份名称无效"
+			}
+			if seen[province] {
+				return province + "重复设置了运费规则"
+			}
+			seen[province] = true
+			provinces = append(provinces, province)
+		}
+		rule.Provinces = provinces
+
+		if len(provinces) == 0 {
+			if hasFallback {
+				return "只能设置一条默认运费规则"
+			}
+			hasFallback = true
+		}
+	}
+	return ""
+}
+
+// QuoteShipping 运费试算。传 product_id 时计算单个商品的运费，否则计算购物车选中商品按商家拆分后的运费；
+// 收货省份取 province 参数，或登录用户的 address_id / 默认地址
+func QuoteShipping(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetCurrentSession(r)
+	query := r.URL.Query()
+
+	province := query.Get("province")
+	if addressID := utils.ParseInt64(query.Get("address_id"), 0); addressID > 0 {
+		address, err := models.GetAddressByID(addressID)
+		if err != nil || session == nil || address.UserID != session.UserID {
+			utils.BadRequest(w, "收货地址不存在")
+			return
+		}
+		province = address.Province
+	} else if province == "" && session != nil {
+		if address, err := models.GetDefaultAddress(session.UserID); err == nil && address != nil {
+			province = address.Province
+		}
+	}
+
+	var items []*models.CartItem
+	if productID := utils.ParseInt64(query.Get("product_id"), 0); productID > 0 {
+		product, err := models.GetProductByID(productID)
+		if err != nil {
+			utils.NotFound(w, "商品不存在")
+			return
+		}
+		quantity := utils.ParseInt(query.Get("quantity"), 1)
+		if quantity <= 0 {
+			utils.BadRequest(w, "数量必须大于0")
+			return
+		}
+
+		// 多规格商品按所选SKU的价格计算包邮门槛
+		if skuID := utils.ParseInt64(query.Get("sku_id"), 0); skuID > 0 {
+			sku, err := models.GetSKUByID(skuID)
+			if err != nil || sku.ProductID != productID {
+				utils.NotFound(w, "商品规格不存在")
+				return
+			}
+			product.Price = sku.Price
+		}
+		items = append(items, &models.CartItem{ProductID: productID, Quantity: quantity, Product: product})
+	} else {
+		if session == nil {
+			utils.BadRequest(w, "请选择要试算运费的商品")
+			return
+		}
+		cartItems, err := models.GetSelectedCartItems(session.UserID)
+		if err != nil || len(cartItems) == 0 {
+			utils.BadRequest(w, "请选择要购买的商品")
+			return
+		}
+		items = cartItems
+	}
+
+	type sellerFreight struct {
+		SellerID      int64   `json:"seller_id"`
+		SellerName    string  `json:"seller_name"`
+		FreightAmount float64 `json:"freight_amount"`
+		items         []*models.CartItem
+	}
+	sellers := make(map[int64]*sellerFreight)
+	var orders []*sellerFreight
+	for _, item := range items {
+		sf, ok := sellers[item.Product.SellerID]
+		if !ok {
+			sf = &sellerFreight{SellerID: item.Product.SellerID, SellerName: item.Product.SellerName}
+			sellers[item.Product.SellerID] = sf
+			orders = append(orders, sf)
+		}
+		sf.items = append(sf.items, item)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].SellerID < orders[j].SellerID })
+
+	var freightAmount float64
+	for _, sf := range orders {
+		freight, err := models.CalcFreight(sf.SellerID, province, sf.items)
+		if err != nil {
+			utils.BadRequest(w, err.Error())
+			return
+		}
+		sf.FreightAmount = freight
+		freightAmount += freight
+	}
+
+	utils.Success(w, map[string]interface{}{
+		"province":       province,
+		"orders":         orders,
+		"freight_amount": math.Round(freightAmount*100) / 100,
+	})
+}
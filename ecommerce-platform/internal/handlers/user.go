@@ -82,7 +82,11 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		models.CreateSeller(seller)
 	}
 
-	token := middleware.CreateSession(user)
+	token, err := middleware.CreateSession(user)
+	if err != nil {
+		utils.InternalError(w, "创建会话失败")
+		return
+	}
 
 	utils.Success(w, map[string]interface{}{
 		"token": token,
@@ -124,14 +128,18 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token := middleware.CreateSession(user)
+	token, err := middleware.CreateSession(user)
+	if err != nil {
+		utils.InternalError(w, "登录失败")
+		return
+	}
 
 	// 设置Cookie
 	http.SetCookie(w, &http.Cookie{
 		Name:     "token",
 		Value:    token,
 		Path:     "/",
-		MaxAge:   86400,
+		MaxAge:   int(middleware.SessionTTL.Seconds()),
 		HttpOnly: true,
 	})
 
@@ -163,9 +171,11 @@ func Login(w http.ResponseWriter, r *http.Request) {
 
 // Logout 用户登出
 func Logout(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie("token")
-	if err == nil {
-		middleware.DeleteSession(cookie.Value)
+	if token := middleware.GetToken(r); token != "" {
+		if err := middleware.DeleteSession(token); err != nil {
+			utils.InternalError(w, "退出失败")
+			return
+		}
 	}
 
 	http.SetCookie(w, &http.Cookie{
@@ -178,6 +188,25 @@ func Logout(w http.ResponseWriter, r *http.Request) {
 	utils.SuccessMessage(w, "退出成功")
 }
 
+// LogoutAll 退出所有设备上的登录
+func LogoutAll(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetCurrentSession(r)
+
+	if err := middleware.RevokeUserSessions(session.UserID); err != nil {
+		utils.InternalError(w, "退出失败")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:   "token",
+		Value:  "",
+		Path:   "/",
+		MaxAge: -1,
+	})
+
+	utils.SuccessMessage(w, "已退出所有设备")
+}
+
 // GetCurrentUser 获取当前用户信息
 func GetCurrentUser(w http.ResponseWriter, r *http.Request) {
 	session := middleware.GetCurrentSession(r)
@@ -349,6 +378,14 @@ func AdminUpdateUserStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 禁用后立即失效，不必等到会话过期
+	if req.Status != 1 {
+		if err := middleware.RevokeUserSessions(req.UserID); err != nil {
+			utils.InternalError(w, "注销用户会话失败")
+			return
+		}
+	}
+
 	utils.SuccessMessage(w, "更新成功")
 }
 
@@ -377,10 +414,24 @@ func AdminUpdateSellerStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	seller, err := models.GetSellerByID(req.SellerID)
+	if err != nil {
+		utils.NotFound(w, "商家不存在")
+		return
+	}
+
 	if err := models.UpdateSellerStatus(req.SellerID, req.Status); err != nil {
 		utils.InternalError(w, "更新失败")
 		return
 	}
 
+	// 商家状态变更后需要重新登录，使新的状态立即生效
+	if seller.Status != req.Status {
+		if err := middleware.RevokeUserSessions(seller.UserID); err != nil {
+			utils.InternalError(w, "注销商家会话失败")
+			return
+		}
+	}
+
 	utils.SuccessMessage(w, "更新成功")
 }
@@ -5,8 +5,8 @@ import (
 	"ecommerce-platform/internal/models"
 	"ecommerce-platform/internal/utils"
 	"net/http"
+	"log"
 	"strings"
-	"sync"
 	"time"
 )
 
@@ -19,14 +19,6 @@ type Session struct {
 	ExpiresAt time.Time
 }
 
-// SessionStore 会话存储
-var SessionStore = struct {
-	sync.RWMutex
-	sessions map[string]*Session
-}{
-	sessions: make(map[string]*Session),
-}
-
 // ContextKey 上下文键
 type ContextKey string
 
@@ -36,14 +28,14 @@ const (
 )
 
 // CreateSession 创建会话
-func CreateSession(user *models.User) string {
+func CreateSession(user *models.User) (string, error) {
 	token := utils.GenerateToken()
-	
+
 	session := &Session{
 		UserID:    user.ID,
 		Username:  user.Username,
 		Role:      user.Role,
-		ExpiresAt: time.Now().Add(24 * time.Hour),
+		ExpiresAt: time.Now().Add(SessionTTL),
 	}
 
 	// 如果是商家，获取商家ID
@@ -54,30 +46,54 @@ func CreateSession(user *models.User) string {
 		}
 	}
 
-	SessionStore.Lock()
-	SessionStore.sessions[token] = session
-	SessionStore.Unlock()
-
-	return token
+	if err := Sessions.Save(token, session); err != nil {
+		return "", err
+	}
+	return token, nil
 }
 
-// GetSession 获取会话
+// GetSession 获取会话，会话有效时延长有效期
 func GetSession(token string) *Session {
-	SessionStore.RLock()
-	session, ok := SessionStore.sessions[token]
-	SessionStore.RUnlock()
+	session, err := Sessions.Get(token)
+	if err != nil {
+		log.Println("读取会话失败:", err)
+		return nil
+	}
 
-	if !ok || session.ExpiresAt.Before(time.Now()) {
+	now := time.Now()
+	if session == nil || session.ExpiresAt.Before(now) {
 		return nil
 	}
+
+	if session.ExpiresAt.Sub(now) < SessionTTL-sessionRefreshInterval {
+		session.ExpiresAt = now.Add(SessionTTL)
+		if err := Sessions.Refresh(token, session.ExpiresAt); err != nil {
+			log.Println("延长会话有效期失败:", err)
+		}
+	}
 	return session
 }
 
 // DeleteSession 删除会话
-func DeleteSession(token string) {
-	SessionStore.Lock()
-	delete(SessionStore.sessions, token)
-	SessionStore.Unlock()
+func DeleteSession(token string) error {
+	return Sessions.Delete(token)
+}
+
+// GetToken 从请求中获取登录令牌，优先使用 Authorization 头，其次使用 Cookie
+func GetToken(r *http.Request) string {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader != "" {
+		parts := strings.Split(authHeader, " ")
+		if len(parts) == 2 && parts[0] == "Bearer" {
+			return parts[1]
+		}
+	}
+
+	cookie, err := r.Cookie("token")
+	if err == nil {
+		return cookie.Value
+	}
+	return ""
 }
 
 // CORS 跨域中间件
@@ -109,23 +125,7 @@ func Logger(next http.Handler) http.Handler {
 // Auth 认证中间件
 func Auth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// 从Header获取Token
-		authHeader := r.Header.Get("Authorization")
-		var token string
-		if authHeader != "" {
-			parts := strings.Split(authHeader, " ")
-			if len(parts) == 2 && parts[0] == "Bearer" {
-				token = parts[1]
-			}
-		}
-
-		// 也尝试从Cookie获取
-		if token == "" {
-			cookie, err := r.Cookie("token")
-			if err == nil {
-				token = cookie.Value
-			}
-		}
+		token := GetToken(r)
 
 		if token == "" {
 			utils.Unauthorized(w, "请先登录")
@@ -147,23 +147,7 @@ func Auth(next http.HandlerFunc) http.HandlerFunc {
 // OptionalAuth 可选认证中间件（不强制登录）
 func OptionalAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// 从Header获取Token
-		authHeader := r.Header.Get("Authorization")
-		var token string
-		if authHeader != "" {
-			parts := strings.Split(authHeader, " ")
-			if len(parts) == 2 && parts[0] == "Bearer" {
-				token = parts[1]
-			}
-		}
-
-		// 也尝试从Cookie获取
-		if token == "" {
-			cookie, err := r.Cookie("token")
-			if err == nil {
-				token = cookie.Value
-			}
-		}
+		token := GetToken(r)
 
 		if token != "" {
 			session := GetSession(token)
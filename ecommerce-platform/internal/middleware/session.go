@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"ecommerce-platform/internal/models"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+)
+
+// SessionTTL 会话有效期，每次访问后重新计算（滑动过期）
+const SessionTTL = 24 * time.Hour
+
+// sessionRefreshInterval 距上次延长有效期超过该时长才再次延长，避免每个请求都写数据库
+const sessionRefreshInterval = 10 * time.Minute
+
+// SessionStore 会话存储
+type SessionStore interface {
+	// Save 保存会话
+	Save(token string, session *Session) error
+	// Get 获取会话，不存在时返回 nil，不检查是否过期
+	Get(token string) (*Session, error)
+	// Refresh 延长会话有效期
+	Refresh(token string, expiresAt time.Time) error
+	// Delete 删除会话
+	Delete(token string) error
+	// DeleteUser 删除用户的所有会话
+	DeleteUser(userID int64) error
+	// DeleteExpired 删除已过期的会话，返回删除的数量
+	DeleteExpired() (int64, error)
+}
+
+// Sessions 当前使用的会话存储，默认保存在数据库中，服务重启后会话仍然有效
+var Sessions SessionStore = DBSessionStore{}
+
+// hashToken 数据库中只保存令牌的哈希值，数据库泄露时无法直接用于登录
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// DBSessionStore 基于数据库 sessions 表的会话存储
+type DBSessionStore struct{}
+
+func (DBSessionStore) Save(token string, session *Session) error {
+	return models.CreateUserSession(&models.UserSession{
+		TokenHash: hashToken(token),
+		UserID:    session.UserID,
+		Username:  session.Username,
+		Role:      session.Role,
+		SellerID:  session.SellerID,
+		ExpiresAt: session.ExpiresAt,
+	})
+}
+
+func (DBSessionStore) Get(token string) (*Session, error) {
+	s, err := models.GetUserSession(hashToken(token))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Session{
+		UserID:    s.UserID,
+		Username:  s.Username,
+		Role:      s.Role,
+		SellerID:  s.SellerID,
+		ExpiresAt: s.ExpiresAt,
+	}, nil
+}
+
+func (DBSessionStore) Refresh(token string, expiresAt time.Time) error {
+	return models.RefreshUserSession(hashToken(token), expiresAt)
+}
+
+func (DBSessionStore) Delete(token string) error {
+	return models.DeleteUserSession(hashToken(token))
+}
+
+func (DBSessionStore) DeleteUser(userID int64) error {
+	_, err := models.DeleteUserSessions(userID)
+	return err
+}
+
+func (DBSessionStore) DeleteExpired() (int64, error) {
+	return models.DeleteExpiredSessions()
+}
+
+// MemorySessionStore 进程内的会话存储，服务重启后会话失效，只适用于单实例部署和测试
+type MemorySessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*Session
+}
+
+// NewMemorySessionStore 创建进程内会话存储
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]*Session)}
+}
+
+func (m *MemorySessionStore) Save(token string, session *Session) error {
+	s := *session
+	m.mu.Lock()
+	m.sessions[token] = &s
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemorySessionStore) Get(token string) (*Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	session, ok := m.sessions[token]
+	if !ok {
+		return nil, nil
+	}
+	s := *session
+	return &s, nil
+}
+
+func (m *MemorySessionStore) Refresh(token string, expiresAt time.Time) error {
+	m.mu.Lock()
+	if session, ok := m.sessions[token]; ok {
+		session.ExpiresAt = expiresAt
+	}
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemorySessionStore) Delete(token string) error {
+	m.mu.Lock()
+	delete(m.sessions, token)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemorySessionStore) DeleteUser(userID int64) error {
+	m.mu.Lock()
+	for token, session := range m.sessions {
+		if session.UserID == userID {
+			delete(m.sessions, token)
+		}
+	}
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemorySessionStore) DeleteExpired() (int64, error) {
+	now := time.Now()
+	var count int64
+	m.mu.Lock()
+	for token, session := range m.sessions {
+		if session.ExpiresAt.Before(now) {
+			delete(m.sessions, token)
+			count++
+		}
+	}
+	m.mu.Unlock()
+	return count, nil
+}
+
+// StartSessionJanitor 在后台定期清理过期会话，返回的函数用于停止清理并等待正在执行的清理结束
+func StartSessionJanitor(interval time.Duration) (stop func()) {
+	quit := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if count, err := Sessions.DeleteExpired(); err != nil {
+				log.Println("清理过期会话失败:", err)
+			} else if count > 0 {
+				log.Printf("清理 %d 个过期会话", count)
+			}
+
+			select {
+			case <-quit:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(quit) })
+		<-done
+	}
+}
+
+// RevokeUserSessions 使用户的所有会话立即失效，用于退出所有设备、禁用用户或商家状态变更
+func RevokeUserSessions(userID int64) error {
+	return Sessions.DeleteUser(userID)
+}
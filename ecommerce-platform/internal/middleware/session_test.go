@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"ecommerce-platform/internal/config"
+	"ecommerce-platform/internal/models"
+	"ecommerce-platform/internal/testutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func setupSessionTest(t *testing.T) *models.User {
+	t.Helper()
+	testutil.OpenDB(t)
+
+	user, err := models.GetUserByID(testutil.SeedBuyer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func authStatus(token string) int {
+	r := httptest.NewRequest(http.MethodGet, "/api/auth/user", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	Auth(func(w http.ResponseWriter, r *http.Request) {})(w, r)
+	if w.Body.Len() == 0 {
+		return http.StatusOK
+	}
+	return http.StatusUnauthorized
+}
+
+func TestDBSessionStore(t *testing.T) {
+	user := setupSessionTest(t)
+
+	token, err := CreateSession(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authStatus(token) != http.StatusOK {
+		t.Fatal("新建的会话应有效")
+	}
+
+	// 数据库中只保存令牌的哈希值
+	var count int
+	config.DB.QueryRow(`SELECT COUNT(*) FROM sessions WHERE token_hash = ?`, token).Scan(&count)
+	if count != 0 {
+		t.Error("数据库中不应保存令牌原文")
+	}
+
+	// 剩余有效期不足时访问会延长有效期
+	Sessions.Refresh(token, time.Now().Add(time.Hour))
+	if s := GetSession(token); s == nil || time.Until(s.ExpiresAt) < SessionTTL-time.Minute {
+		t.Error("访问后会话有效期应延长")
+	}
+
+	// 过期的会话无效，并被清理
+	Sessions.Refresh(token, time.Now().Add(-time.Minute))
+	if authStatus(token) == http.StatusOK {
+		t.Error("过期的会话应无效")
+	}
+	if n, err := Sessions.DeleteExpired(); err != nil || n != 1 {
+		t.Errorf("应清理 1 个过期会话，实际 %d %v", n, err)
+	}
+}
+
+func TestRevokeUserSessions(t *testing.T) {
+	user := setupSessionTest(t)
+
+	first, _ := CreateSession(user)
+	second, _ := CreateSession(user)
+
+	if err := DeleteSession(first); err != nil {
+		t.Fatal(err)
+	}
+	if authStatus(first) == http.StatusOK || authStatus(second) != http.StatusOK {
+		t.Fatal("退出登录只应使当前会话失效")
+	}
+
+	third, _ := CreateSession(user)
+	if err := RevokeUserSessions(user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if authStatus(second) == http.StatusOK || authStatus(third) == http.StatusOK {
+		t.Error("注销用户会话后所有会话应失效")
+	}
+}
@@ -0,0 +1,378 @@
+package models
+
+import (
+	"database/sql"
+	"ecommerce-platform/internal/config"
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+// 售后类型
+const (
+	AfterSaleRefund = "refund" // 仅退款
+	AfterSaleReturn = "return" // 退货退款
+)
+
+// 售后单状态
+const (
+	AfterSalePending     = 0 // 待商家处理
+	AfterSaleAwaitReturn = 1 // 商家已同意，待买家退货
+	AfterSaleReturned    = 2 // 买家已退货，待商家收货
+	AfterSaleRefunded    = 3 // 已退款
+	AfterSaleRejected    = 4 // 商家已拒绝，买家可申请平台介入
+	AfterSaleArbitration = 5 // 平台介入中
+	AfterSaleClosed      = 6 // 已关闭（买家撤销或平台驳回）
+	AfterSaleRefunding   = 7 // 退款中，已确定退款但支付渠道尚未退款成功
+)
+
+// openAfterSaleStatuses 进行中的售后单状态，同一订单商品同时只能有一个
+const openAfterSaleStatuses = `(0, 1, 2, 4, 5, 7)`
+
+var (
+	// ErrAfterSaleStatus 售后单当前状态不允许该操作，通常是已被其他请求处理
+	ErrAfterSaleStatus = errors.New("售后单状态不允许该操作")
+	// ErrAfterSaleExists 订单商品已有进行中的售后单
+	ErrAfterSaleExists = errors.New("该商品已有进行中的售后申请")
+)
+
+// AfterSale 售后单，针对订单中的一个商品申请仅退款或退货退款
+type AfterSale struct {
+	ID                int64      `json:"id"`
+	AfterSaleNo       string     `json:"after_sale_no"`
+	OrderID           int64      `json:"order_id"`
+	OrderItemID       int64      `json:"order_item_id"`
+	UserID            int64      `json:"user_id"`
+	SellerID          int64      `json:"seller_id"`
+	Type              string     `json:"type"`
+	Reason            string     `json:"reason"`
+	Description       string     `json:"description"`
+	Images            string     `json:"images"` // 凭证图片，多张用逗号分隔
+	Quantity          int        `json:"quantity"`
+	Amount            float64    `json:"amount"`
+	Status            int        `json:"status"`
+	RejectReason      string     `json:"reject_reason"`
+	ReturnTrackingNo  string     `json:"return_tracking_no"`
+	ArbitrationReason string     `json:"arbitration_reason"`
+	AdminRemark       string     `json:"admin_remark"`
+	RefundNo          string     `json:"refund_no"`
+	FinishedAt        *time.Time `json:"finished_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	// 关联信息
+	OrderNo      string `json:"order_no,omitempty"`
+	ProductName  string `json:"product_name,omitempty"`
+	ProductImage string `json:"product_image,omitempty"`
+	SKUSpecs     string `json:"sku_specs,omitempty"`
+	Username     string `json:"username,omitempty"`
+	SellerName   string `json:"seller_name,omitempty"`
+}
+
+// RefundableAmount 订单商品退 quantity 件时最多可退的金额：按商品金额占比分摊订单优惠。
+// includeFreight 为 true 时加上订单运费，用于未发货订单全部退款的情况
+func (o *Order) RefundableAmount(item *OrderItem, quantity int, includeFreight bool) float64 {
+	amount := item.TotalPrice * float64(quantity) / float64(item.Quantity)
+	if o.TotalAmount > 0 {
+		amount *= (o.TotalAmount - o.DiscountAmount) / o.TotalAmount
+	}
+	if includeFreight {
+		amount += o.FreightAmount
+	}
+	return math.Floor(amount*100) / 100
+}
+
+// RefundsAllRemaining 判断订单商品 itemID 退 quantity 件后，订单中所有商品是否都已退完。
+// 运费只在退掉整个订单剩余商品的那次售后中退还，避免按商品重复退运费
+func (o *Order) RefundsAllRemaining(itemID int64, quantity int) bool {
+	for _, item := range o.Items {
+		remaining := item.Quantity - item.RefundedQuantity
+		if item.ID == itemID {
+			remaining -= quantity
+		}
+		if remaining > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// GetOrderItemByID 通过ID获取订单商品
+func GetOrderItemByID(id int64) (*OrderItem, error) {
+	item := &OrderItem{}
+	err := config.DB.QueryRow(`
+		SELECT id, order_id, product_id, product_name, product_image, COALESCE(sku_id, 0), COALESCE(sku_specs, ''),
+			price, quantity, total_price, COALESCE(refunded_quantity, 0), created_at
+		FROM order_items WHERE id = ?
+	`, id).Scan(&item.ID, &item.OrderID, &item.ProductID, &item.ProductName, &item.ProductImage, &item.SKUID,
+		&item.SKUSpecs, &item.Price, &item.Quantity, &item.TotalPrice, &item.RefundedQuantity, &item.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// CreateAfterSale 创建售后单，同一订单商品已有进行中的售后单时返回 ErrAfterSaleExists
+func CreateAfterSale(as *AfterSale) (int64, error) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var open int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM after_sales WHERE order_item_id = ? AND status IN `+openAfterSaleStatuses,
+		as.OrderItemID).Scan(&open)
+	if err != nil {
+		return 0, err
+	}
+	if open > 0 {
+		return 0, ErrAfterSaleExists
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO after_sales (after_sale_no, order_id, order_item_id, user_id, seller_id, type, reason,
+			description, images, quantity, amount, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, as.AfterSaleNo, as.OrderID, as.OrderItemID, as.UserID, as.SellerID, as.Type, as.Reason,
+		as.Description, as.Images, as.Quantity, as.Amount, AfterSalePending)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+const afterSaleQuery = `
+	SELECT a.id, a.after_sale_no, a.order_id, a.order_item_id, a.user_id, a.seller_id, a.type, a.reason,
+		COALESCE(a.description, ''), COALESCE(a.images, ''), a.quantity, a.amount, a.status,
+		COALESCE(a.reject_reason, ''), COALESCE(a.return_tracking_no, ''), COALESCE(a.arbitration_reason, ''),
+		COALESCE(a.admin_remark, ''), COALESCE(a.refund_no, ''), a.finished_at, a.created_at, a.updated_at,
+		o.order_no, i.product_name, COALESCE(i.product_image, ''), COALESCE(i.sku_specs, ''),
+		COALESCE(u.username, ''), COALESCE(s.shop_name, '')
+	FROM after_sales a
+	JOIN orders o ON a.order_id = o.id
+	JOIN order_items i ON a.order_item_id = i.id
+	LEFT JOIN users u ON a.user_id = u.id
+	LEFT JOIN sellers s ON a.seller_id = s.id`
+
+func scanAfterSale(scanner interface{ Scan(...interface{}) error }) (*AfterSale, error) {
+	as := &AfterSale{}
+	var finishedAt sql.NullTime
+	err := scanner.Scan(&as.ID, &as.AfterSaleNo, &as.OrderID, &as.OrderItemID, &as.UserID, &as.SellerID, &as.Type,
+		&as.Reason, &as.Description, &as.Images, &as.Quantity, &as.Amount, &as.Status,
+		&as.RejectReason, &as.ReturnTrackingNo, &as.ArbitrationReason,
+		&as.AdminRemark, &as.RefundNo, &finishedAt, &as.CreatedAt, &as.UpdatedAt,
+		&as.OrderNo, &as.ProductName, &as.ProductImage, &as.SKUSpecs, &as.Username, &as.SellerName)
+	if err != nil {
+		return nil, err
+	}
+	if finishedAt.Valid {
+		as.FinishedAt = &finishedAt.Time
+	}
+	return as, nil
+}
+
+// GetAfterSaleByID 通过ID获取售后单
+func GetAfterSaleByID(id int64) (*AfterSale, error) {
+	return scanAfterSale(config.DB.QueryRow(afterSaleQuery+` WHERE a.id = ?`, id))
+}
+
+// GetAfterSales 获取售后单列表，userID、sellerID 为 0 时不筛选，status 为 -1 时返回全部
+func GetAfterSales(page, pageSize int, userID, sellerID int64, status int) ([]*AfterSale, int, error) {
+	conditions := []string{"1=1"}
+	args := []interface{}{}
+
+	if userID > 0 {
+		conditions = append(conditions, "a.user_id = ?")
+		args = append(args, userID)
+	}
+	if sellerID > 0 {
+		conditions = append(conditions, "a.seller_id = ?")
+		args = append(args, sellerID)
+	}
+	if status >= 0 {
+		conditions = append(conditions, "a.status = ?")
+		args = append(args, status)
+	}
+	whereClause := strings.Join(conditions, " AND ")
+
+	var total int
+	err := config.DB.QueryRow(`SELECT COUNT(*) FROM after_sales a WHERE `+whereClause, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, pageSize, (page-1)*pageSize)
+	rows, err := config.DB.Query(afterSaleQuery+` WHERE `+whereClause+` ORDER BY a.created_at DESC, a.id DESC LIMIT ? OFFSET ?`, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var list []*AfterSale
+	for rows.Next() {
+		as, err := scanAfterSale(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		list = append(list, as)
+	}
+	return list, total, nil
+}
+
+// updateAfterSale 售后单状态从 from 中的某个状态变为 to，并更新 set 中的字段；
+// 状态已被其他请求修改时返回 ErrAfterSaleStatus
+func updateAfterSale(id int64, from []int, to int, set string, args ...interface{}) error {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(from)), ", ")
+	query := `UPDATE after_sales SET status = ?, updated_at = CURRENT_TIMESTAMP`
+	if set != "" {
+		query += `, ` + set
+	}
+	query += ` WHERE id = ? AND status IN (` + placeholders + `)`
+
+	params := append([]interface{}{to}, args...)
+	params = append(params, id)
+	for _, status := range from {
+		params = append(params, status)
+	}
+
+	result, err := config.DB.Exec(query, params...)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrAfterSaleStatus
+	}
+	return nil
+}
+
+// ApproveReturn 同意退货，等待买家寄回商品
+func ApproveReturn(id int64, from int) error {
+	return updateAfterSale(id, []int{from}, AfterSaleAwaitReturn, "")
+}
+
+// RejectAfterSale 商家拒绝售后申请，可在待处理或已退货（如退回商品有问题）时拒绝
+func RejectAfterSale(id int64, reason string) error {
+	return updateAfterSale(id, []int{AfterSalePending, AfterSaleReturned}, AfterSaleRejected, "reject_reason = ?", reason)
+}
+
+// SubmitReturn 买家填写退货物流单号
+func SubmitReturn(id int64, trackingNo string) error {
+	return updateAfterSale(id, []int{AfterSaleAwaitReturn}, AfterSaleReturned, "return_tracking_no = ?", trackingNo)
+}
+
+// CancelAfterSale 买家撤销售后申请
+func CancelAfterSale(id int64) error {
+	return updateAfterSale(id, []int{AfterSalePending, AfterSaleAwaitReturn, AfterSaleRejected}, AfterSaleClosed,
+		"finished_at = CURRENT_TIMESTAMP")
+}
+
+// RequestArbitration 商家拒绝后买家申请平台介入
+func RequestArbitration(id int64, reason string) error {
+	return updateAfterSale(id, []int{AfterSaleRejected}, AfterSaleArbitration, "arbitration_reason = ?", reason)
+}
+
+// CloseArbitration 平台驳回售后申请
+func CloseArbitration(id int64, remark string) error {
+	return updateAfterSale(id, []int{AfterSaleArbitration}, AfterSaleClosed,
+		"admin_remark = ?, finished_at = CURRENT_TIMESTAMP", remark)
+}
+
+// StartAfterSaleRefund 调用支付渠道退款前将售后单置为退款中并记录退款流水号，
+// 并发的重复处理请求只有一个能进入退款
+func StartAfterSaleRefund(id int64, from int, refundNo string) error {
+	return updateAfterSale(id, []int{from}, AfterSaleRefunding, "refund_no = ?", refundNo)
+}
+
+// SetAfterSaleAdminRemark 记录平台处理意见
+func SetAfterSaleAdminRemark(id int64, remark string) error {
+	_, err := config.DB.Exec(`UPDATE after_sales SET admin_remark = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, remark, id)
+	return err
+}
+
+// CompleteAfterSale 售后退款完成：退款中的售后单标记为已退款，累计订单商品的退款数量和支付记录的退款金额，
+// 恢复商品销量，restock 为 true（退货或未发货）时同时恢复库存。订单商品全部退款后订单变为已退款。
+// paymentID 为 0 表示订单没有线上支付记录
+func CompleteAfterSale(as *AfterSale, paymentID int64, restock bool) error {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE after_sales SET status = ?, finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?
+	`, AfterSaleRefunded, as.ID, AfterSaleRefunding)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrAfterSaleStatus
+	}
+
+	result, err = tx.Exec(`
+		UPDATE order_items SET refunded_quantity = refunded_quantity + ?
+		WHERE id = ? AND refunded_quantity + ? <= quantity
+	`, as.Quantity, as.OrderItemID, as.Quantity)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errors.New("退款数量超过购买数量")
+	}
+
+	item, err := getOrderItemTx(tx, as.OrderItemID)
+	if err != nil {
+		return err
+	}
+
+	stock := 0
+	if restock {
+		stock = as.Quantity
+	}
+	_, err = tx.Exec(`UPDATE products SET stock = stock + ?, sales = MAX(sales - ?, 0) WHERE id = ?`,
+		stock, as.Quantity, item.ProductID)
+	if err != nil {
+		return err
+	}
+	if item.SKUID > 0 {
+		_, err = tx.Exec(`UPDATE product_skus SET stock = stock + ?, sales = MAX(sales - ?, 0) WHERE id = ?`,
+			stock, as.Quantity, item.SKUID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if paymentID > 0 {
+		if err = addPaymentRefundTx(tx, paymentID, as.Amount); err != nil {
+			return err
+		}
+	}
+
+	// 所有商品都已退款时订单变为已退款
+	_, err = tx.Exec(`
+		UPDATE orders SET status = 6, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status IN (1, 2, 3)
+			AND NOT EXISTS (SELECT 1 FROM order_items WHERE order_id = orders.id AND refunded_quantity < quantity)
+	`, as.OrderID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func getOrderItemTx(tx *sql.Tx, id int64) (*OrderItem, error) {
+	item := &OrderItem{}
+	err := tx.QueryRow(`SELECT id, product_id, COALESCE(sku_id, 0) FROM order_items WHERE id = ?`, id).
+		Scan(&item.ID, &item.ProductID, &item.SKUID)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
@@ -9,6 +9,8 @@ type CartItem struct {
 	ID        int64     `json:"id"`
 	UserID    int64     `json:"user_id"`
 	ProductID int64     `json:"product_id"`
+	SKUID     int64     `json:"sku_id"`
+	SKUSpecs  string    `json:"sku_specs"`
 	Quantity  int       `json:"quantity"`
 	Selected  int       `json:"selected"`
 	CreatedAt time.Time `json:"created_at"`
@@ -17,11 +19,11 @@ type CartItem struct {
 	Product *Product `json:"product,omitempty"`
 }
 
-// AddToCart 添加商品到购物车
-func AddToCart(userID, productID int64, quantity int) error {
+// AddToCart 添加商品到购物车，同一商品的不同SKU分别记录
+func AddToCart(userID, productID, skuID int64, quantity int) error {
 	// 检查是否已存在
 	var existID int64
-	err := config.DB.QueryRow(`SELECT id FROM cart_items WHERE user_id = ? AND product_id = ?`, userID, productID).Scan(&existID)
+	err := config.DB.QueryRow(`SELECT id FROM cart_items WHERE user_id = ? AND product_id = ? AND sku_id = ?`, userID, productID, skuID).Scan(&existID)
 	
 	if err == nil {
 		// 已存在，更新数量
@@ -34,19 +36,21 @@ func AddToCart(userID, productID int64, quantity int) error {
 	
 	// 不存在，新增
 	_, err = config.DB.Exec(`
-		INSERT INTO cart_items (user_id, product_id, quantity, selected)
-		VALUES (?, ?, ?, 1)
-	`, userID, productID, quantity)
+		INSERT INTO cart_items (user_id, product_id, sku_id, quantity, selected)
+		VALUES (?, ?, ?, ?, 1)
+	`, userID, productID, skuID, quantity)
 	return err
 }
 
 // GetCartItems 获取用户购物车
 func GetCartItems(userID int64) ([]*CartItem, error) {
 	rows, err := config.DB.Query(`
-		SELECT ci.id, ci.user_id, ci.product_id, ci.quantity, ci.selected, ci.created_at, ci.updated_at,
-			p.name, p.price, p.original_price, p.stock, p.images, s.shop_name
+		SELECT ci.id, ci.user_id, ci.product_id, ci.sku_id, ci.quantity, ci.selected, ci.created_at, ci.updated_at,
+			p.name, COALESCE(k.price, p.price), COALESCE(k.original_price, p.original_price), COALESCE(k.stock, p.stock),
+			CASE WHEN COALESCE(k.image, '') <> '' THEN k.image ELSE p.images END, s.shop_name, COALESCE(k.specs, '')
 		FROM cart_items ci
 		JOIN products p ON ci.product_id = p.id
+		LEFT JOIN product_skus k ON ci.sku_id = k.id
 		LEFT JOIN sellers s ON p.seller_id = s.id
 		WHERE ci.user_id = ?
 		ORDER BY ci.created_at DESC
@@ -59,13 +63,17 @@ func GetCartItems(userID int64) ([]*CartItem, error) {
 	var items []*CartItem
 	for rows.Next() {
 		item := &CartItem{Product: &Product{}}
-		err := rows.Scan(&item.ID, &item.UserID, &item.ProductID, &item.Quantity, &item.Selected,
+		var specs string
+		err := rows.Scan(&item.ID, &item.UserID, &item.ProductID, &item.SKUID, &item.Quantity, &item.Selected,
 			&item.CreatedAt, &item.UpdatedAt, &item.Product.Name, &item.Product.Price,
-			&item.Product.OriginalPrice, &item.Product.Stock, &item.Product.Images, &item.Product.SellerName)
+			&item.Product.OriginalPrice, &item.Product.Stock, &item.Product.Images, &item.Product.SellerName, &specs)
 		if err != nil {
 			return nil, err
 		}
 		item.Product.ID = item.ProductID
+		if item.SKUID > 0 {
+			item.SKUSpecs = formatSpecs(parseSpecs(specs))
+		}
 		items = append(items, item)
 	}
 	return items, nil
@@ -122,10 +130,13 @@ func ClearSelectedCart(userID int64) error {
 // GetSelectedCartItems 获取用户选中的购物车商品
 func GetSelectedCartItems(userID int64) ([]*CartItem, error) {
 	rows, err := config.DB.Query(`
-		SELECT ci.id, ci.user_id, ci.product_id, ci.quantity, ci.selected, ci.created_at, ci.updated_at,
-			p.name, p.price, p.original_price, p.stock, p.images, p.seller_id, s.shop_name
+		SELECT ci.id, ci.user_id, ci.product_id, ci.sku_id, ci.quantity, ci.selected, ci.created_at, ci.updated_at,
+			p.name, COALESCE(k.price, p.price), COALESCE(k.original_price, p.original_price), COALESCE(k.stock, p.stock),
+			CASE WHEN COALESCE(k.image, '') <> '' THEN k.image ELSE p.images END, p.seller_id, COALESCE(p.category_id, 0),
+			COALESCE(p.weight, 0), COALESCE(p.shipping_template_id, 0), s.shop_name, COALESCE(k.specs, '')
 		FROM cart_items ci
 		JOIN products p ON ci.product_id = p.id
+		LEFT JOIN product_skus k ON ci.sku_id = k.id
 		LEFT JOIN sellers s ON p.seller_id = s.id
 		WHERE ci.user_id = ? AND ci.selected = 1
 		ORDER BY ci.created_at DESC
@@ -138,14 +149,19 @@ func GetSelectedCartItems(userID int64) ([]*CartItem, error) {
 	var items []*CartItem
 	for rows.Next() {
 		item := &CartItem{Product: &Product{}}
-		err := rows.Scan(&item.ID, &item.UserID, &item.ProductID, &item.Quantity, &item.Selected,
+		var specs string
+		err := rows.Scan(&item.ID, &item.UserID, &item.ProductID, &item.SKUID, &item.Quantity, &item.Selected,
 			&item.CreatedAt, &item.UpdatedAt, &item.Product.Name, &item.Product.Price,
 			&item.Product.OriginalPrice, &item.Product.Stock, &item.Product.Images,
-			&item.Product.SellerID, &item.Product.SellerName)
+			&item.Product.SellerID, &item.Product.CategoryID, &item.Product.Weight, &item.Product.ShippingTemplateID,
+			&item.Product.SellerName, &specs)
 		if err != nil {
 			return nil, err
 		}
 		item.Product.ID = item.ProductID
+		if item.SKUID > 0 {
+			item.SKUSpecs = formatSpecs(parseSpecs(specs))
+		}
 		items = append(items, item)
 	}
 	return items, nil
@@ -0,0 +1,293 @@
+package models
+
+import (
+	"database/sql"
+	"ecommerce-platform/internal/config"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// 优惠券类型
+const (
+	CouponFixed     = "fixed"     // 立减：直接减免固定金额
+	CouponPercent   = "percent"   // 折扣：按比例减免，可设最高优惠
+	CouponThreshold = "threshold" // 满减：满 min_amount 减固定金额
+)
+
+// ErrCouponUnavailable 优惠券已使用、已过期或不属于当前用户
+var ErrCouponUnavailable = errors.New("优惠券不可用")
+
+type Coupon struct {
+	ID           int64     `json:"id"`
+	SellerID     int64     `json:"seller_id"` // 0 为平台券，全场通用
+	Name         string    `json:"name"`
+	Type         string    `json:"type"`
+	Value        float64   `json:"value"`        // 立减/满减为金额，折扣为优惠百分比（如 20 表示八折）
+	MinAmount    float64   `json:"min_amount"`   // 使用门槛，0 为无门槛
+	MaxDiscount  float64   `json:"max_discount"` // 折扣券最高优惠，0 为不限
+	CategoryID   int64     `json:"category_id"`  // 限定分类，0 为不限
+	TotalCount   int       `json:"total_count"`  // 发行量，0 为不限
+	ClaimedCount int       `json:"claimed_count"`
+	PerUserLimit int       `json:"per_user_limit"` // 每人限领张数
+	StartTime    time.Time `json:"start_time"`
+	EndTime      time.Time `json:"end_time"`
+	Status       int       `json:"status"` // 1启用 0停用
+	CreatedAt    time.Time `json:"created_at"`
+	// 关联信息
+	SellerName   string `json:"seller_name,omitempty"`
+	CategoryName string `json:"category_name,omitempty"`
+}
+
+// UserCoupon 用户领取到券包中的优惠券
+type UserCoupon struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	CouponID  int64      `json:"coupon_id"`
+	Status    int        `json:"status"` // 0未使用 1已使用
+	OrderID   int64      `json:"order_id"`
+	ClaimedAt time.Time  `json:"claimed_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	Coupon    *Coupon    `json:"coupon,omitempty"`
+}
+
+// Available 优惠券是否启用且在有效期内
+func (c *Coupon) Available(now time.Time) bool {
+	return c.Status == 1 && !now.Before(c.StartTime) && now.Before(c.EndTime)
+}
+
+// Discount 计算优惠券对某个商家订单商品的优惠金额。
+// 平台券适用于所有商家，商家券只适用于本店；限定分类时只按该分类商品金额计算门槛和折扣
+func (c *Coupon) Discount(sellerID int64, items []*CartItem) (float64, error) {
+	if c.SellerID > 0 && c.SellerID != sellerID {
+		return 0, fmt.Errorf("优惠券%s不适用于该店铺", c.Name)
+	}
+
+	var eligible float64
+	for _, item := range items {
+		if c.CategoryID == 0 || item.Product.CategoryID == c.CategoryID {
+			eligible += item.Product.Price * float64(item.Quantity)
+		}
+	}
+	if eligible <= 0 {
+		return 0, fmt.Errorf("没有适用优惠券%s的商品", c.Name)
+	}
+	if eligible < c.MinAmount {
+		return 0, fmt.Errorf("未满足优惠券%s的使用门槛", c.Name)
+	}
+
+	discount := c.Value
+	if c.Type == CouponPercent {
+		discount = eligible * c.Value / 100
+		if c.MaxDiscount > 0 && discount > c.MaxDiscount {
+			discount = c.MaxDiscount
+		}
+	}
+	if discount > eligible {
+		discount = eligible
+	}
+	return math.Round(discount*100) / 100, nil
+}
+
+const couponColumns = `c.id, c.seller_id, c.name, c.type, c.value, c.min_amount, c.max_discount, c.category_id,
+	c.total_count, c.claimed_count, c.per_user_limit, c.start_time, c.end_time, c.status, c.created_at,
+	COALESCE(s.shop_name, ''), COALESCE(cat.name, '')`
+
+const couponJoins = ` FROM coupons c
+	LEFT JOIN sellers s ON c.seller_id = s.id
+	LEFT JOIN categories cat ON c.category_id = cat.id`
+
+func scanCoupon(scanner interface{ Scan(...interface{}) error }, c *Coupon) error {
+	return scanner.Scan(&c.ID, &c.SellerID, &c.Name, &c.Type, &c.Value, &c.MinAmount, &c.MaxDiscount, &c.CategoryID,
+		&c.TotalCount, &c.ClaimedCount, &c.PerUserLimit, &c.StartTime, &c.EndTime, &c.Status, &c.CreatedAt,
+		&c.SellerName, &c.CategoryName)
+}
+
+// CreateCoupon 创建优惠券
+func CreateCoupon(coupon *Coupon) (int64, error) {
+	result, err := config.DB.Exec(`
+		INSERT INTO coupons (seller_id, name, type, value, min_amount, max_discount, category_id,
+			total_count, per_user_limit, start_time, end_time, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, coupon.SellerID, coupon.Name, coupon.Type, coupon.Value, coupon.MinAmount, coupon.MaxDiscount,
+		coupon.CategoryID, coupon.TotalCount, coupon.PerUserLimit, coupon.StartTime, coupon.EndTime, coupon.Status)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetCouponByID 通过ID获取优惠券
+func GetCouponByID(id int64) (*Coupon, error) {
+	coupon := &Coupon{}
+	err := scanCoupon(config.DB.QueryRow(`SELECT `+couponColumns+couponJoins+` WHERE c.id = ?`, id), coupon)
+	if err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+// GetCoupons 获取优惠券列表，sellerID 为 -1 时不按商家筛选，onlyActive 为 true 时只返回启用的优惠券
+func GetCoupons(page, pageSize int, sellerID int64, onlyActive bool) ([]*Coupon, int, error) {
+	where := ` WHERE 1=1`
+	var args []interface{}
+	if sellerID >= 0 {
+		where += ` AND c.seller_id = ?`
+		args = append(args, sellerID)
+	}
+	if onlyActive {
+		where += ` AND c.status = 1`
+	}
+
+	var total int
+	err := config.DB.QueryRow(`SELECT COUNT(*) FROM coupons c`+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, pageSize, (page-1)*pageSize)
+	rows, err := config.DB.Query(`SELECT `+couponColumns+couponJoins+where+` ORDER BY c.created_at DESC LIMIT ? OFFSET ?`, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var coupons []*Coupon
+	for rows.Next() {
+		coupon := &Coupon{}
+		if err := scanCoupon(rows, coupon); err != nil {
+			return nil, 0, err
+		}
+		coupons = append(coupons, coupon)
+	}
+	return coupons, total, nil
+}
+
+// UpdateCouponStatus 启用或停用优惠券
+func UpdateCouponStatus(id int64, status int) error {
+	_, err := config.DB.Exec(`UPDATE coupons SET status = ? WHERE id = ?`, status, id)
+	return err
+}
+
+// ClaimCoupon 领取优惠券到用户券包，检查有效期、发行量和每人限领张数
+func ClaimCoupon(userID, couponID int64) (int64, error) {
+	coupon, err := GetCouponByID(couponID)
+	if err != nil {
+		return 0, fmt.Errorf("优惠券不存在")
+	}
+	if !coupon.Available(time.Now()) {
+		return 0, fmt.Errorf("优惠券不在领取时间内")
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var claimed int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM user_coupons WHERE user_id = ? AND coupon_id = ?`, userID, couponID).Scan(&claimed)
+	if err != nil {
+		return 0, err
+	}
+	if claimed >= coupon.PerUserLimit {
+		return 0, fmt.Errorf("已达到领取上限")
+	}
+
+	result, err := tx.Exec(`
+		UPDATE coupons SET claimed_count = claimed_count + 1
+		WHERE id = ? AND (total_count = 0 OR claimed_count < total_count)
+	`, couponID)
+	if err != nil {
+		return 0, err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return 0, fmt.Errorf("优惠券已领完")
+	}
+
+	result, err = tx.Exec(`INSERT INTO user_coupons (user_id, coupon_id) VALUES (?, ?)`, userID, couponID)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+const userCouponQuery = `SELECT uc.id, uc.user_id, uc.coupon_id, uc.status, COALESCE(uc.order_id, 0), uc.claimed_at, uc.used_at, ` +
+	couponColumns + couponJoins + ` JOIN user_coupons uc ON uc.coupon_id = c.id`
+
+func scanUserCoupon(scanner interface{ Scan(...interface{}) error }) (*UserCoupon, error) {
+	uc := &UserCoupon{Coupon: &Coupon{}}
+	c := uc.Coupon
+	var usedAt sql.NullTime
+	err := scanner.Scan(&uc.ID, &uc.UserID, &uc.CouponID, &uc.Status, &uc.OrderID, &uc.ClaimedAt, &usedAt,
+		&c.ID, &c.SellerID, &c.Name, &c.Type, &c.Value, &c.MinAmount, &c.MaxDiscount, &c.CategoryID,
+		&c.TotalCount, &c.ClaimedCount, &c.PerUserLimit, &c.StartTime, &c.EndTime, &c.Status, &c.CreatedAt,
+		&c.SellerName, &c.CategoryName)
+	if err != nil {
+		return nil, err
+	}
+	if usedAt.Valid {
+		uc.UsedAt = &usedAt.Time
+	}
+	return uc, nil
+}
+
+// GetUserCouponByID 获取用户券包中的优惠券
+func GetUserCouponByID(userID, id int64) (*UserCoupon, error) {
+	return scanUserCoupon(config.DB.QueryRow(userCouponQuery+` WHERE uc.id = ? AND uc.user_id = ?`, id, userID))
+}
+
+// GetUserCoupons 获取用户券包，status 为 -1 时返回全部
+func GetUserCoupons(userID int64, status int) ([]*UserCoupon, error) {
+	query := userCouponQuery + ` WHERE uc.user_id = ?`
+	args := []interface{}{userID}
+	if status >= 0 {
+		query += ` AND uc.status = ?`
+		args = append(args, status)
+	}
+
+	rows, err := config.DB.Query(query+` ORDER BY uc.claimed_at DESC, uc.id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var coupons []*UserCoupon
+	for rows.Next() {
+		uc, err := scanUserCoupon(rows)
+		if err != nil {
+			return nil, err
+		}
+		coupons = append(coupons, uc)
+	}
+	return coupons, nil
+}
+
+// useCouponTx 在下单事务中核销优惠券，已被使用时返回 ErrCouponUnavailable
+func useCouponTx(tx *sql.Tx, userID, userCouponID, orderID int64) error {
+	result, err := tx.Exec(`
+		UPDATE user_coupons SET status = 1, order_id = ?, used_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND user_id = ? AND status = 0
+	`, orderID, userCouponID, userID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrCouponUnavailable
+	}
+	return nil
+}
+
+// returnCouponTx 取消订单时退回订单使用的优惠券
+func returnCouponTx(tx *sql.Tx, orderID int64) error {
+	_, err := tx.Exec(`
+		UPDATE user_coupons SET status = 0, order_id = NULL, used_at = NULL
+		WHERE order_id = ? AND status = 1
+	`, orderID)
+	return err
+}
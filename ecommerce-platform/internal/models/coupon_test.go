@@ -0,0 +1,167 @@
+package models
+
+import (
+	"ecommerce-platform/internal/testutil"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCouponDiscount(t *testing.T) {
+	// 分类 1 的商品共 30 元，分类 2 的商品共 70 元
+	items := []*CartItem{
+		{Quantity: 3, Product: &Product{CategoryID: 1, Price: 10}},
+		{Quantity: 1, Product: &Product{CategoryID: 2, Price: 70}},
+	}
+
+	tests := []struct {
+		name    string
+		coupon  Coupon
+		want    float64
+		wantErr bool
+	}{
+		{"立减", Coupon{Type: CouponFixed, Value: 10}, 10, false},
+		{"折扣", Coupon{Type: CouponPercent, Value: 20}, 20, false},
+		{"折扣超过最高优惠", Coupon{Type: CouponPercent, Value: 20, MaxDiscount: 15}, 15, false},
+		{"折扣未超过最高优惠", Coupon{Type: CouponPercent, Value: 10, MaxDiscount: 15}, 10, false},
+		{"限定分类按分类金额折扣", Coupon{Type: CouponPercent, Value: 10, CategoryID: 1}, 3, false},
+		{"满减达到门槛", Coupon{Type: CouponThreshold, Value: 10, MinAmount: 100}, 10, false},
+		{"满减未达到门槛", Coupon{Type: CouponThreshold, Value: 10, MinAmount: 101}, 0, true},
+		{"限定分类按分类金额判断门槛", Coupon{Type: CouponThreshold, Value: 10, MinAmount: 50, CategoryID: 1}, 0, true},
+		{"优惠不超过适用金额", Coupon{Type: CouponFixed, Value: 50, CategoryID: 1}, 30, false},
+		{"没有适用分类的商品", Coupon{Type: CouponFixed, Value: 10, CategoryID: 3}, 0, true},
+		{"本店商家券", Coupon{Type: CouponFixed, Value: 10, SellerID: 1}, 10, false},
+		{"其他店铺的商家券", Coupon{Type: CouponFixed, Value: 10, SellerID: 2}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.coupon.Discount(1, items)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("期望错误 %v，实际 %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("优惠金额应为 %v，实际 %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func createTestCoupon(t *testing.T, totalCount, perUserLimit int) int64 {
+	t.Helper()
+	id, err := CreateCoupon(&Coupon{
+		Name:         "c",
+		Type:         CouponFixed,
+		Value:        5,
+		TotalCount:   totalCount,
+		PerUserLimit: perUserLimit,
+		StartTime:    time.Now().Add(-time.Hour),
+		EndTime:      time.Now().Add(time.Hour),
+		Status:       1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestClaimCouponPerUserLimit(t *testing.T) {
+	userID, _ := setupTestDB(t)
+	couponID := createTestCoupon(t, 0, 2)
+
+	for i := 0; i < 2; i++ {
+		if _, err := ClaimCoupon(userID, couponID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := ClaimCoupon(userID, couponID); err == nil {
+		t.Error("超过每人限领张数应领取失败")
+	}
+	if got := testutil.QueryInt(t, `SELECT claimed_count FROM coupons WHERE id = ?`, couponID); got != 2 {
+		t.Errorf("已领取数量应为 2，实际 %d", got)
+	}
+}
+
+func TestClaimCouponConcurrently(t *testing.T) {
+	setupTestDB(t)
+	couponID := createTestCoupon(t, 5, 1)
+
+	// 10 个用户同时领取发行量为 5 的优惠券，只能领出 5 张
+	const users = 10
+	for i := 0; i < users; i++ {
+		testutil.MustExec(t, `INSERT INTO users (id, username, password, email, role) VALUES (?, ?, 'x', ?, 'customer')`,
+			100+i, fmt.Sprintf("u%d", i), fmt.Sprintf("u%d@test.com", i))
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < users; i++ {
+		wg.Add(1)
+		go func(userID int64) {
+			defer wg.Done()
+			if _, err := ClaimCoupon(userID, couponID); err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}(int64(100 + i))
+	}
+	wg.Wait()
+
+	if succeeded != 5 {
+		t.Errorf("应领取成功 5 次，实际 %d", succeeded)
+	}
+	if got := testutil.QueryInt(t, `SELECT claimed_count FROM coupons WHERE id = ?`, couponID); got != 5 {
+		t.Errorf("已领取数量应为 5，实际 %d", got)
+	}
+	if got := testutil.QueryInt(t, `SELECT COUNT(*) FROM user_coupons WHERE coupon_id = ?`, couponID); got != 5 {
+		t.Errorf("券包中应有 5 张，实际 %d", got)
+	}
+}
+
+func TestCouponReturnedOnCancelOrder(t *testing.T) {
+	userID, sellerIDs := setupTestDB(t)
+	productID := createTestProduct(t, sellerIDs[0], 10)
+	userCouponID, err := ClaimCoupon(userID, createTestCoupon(t, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order := newTestOrder(userID, sellerIDs[0], productID, 0, 1)
+	order.UserCouponID = userCouponID
+	orderIDs, err := CreateOrders([]*Order{order}, userID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uc, err := GetUserCouponByID(userID, userCouponID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uc.Status != 1 || uc.OrderID != orderIDs[0] {
+		t.Fatalf("下单后优惠券应已核销到订单 %d，实际状态 %d、订单 %d", orderIDs[0], uc.Status, uc.OrderID)
+	}
+
+	// 已使用的优惠券不能再下单
+	again := newTestOrder(userID, sellerIDs[0], productID, 0, 1)
+	again.UserCouponID = userCouponID
+	if _, err := CreateOrders([]*Order{again}, userID, nil); !errors.Is(err, ErrCouponUnavailable) {
+		t.Fatalf("重复使用优惠券应返回 ErrCouponUnavailable，实际 %v", err)
+	}
+
+	if err := CancelOrder(orderIDs[0]); err != nil {
+		t.Fatal(err)
+	}
+	uc, err = GetUserCouponByID(userID, userCouponID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uc.Status != 0 || uc.OrderID != 0 || uc.UsedAt != nil {
+		t.Errorf("取消订单后优惠券应退回，实际状态 %d、订单 %d", uc.Status, uc.OrderID)
+	}
+
+	// 退回的优惠券可以再次使用
+	if _, err := CreateOrders([]*Order{again}, userID, nil); err != nil {
+		t.Errorf("退回的优惠券应可再次使用: %v", err)
+	}
+}
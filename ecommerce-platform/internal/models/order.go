@@ -3,32 +3,36 @@ package models
 import (
 	"database/sql"
 	"ecommerce-platform/internal/config"
+	"errors"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
 type Order struct {
-	ID             int64     `json:"id"`
-	OrderNo        string    `json:"order_no"`
-	UserID         int64     `json:"user_id"`
-	SellerID       int64     `json:"seller_id"`
-	TotalAmount    float64   `json:"total_amount"`
-	PayAmount      float64   `json:"pay_amount"`
-	FreightAmount  float64   `json:"freight_amount"`
-	Status         int       `json:"status"` // 0待支付 1待发货 2已发货 3已完成 4已取消 5退款中 6已退款
-	PayType        string    `json:"pay_type"`
-	PayTime        *time.Time `json:"pay_time"`
-	ShipTime       *time.Time `json:"ship_time"`
-	ReceiveTime    *time.Time `json:"receive_time"`
-	FinishTime     *time.Time `json:"finish_time"`
-	ReceiverName   string    `json:"receiver_name"`
-	ReceiverPhone  string    `json:"receiver_phone"`
-	ReceiverAddress string   `json:"receiver_address"`
-	Remark         string    `json:"remark"`
-	TrackingNo     string    `json:"tracking_no"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	ID              int64      `json:"id"`
+	OrderNo         string     `json:"order_no"`
+	UserID          int64      `json:"user_id"`
+	SellerID        int64      `json:"seller_id"`
+	TotalAmount     float64    `json:"total_amount"`
+	PayAmount       float64    `json:"pay_amount"`
+	FreightAmount   float64    `json:"freight_amount"`
+	DiscountAmount  float64    `json:"discount_amount"`
+	UserCouponID    int64      `json:"user_coupon_id"` // 使用的用户优惠券，0 为未使用
+	Status          int        `json:"status"`         // 0待支付 1待发货 2已发货 3已完成 4已取消 5退款中 6已退款
+	PayType         string     `json:"pay_type"`
+	PayTime         *time.Time `json:"pay_time"`
+	ShipTime        *time.Time `json:"ship_time"`
+	ReceiveTime     *time.Time `json:"receive_time"`
+	FinishTime      *time.Time `json:"finish_time"`
+	ReceiverName    string     `json:"receiver_name"`
+	ReceiverPhone   string     `json:"receiver_phone"`
+	ReceiverAddress string     `json:"receiver_address"`
+	Remark          string     `json:"remark"`
+	TrackingNo      string     `json:"tracking_no"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
 	// 关联信息
 	Items      []*OrderItem `json:"items,omitempty"`
 	SellerName string       `json:"seller_name,omitempty"`
@@ -36,38 +40,89 @@ type Order struct {
 }
 
 type OrderItem struct {
-	ID           int64     `json:"id"`
-	OrderID      int64     `json:"order_id"`
-	ProductID    int64     `json:"product_id"`
-	ProductName  string    `json:"product_name"`
-	ProductImage string    `json:"product_image"`
-	Price        float64   `json:"price"`
-	Quantity     int       `json:"quantity"`
-	TotalPrice   float64   `json:"total_price"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID               int64     `json:"id"`
+	OrderID          int64     `json:"order_id"`
+	ProductID        int64     `json:"product_id"`
+	ProductName      string    `json:"product_name"`
+	ProductImage     string    `json:"product_image"`
+	SKUID            int64     `json:"sku_id"`
+	SKUSpecs         string    `json:"sku_specs"`
+	Price            float64   `json:"price"`
+	Quantity         int       `json:"quantity"`
+	TotalPrice       float64   `json:"total_price"`
+	RefundedQuantity int       `json:"refunded_quantity"` // 已退款数量
+	CreatedAt        time.Time `json:"created_at"`
 }
 
-// GenerateOrderNo 生成订单号
+var orderNoSeq uint32
+
+// GenerateOrderNo 生成订单号，末尾的自增序号保证并发下单时不重复
 func GenerateOrderNo() string {
-	return fmt.Sprintf("%d%d", time.Now().UnixNano(), time.Now().Unix()%1000)
+	return fmt.Sprintf("%d%04d", time.Now().UnixNano(), atomic.AddUint32(&orderNoSeq, 1)%10000)
 }
 
+// ErrInsufficientStock 下单时商品或SKU库存不足
+var ErrInsufficientStock = errors.New("库存不足")
+
+// ErrOrderNotCancellable 订单不是待支付状态，不能取消
+var ErrOrderNotCancellable = errors.New("订单状态不允许取消")
+
+// ErrOrderNotShippable 订单不是待发货状态，不能发货
+var ErrOrderNotShippable = errors.New("订单状态不允许发货")
+
+// ErrOrderAfterSaleOpen 订单有进行中的售后，需处理完售后再发货或确认收货
+var ErrOrderAfterSaleOpen = errors.New("订单有进行中的售后，请先处理售后")
+
 // CreateOrder 创建订单
 func CreateOrder(order *Order) (int64, error) {
-	tx, err := config.DB.Begin()
+	orderIDs, err := CreateOrders([]*Order{order}, order.UserID, nil)
 	if err != nil {
 		return 0, err
 	}
+	return orderIDs[0], nil
+}
+
+// CreateOrders 在一个事务中创建多个订单（按商家拆分的同一次结算），
+// 并核销优惠券、删除已结算的购物车商品。库存按条件扣减，任一商品库存不足时整体回滚，
+// 返回的错误包装 ErrInsufficientStock；优惠券已被使用时返回 ErrCouponUnavailable
+func CreateOrders(orders []*Order, userID int64, cartItemIDs []int64) ([]int64, error) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
 	defer tx.Rollback()
 
+	var orderIDs []int64
+	for _, order := range orders {
+		orderID, err := createOrderTx(tx, order)
+		if err != nil {
+			return nil, err
+		}
+		orderIDs = append(orderIDs, orderID)
+	}
+
+	for _, id := range cartItemIDs {
+		_, err = tx.Exec(`DELETE FROM cart_items WHERE id = ? AND user_id = ?`, id, userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return orderIDs, nil
+}
+
+func createOrderTx(tx *sql.Tx, order *Order) (int64, error) {
 	// 创建订单
 	result, err := tx.Exec(`
 		INSERT INTO orders (order_no, user_id, seller_id, total_amount, pay_amount, freight_amount,
-			status, receiver_name, receiver_phone, receiver_address, remark)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			discount_amount, user_coupon_id, status, receiver_name, receiver_phone, receiver_address, remark)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`, order.OrderNo, order.UserID, order.SellerID, order.TotalAmount, order.PayAmount,
-		order.FreightAmount, order.Status, order.ReceiverName, order.ReceiverPhone,
-		order.ReceiverAddress, order.Remark)
+		order.FreightAmount, order.DiscountAmount, order.UserCouponID, order.Status, order.ReceiverName,
+		order.ReceiverPhone, order.ReceiverAddress, order.Remark)
 	if err != nil {
 		return 0, err
 	}
@@ -77,27 +132,42 @@ func CreateOrder(order *Order) (int64, error) {
 		return 0, err
 	}
 
+	// 核销优惠券
+	if order.UserCouponID > 0 {
+		if err = useCouponTx(tx, order.UserID, order.UserCouponID, orderID); err != nil {
+			return 0, err
+		}
+	}
+
 	// 创建订单商品
 	for _, item := range order.Items {
 		_, err = tx.Exec(`
-			INSERT INTO order_items (order_id, product_id, product_name, product_image, price, quantity, total_price)
-			VALUES (?, ?, ?, ?, ?, ?, ?)
-		`, orderID, item.ProductID, item.ProductName, item.ProductImage, item.Price, item.Quantity, item.TotalPrice)
+			INSERT INTO order_items (order_id, product_id, product_name, product_image, sku_id, sku_specs, price, quantity, total_price)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, orderID, item.ProductID, item.ProductName, item.ProductImage, item.SKUID, item.SKUSpecs, item.Price, item.Quantity, item.TotalPrice)
 		if err != nil {
 			return 0, err
 		}
 
-		// 减少库存，增加销量
-		_, err = tx.Exec(`UPDATE products SET stock = stock - ?, sales = sales + ? WHERE id = ?`,
-			item.Quantity, item.Quantity, item.ProductID)
+		// 库存充足时才减少库存，增加销量
+		result, err = tx.Exec(`UPDATE products SET stock = stock - ?, sales = sales + ? WHERE id = ? AND stock >= ?`,
+			item.Quantity, item.Quantity, item.ProductID, item.Quantity)
 		if err != nil {
 			return 0, err
 		}
-	}
-
-	err = tx.Commit()
-	if err != nil {
-		return 0, err
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return 0, fmt.Errorf("商品%s%w", item.ProductName, ErrInsufficientStock)
+		}
+		if item.SKUID > 0 {
+			result, err = tx.Exec(`UPDATE product_skus SET stock = stock - ?, sales = sales + ? WHERE id = ? AND status = 1 AND stock >= ?`,
+				item.Quantity, item.Quantity, item.SKUID, item.Quantity)
+			if err != nil {
+				return 0, err
+			}
+			if affected, _ := result.RowsAffected(); affected == 0 {
+				return 0, fmt.Errorf("商品%s %s%w", item.ProductName, item.SKUSpecs, ErrInsufficientStock)
+			}
+		}
 	}
 
 	return orderID, nil
@@ -108,23 +178,23 @@ func GetOrderByID(id int64) (*Order, error) {
 	order := &Order{}
 	var payTime, shipTime, receiveTime, finishTime sql.NullTime
 	err := config.DB.QueryRow(`
-		SELECT o.id, o.order_no, o.user_id, o.seller_id, o.total_amount, o.pay_amount, o.freight_amount,
-			o.status, o.pay_type, o.pay_time, o.ship_time, o.receive_time, o.finish_time,
-			o.receiver_name, o.receiver_phone, o.receiver_address, o.remark, o.tracking_no,
+		SELECT o.id, o.order_no, o.user_id, o.seller_id, o.total_amount, o.pay_amount, o.freight_amount, o.discount_amount, o.user_coupon_id,
+			o.status, COALESCE(o.pay_type, ''), o.pay_time, o.ship_time, o.receive_time, o.finish_time,
+			o.receiver_name, o.receiver_phone, o.receiver_address, COALESCE(o.remark, ''), COALESCE(o.tracking_no, ''),
 			o.created_at, o.updated_at, s.shop_name, u.username
 		FROM orders o
 		LEFT JOIN sellers s ON o.seller_id = s.id
 		LEFT JOIN users u ON o.user_id = u.id
 		WHERE o.id = ?
 	`, id).Scan(&order.ID, &order.OrderNo, &order.UserID, &order.SellerID, &order.TotalAmount,
-		&order.PayAmount, &order.FreightAmount, &order.Status, &order.PayType,
+		&order.PayAmount, &order.FreightAmount, &order.DiscountAmount, &order.UserCouponID, &order.Status, &order.PayType,
 		&payTime, &shipTime, &receiveTime, &finishTime,
 		&order.ReceiverName, &order.ReceiverPhone, &order.ReceiverAddress, &order.Remark,
 		&order.TrackingNo, &order.CreatedAt, &order.UpdatedAt, &order.SellerName, &order.Username)
 	if err != nil {
 		return nil, err
 	}
-	
+
 	if payTime.Valid {
 		order.PayTime = &payTime.Time
 	}
@@ -156,7 +226,8 @@ func GetOrderByOrderNo(orderNo string) (*Order, error) {
 // GetOrderItems 获取订单商品
 func GetOrderItems(orderID int64) ([]*OrderItem, error) {
 	rows, err := config.DB.Query(`
-		SELECT id, order_id, product_id, product_name, product_image, price, quantity, total_price, created_at
+		SELECT id, order_id, product_id, product_name, product_image, COALESCE(sku_id, 0), COALESCE(sku_specs, ''),
+			price, quantity, total_price, COALESCE(refunded_quantity, 0), created_at
 		FROM order_items WHERE order_id = ?
 	`, orderID)
 	if err != nil {
@@ -168,7 +239,8 @@ func GetOrderItems(orderID int64) ([]*OrderItem, error) {
 	for rows.Next() {
 		item := &OrderItem{}
 		err := rows.Scan(&item.ID, &item.OrderID, &item.ProductID, &item.ProductName,
-			&item.ProductImage, &item.Price, &item.Quantity, &item.TotalPrice, &item.CreatedAt)
+			&item.ProductImage, &item.SKUID, &item.SKUSpecs, &item.Price, &item.Quantity, &item.TotalPrice,
+			&item.RefundedQuantity, &item.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -180,10 +252,10 @@ func GetOrderItems(orderID int64) ([]*OrderItem, error) {
 // GetOrders 获取订单列表
 func GetOrders(page, pageSize int, userID, sellerID int64, status int) ([]*Order, int, error) {
 	offset := (page - 1) * pageSize
-	
+
 	conditions := []string{"1=1"}
 	args := []interface{}{}
-	
+
 	if userID > 0 {
 		conditions = append(conditions, "o.user_id = ?")
 		args = append(args, userID)
@@ -196,9 +268,9 @@ func GetOrders(page, pageSize int, userID, sellerID int64, status int) ([]*Order
 		conditions = append(conditions, "o.status = ?")
 		args = append(args, status)
 	}
-	
+
 	whereClause := strings.Join(conditions, " AND ")
-	
+
 	var total int
 	err := config.DB.QueryRow(`SELECT COUNT(*) FROM orders o WHERE `+whereClause, args...).Scan(&total)
 	if err != nil {
@@ -207,9 +279,9 @@ func GetOrders(page, pageSize int, userID, sellerID int64, status int) ([]*Order
 
 	queryArgs := append(args, pageSize, offset)
 	rows, err := config.DB.Query(`
-		SELECT o.id, o.order_no, o.user_id, o.seller_id, o.total_amount, o.pay_amount, o.freight_amount,
-			o.status, o.pay_type, o.pay_time, o.ship_time, o.receive_time, o.finish_time,
-			o.receiver_name, o.receiver_phone, o.receiver_address, o.remark, o.tracking_no,
+		SELECT o.id, o.order_no, o.user_id, o.seller_id, o.total_amount, o.pay_amount, o.freight_amount, o.discount_amount, o.user_coupon_id,
+			o.status, COALESCE(o.pay_type, ''), o.pay_time, o.ship_time, o.receive_time, o.finish_time,
+			o.receiver_name, o.receiver_phone, o.receiver_address, COALESCE(o.remark, ''), COALESCE(o.tracking_no, ''),
 			o.created_at, o.updated_at, s.shop_name, u.username
 		FROM orders o
 		LEFT JOIN sellers s ON o.seller_id = s.id
@@ -225,14 +297,14 @@ func GetOrders(page, pageSize int, userID, sellerID int64, status int) ([]*Order
 		order := &Order{}
 		var payTime, shipTime, receiveTime, finishTime sql.NullTime
 		err := rows.Scan(&order.ID, &order.OrderNo, &order.UserID, &order.SellerID, &order.TotalAmount,
-			&order.PayAmount, &order.FreightAmount, &order.Status, &order.PayType,
+			&order.PayAmount, &order.FreightAmount, &order.DiscountAmount, &order.UserCouponID, &order.Status, &order.PayType,
 			&payTime, &shipTime, &receiveTime, &finishTime,
 			&order.ReceiverName, &order.ReceiverPhone, &order.ReceiverAddress, &order.Remark,
 			&order.TrackingNo, &order.CreatedAt, &order.UpdatedAt, &order.SellerName, &order.Username)
 		if err != nil {
 			return nil, 0, err
 		}
-		
+
 		if payTime.Valid {
 			order.PayTime = &payTime.Time
 		}
@@ -270,22 +342,28 @@ func UpdateOrderStatus(id int64, status int) error {
 	return err
 }
 
-// PayOrder 支付订单
-func PayOrder(id int64, payType string) error {
-	_, err := config.DB.Exec(`
-		UPDATE orders SET status = 1, pay_type = ?, pay_time = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
-		WHERE id = ? AND status = 0
-	`, payType, id)
-	return err
-}
-
-// ShipOrder 发货
+// ShipOrder 发货。订单有进行中的售后时不能发货，否则仅退款完成后买家既拿到退款又收到商品
 func ShipOrder(id int64, trackingNo string) error {
-	_, err := config.DB.Exec(`
+	result, err := config.DB.Exec(`
 		UPDATE orders SET status = 2, tracking_no = ?, ship_time = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ? AND status = 1
+		AND NOT EXISTS (SELECT 1 FROM after_sales WHERE order_id = orders.id AND status IN `+openAfterSaleStatuses+`)
 	`, trackingNo, id)
-	return err
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		return nil
+	}
+
+	var open int
+	if err := config.DB.QueryRow(`SELECT COUNT(*) FROM after_sales WHERE order_id = ? AND status IN `+openAfterSaleStatuses, id).Scan(&open); err != nil {
+		return err
+	}
+	if open > 0 {
+		return ErrOrderAfterSaleOpen
+	}
+	return ErrOrderNotShippable
 }
 
 // ReceiveOrder 确认收货
@@ -305,7 +383,7 @@ func CancelOrder(id int64) error {
 		return err
 	}
 	if order.Status != 0 {
-		return fmt.Errorf("订单状态不允许取消")
+		return ErrOrderNotCancellable
 	}
 
 	tx, err := config.DB.Begin()
@@ -314,11 +392,19 @@ func CancelOrder(id int64) error {
 	}
 	defer tx.Rollback()
 
-	// 更新订单状态
-	_, err = tx.Exec(`UPDATE orders SET status = 4, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, id)
+	// 更新订单状态，只有待支付的订单能取消，避免重复取消时重复恢复库存
+	result, err := tx.Exec(`UPDATE orders SET status = 4, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND status = 0`, id)
 	if err != nil {
 		return err
 	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrOrderNotCancellable
+	}
+
+	// 退回优惠券
+	if err = returnCouponTx(tx, id); err != nil {
+		return err
+	}
 
 	// 恢复库存
 	for _, item := range order.Items {
@@ -327,11 +413,60 @@ func CancelOrder(id int64) error {
 		if err != nil {
 			return err
 		}
+		if item.SKUID > 0 {
+			_, err = tx.Exec(`UPDATE product_skus SET stock = stock + ?, sales = sales - ? WHERE id = ?`,
+				item.Quantity, item.Quantity, item.SKUID)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	return tx.Commit()
 }
 
+// GetExpiredUnpaidOrderIDs 获取下单超过 timeout 仍未支付的订单
+func GetExpiredUnpaidOrderIDs(timeout time.Duration, limit int) ([]int64, error) {
+	rows, err := config.DB.Query(`
+		SELECT id FROM orders WHERE status = 0 AND created_at <= datetime('now', ?)
+		ORDER BY id LIMIT ?
+	`, sqliteAgo(timeout), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// AutoReceiveOrders 将发货超过 after 的订单自动确认收货，返回处理的订单数。
+// 有进行中售后的订单等售后处理完再确认收货
+func AutoReceiveOrders(after time.Duration) (int64, error) {
+	result, err := config.DB.Exec(`
+		UPDATE orders SET status = 3, receive_time = CURRENT_TIMESTAMP, finish_time = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE status = 2 AND ship_time <= datetime('now', ?)
+		AND NOT EXISTS (SELECT 1 FROM after_sales WHERE order_id = orders.id AND status IN `+openAfterSaleStatuses+`)
+	`, sqliteAgo(after))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// sqliteAgo 将时长转换为 SQLite datetime 的修饰符，如 "-1800 seconds"。
+// 订单时间由 CURRENT_TIMESTAMP 写入（UTC），在 SQL 中比较可避免时区不一致
+func sqliteAgo(d time.Duration) string {
+	return fmt.Sprintf("-%d seconds", int64(d/time.Second))
+}
+
 // UpdateOrderAddress 更新订单地址
 func UpdateOrderAddress(id int64, name, phone, address string) error {
 	_, err := config.DB.Exec(`
@@ -344,7 +479,7 @@ func UpdateOrderAddress(id int64, name, phone, address string) error {
 // GetOrderStats 获取订单统计
 func GetOrderStats(sellerID int64) (map[string]interface{}, error) {
 	stats := make(map[string]interface{})
-	
+
 	var condition string
 	var args []interface{}
 	if sellerID > 0 {
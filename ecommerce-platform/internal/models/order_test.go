@@ -0,0 +1,180 @@
+package models
+
+import (
+	"ecommerce-platform/internal/testutil"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// setupTestDB 使用临时数据库，并创建一个顾客、两个商家和一个分类
+func setupTestDB(t *testing.T) (userID int64, sellerIDs [2]int64) {
+	t.Helper()
+	testutil.OpenDB(t)
+
+	userID = testutil.SeedBuyer(t)
+	testutil.SeedShop(t, 1)
+	testutil.SeedShop(t, 2)
+	testutil.MustExec(t, `INSERT INTO categories (id, name) VALUES (1, 'test')`)
+	return userID, [2]int64{1, 2}
+}
+
+func createTestProduct(t *testing.T, sellerID int64, stock int) int64 {
+	t.Helper()
+	id, err := CreateProduct(&Product{SellerID: sellerID, CategoryID: 1, Name: "p", Price: 10, Stock: stock, Status: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func newTestOrder(userID, sellerID, productID, skuID int64, quantity int) *Order {
+	return &Order{
+		OrderNo:  GenerateOrderNo(),
+		UserID:   userID,
+		SellerID: sellerID,
+		Items: []*OrderItem{{
+			ProductID:   productID,
+			ProductName: "p",
+			SKUID:       skuID,
+			Price:       10,
+			Quantity:    quantity,
+			TotalPrice:  10 * float64(quantity),
+		}},
+	}
+}
+
+// hammer 并发下单，返回成功的次数
+func hammer(t *testing.T, buyers int, newOrder func() *Order) int {
+	t.Helper()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < buyers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			order := newOrder()
+			_, err := CreateOrders([]*Order{order}, order.UserID, nil)
+			if err != nil {
+				if !errors.Is(err, ErrInsufficientStock) {
+					t.Errorf("下单失败的原因应为库存不足: %v", err)
+				}
+				return
+			}
+			mu.Lock()
+			succeeded++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return succeeded
+}
+
+func TestCreateOrdersConcurrentNoOversell(t *testing.T) {
+	userID, sellers := setupTestDB(t)
+	const stock, buyers = 5, 50
+	productID := createTestProduct(t, sellers[0], stock)
+
+	succeeded := hammer(t, buyers, func() *Order {
+		return newTestOrder(userID, sellers[0], productID, 0, 1)
+	})
+
+	if succeeded != stock {
+		t.Errorf("成功下单 %d 次，期望 %d 次", succeeded, stock)
+	}
+	if got := testutil.QueryInt(t, `SELECT stock FROM products WHERE id = ?`, productID); got != 0 {
+		t.Errorf("剩余库存 %d，期望 0", got)
+	}
+	if got := testutil.QueryInt(t, `SELECT sales FROM products WHERE id = ?`, productID); got != stock {
+		t.Errorf("销量 %d，期望 %d", got, stock)
+	}
+	if got := testutil.QueryInt(t, `SELECT COUNT(*) FROM orders`); got != stock {
+		t.Errorf("订单数 %d，期望 %d", got, stock)
+	}
+}
+
+func TestCreateOrdersConcurrentSKU(t *testing.T) {
+	userID, sellers := setupTestDB(t)
+	productID := createTestProduct(t, sellers[0], 0)
+	err := SaveProductSKUs(productID,
+		[]*ProductOption{{Name: "颜色", Values: []string{"黑色", "白色"}}},
+		[]*ProductSKU{
+			{Specs: map[string]string{"颜色": "黑色"}, Price: 10, Stock: 3},
+			{Specs: map[string]string{"颜色": "白色"}, Price: 12, Stock: 100},
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	skus, err := GetProductSKUs(productID, true)
+	if err != nil || len(skus) != 2 {
+		t.Fatalf("获取SKU失败: %v", err)
+	}
+	black := skus[0]
+
+	// 商品总库存充足，但所选SKU只有3件
+	succeeded := hammer(t, 30, func() *Order {
+		return newTestOrder(userID, sellers[0], productID, black.ID, 1)
+	})
+
+	if succeeded != 3 {
+		t.Errorf("成功下单 %d 次，期望 3 次", succeeded)
+	}
+	if got := testutil.QueryInt(t, `SELECT stock FROM product_skus WHERE id = ?`, black.ID); got != 0 {
+		t.Errorf("SKU剩余库存 %d，期望 0", got)
+	}
+	if got := testutil.QueryInt(t, `SELECT stock FROM products WHERE id = ?`, productID); got != 100 {
+		t.Errorf("商品剩余库存 %d，期望 100", got)
+	}
+}
+
+func TestCreateOrdersAtomicAcrossSellers(t *testing.T) {
+	userID, sellers := setupTestDB(t)
+	enough := createTestProduct(t, sellers[0], 10)
+	scarce := createTestProduct(t, sellers[1], 1)
+	if err := AddToCart(userID, enough, 0, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddToCart(userID, scarce, 0, 2); err != nil {
+		t.Fatal(err)
+	}
+	items, err := GetSelectedCartItems(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cartItemIDs []int64
+	for _, item := range items {
+		cartItemIDs = append(cartItemIDs, item.ID)
+	}
+
+	orders := []*Order{
+		newTestOrder(userID, sellers[0], enough, 0, 2),
+		newTestOrder(userID, sellers[1], scarce, 0, 2),
+	}
+	if _, err := CreateOrders(orders, userID, cartItemIDs); !errors.Is(err, ErrInsufficientStock) {
+		t.Fatalf("期望库存不足错误，实际: %v", err)
+	}
+
+	if got := testutil.QueryInt(t, `SELECT COUNT(*) FROM orders`); got != 0 {
+		t.Errorf("失败的结算不应留下订单，实际 %d 个", got)
+	}
+	if got := testutil.QueryInt(t, `SELECT stock FROM products WHERE id = ?`, enough); got != 10 {
+		t.Errorf("其他商家商品库存应回滚为 10，实际 %d", got)
+	}
+	if got := testutil.QueryInt(t, `SELECT COUNT(*) FROM cart_items WHERE user_id = ?`, userID); got != 2 {
+		t.Errorf("购物车应保持不变，实际 %d 项", got)
+	}
+
+	// 库存充足时全部成功并清理购物车
+	orders[1].Items[0].Quantity = 1
+	orderIDs, err := CreateOrders(orders, userID, cartItemIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orderIDs) != 2 {
+		t.Errorf("期望创建 2 个订单，实际 %d 个", len(orderIDs))
+	}
+	if got := testutil.QueryInt(t, `SELECT COUNT(*) FROM cart_items WHERE user_id = ?`, userID); got != 0 {
+		t.Errorf("购物车应已清空，实际 %d 项", got)
+	}
+}
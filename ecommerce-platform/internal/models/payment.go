@@ -0,0 +1,210 @@
+package models
+
+import (
+	"database/sql"
+	"ecommerce-platform/internal/config"
+	"errors"
+	"math"
+	"time"
+)
+
+// 支付记录状态
+const (
+	PaymentPending   = 0 // 待支付
+	PaymentPaid      = 1 // 已支付
+	PaymentRefunded  = 2 // 已退款
+	PaymentRefunding = 3 // 待退款：支付成功时订单已取消或已由其他支付记录支付
+)
+
+var (
+	// ErrPaymentAmountMismatch 支付通知中的金额与支付记录不一致
+	ErrPaymentAmountMismatch = errors.New("支付金额不一致")
+	// ErrOrderNotPayable 支付成功时订单已取消或已由其他支付记录支付，需要退款
+	ErrOrderNotPayable = errors.New("订单状态不允许支付")
+)
+
+// Payment 支付记录，每次发起支付对应一条
+type Payment struct {
+	ID        int64   `json:"id"`
+	PaymentNo string  `json:"payment_no"`
+	OrderID   int64   `json:"order_id"`
+	UserID    int64   `json:"user_id"`
+	Provider  string  `json:"provider"`
+	Amount    float64 `json:"amount"`
+	Status    int     `json:"status"` // 0待支付 1已支付 2已退款 3待退款
+	TradeNo   string  `json:"trade_no"`
+	PayURL    string  `json:"pay_url"`
+	RefundNo  string  `json:"refund_no"`
+	// RefundedAmount 售后已部分退款的金额
+	RefundedAmount float64    `json:"refunded_amount"`
+	PaidAt         *time.Time `json:"paid_at"`
+	RefundedAt     *time.Time `json:"refunded_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// GeneratePaymentNo 生成支付流水号
+func GeneratePaymentNo() string {
+	return "P" + GenerateOrderNo()
+}
+
+// CreatePayment 创建支付记录
+func CreatePayment(payment *Payment) (int64, error) {
+	result, err := config.DB.Exec(`
+		INSERT INTO payments (payment_no, order_id, user_id, provider, amount, status)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, payment.PaymentNo, payment.OrderID, payment.UserID, payment.Provider, payment.Amount, PaymentPending)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// UpdatePaymentTrade 记录渠道返回的交易号和支付地址
+func UpdatePaymentTrade(id int64, tradeNo, payURL string) error {
+	_, err := config.DB.Exec(`
+		UPDATE payments SET trade_no = ?, pay_url = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?
+	`, tradeNo, payURL, id)
+	return err
+}
+
+const paymentColumns = `id, payment_no, order_id, user_id, provider, amount, status, COALESCE(trade_no, ''),
+	COALESCE(pay_url, ''), COALESCE(refund_no, ''), COALESCE(refunded_amount, 0), paid_at, refunded_at, created_at, updated_at`
+
+func scanPayment(scanner interface{ Scan(...interface{}) error }) (*Payment, error) {
+	p := &Payment{}
+	var paidAt, refundedAt sql.NullTime
+	err := scanner.Scan(&p.ID, &p.PaymentNo, &p.OrderID, &p.UserID, &p.Provider, &p.Amount, &p.Status, &p.TradeNo,
+		&p.PayURL, &p.RefundNo, &p.RefundedAmount, &paidAt, &refundedAt, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if paidAt.Valid {
+		p.PaidAt = &paidAt.Time
+	}
+	if refundedAt.Valid {
+		p.RefundedAt = &refundedAt.Time
+	}
+	return p, nil
+}
+
+// GetPaymentByNo 通过支付流水号获取支付记录
+func GetPaymentByNo(paymentNo string) (*Payment, error) {
+	return scanPayment(config.DB.QueryRow(`SELECT `+paymentColumns+` FROM payments WHERE payment_no = ?`, paymentNo))
+}
+
+// GetOrderPayments 获取订单的支付记录
+func GetOrderPayments(orderID int64) ([]*Payment, error) {
+	rows, err := config.DB.Query(`SELECT `+paymentColumns+` FROM payments WHERE order_id = ? ORDER BY id`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []*Payment
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, p)
+	}
+	return payments, nil
+}
+
+// CompletePayment 处理支付成功通知：支付记录标记为已支付，订单改为待发货。
+// 渠道可能重复通知，已处理过的支付记录直接返回，不重复修改订单；
+// 订单已取消或已由其他支付记录支付时，支付记录标记为待退款并返回 ErrOrderNotPayable，由调用方退款。
+// 退款失败时渠道重复通知，待退款的支付记录会再次返回 ErrOrderNotPayable，直到退款成功
+func CompletePayment(paymentNo, tradeNo string, amount float64) (*Payment, error) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	payment, err := scanPayment(tx.QueryRow(`SELECT `+paymentColumns+` FROM payments WHERE payment_no = ?`, paymentNo))
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status == PaymentRefunding {
+		return payment, ErrOrderNotPayable
+	}
+	if payment.Status != PaymentPending {
+		return payment, nil
+	}
+	if math.Abs(payment.Amount-amount) > 0.001 {
+		return nil, ErrPaymentAmountMismatch
+	}
+
+	now := time.Now()
+	result, err := tx.Exec(`
+		UPDATE orders SET status = 1, pay_type = ?, pay_time = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = 0
+	`, payment.Provider, now, payment.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	status := PaymentPaid
+	if orderPaid, _ := result.RowsAffected(); orderPaid == 0 {
+		status = PaymentRefunding
+	}
+
+	result, err = tx.Exec(`
+		UPDATE payments SET status = ?, trade_no = ?, paid_at = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?
+	`, status, tradeNo, now, payment.ID, PaymentPending)
+	if err != nil {
+		return nil, err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return payment, nil
+	}
+	payment.Status = status
+	payment.TradeNo = tradeNo
+	payment.PaidAt = &now
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	if status == PaymentRefunding {
+		return payment, ErrOrderNotPayable
+	}
+	return payment, nil
+}
+
+// MarkPaymentRefunded 将已支付或待退款的支付记录标记为已退款
+func MarkPaymentRefunded(id int64, refundNo string) error {
+	result, err := config.DB.Exec(`
+		UPDATE payments SET status = ?, refund_no = ?, refunded_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status IN (?, ?)
+	`, PaymentRefunded, refundNo, id, PaymentPaid, PaymentRefunding)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errors.New("支付记录状态不允许退款")
+	}
+	return nil
+}
+
+// GetPaidPayment 获取订单已完成的支付记录，订单没有线上支付记录时返回 sql.ErrNoRows
+func GetPaidPayment(orderID int64) (*Payment, error) {
+	return scanPayment(config.DB.QueryRow(`SELECT `+paymentColumns+` FROM payments WHERE order_id = ? AND status = ? ORDER BY id LIMIT 1`,
+		orderID, PaymentPaid))
+}
+
+// addPaymentRefundTx 累计售后退款金额，累计金额不能超过支付金额
+func addPaymentRefundTx(tx *sql.Tx, id int64, amount float64) error {
+	result, err := tx.Exec(`
+		UPDATE payments SET refunded_amount = refunded_amount + ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ? AND refunded_amount + ? <= amount + 0.001
+	`, amount, id, PaymentPaid, amount)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errors.New("退款金额超过支付金额")
+	}
+	return nil
+}
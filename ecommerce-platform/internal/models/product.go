@@ -2,7 +2,6 @@ package models
 
 import (
 	"ecommerce-platform/internal/config"
-	"strings"
 	"time"
 )
 
@@ -27,6 +26,8 @@ type Product struct {
 	Sales         int       `json:"sales"`
 	Images        string    `json:"images"`
 	Brand         string    `json:"brand"`
+	Weight             float64 `json:"weight"`               // 重量（千克），按重量计算运费时使用
+	ShippingTemplateID int64   `json:"shipping_template_id"` // 运费模板，0 为商家默认模板
 	Status        int       `json:"status"`
 	Rating        float64   `json:"rating"`
 	RatingCount   int       `json:"rating_count"`
@@ -35,6 +36,9 @@ type Product struct {
 	// 关联信息
 	SellerName   string `json:"seller_name,omitempty"`
 	CategoryName string `json:"category_name,omitempty"`
+	// 多规格信息
+	Options []*ProductOption `json:"options,omitempty"`
+	SKUs    []*ProductSKU    `json:"skus,omitempty"`
 }
 
 // CreateCategory 创建分类
@@ -91,26 +95,39 @@ func UpdateCategory(category *Category) error {
 		UPDATE categories SET name = ?, parent_id = ?, icon = ?, sort_order = ?
 		WHERE id = ?
 	`, category.Name, category.ParentID, category.Icon, category.SortOrder, category.ID)
-	return err
+	if err != nil {
+		return err
+	}
+	return reindexCategory(category.ID)
 }
 
 // DeleteCategory 删除分类
 func DeleteCategory(id int64) error {
 	_, err := config.DB.Exec(`DELETE FROM categories WHERE id = ?`, id)
-	return err
+	if err != nil {
+		return err
+	}
+	return reindexCategory(id)
 }
 
 // CreateProduct 创建商品
 func CreateProduct(product *Product) (int64, error) {
 	result, err := config.DB.Exec(`
-		INSERT INTO products (seller_id, category_id, name, description, price, original_price, stock, images, brand, status)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO products (seller_id, category_id, name, description, price, original_price, stock, images, brand,
+			weight, shipping_template_id, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`, product.SellerID, product.CategoryID, product.Name, product.Description,
-		product.Price, product.OriginalPrice, product.Stock, product.Images, product.Brand, product.Status)
+		product.Price, product.OriginalPrice, product.Stock, product.Images, product.Brand,
+		product.Weight, product.ShippingTemplateID, product.Status)
 	if err != nil {
 		return 0, err
 	}
-	return result.LastInsertId()
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	syncProductIndex(id)
+	return id, nil
 }
 
 // GetProductByID 通过ID获取商品
@@ -119,7 +136,8 @@ func GetProductByID(id int64) (*Product, error) {
 	err := config.DB.QueryRow(`
 		SELECT p.id, p.seller_id, COALESCE(p.category_id, 0), p.name, COALESCE(p.description, ''), 
 			p.price, COALESCE(p.original_price, 0), p.stock, COALESCE(p.sales, 0), COALESCE(p.images, ''), 
-			COALESCE(p.brand, ''), p.status, COALESCE(p.rating, 5.0), COALESCE(p.rating_count, 0),
+			COALESCE(p.brand, ''), COALESCE(p.weight, 0), COALESCE(p.shipping_template_id, 0),
+			p.status, COALESCE(p.rating, 5.0), COALESCE(p.rating_count, 0),
 			p.created_at, p.updated_at, COALESCE(s.shop_name, ''), COALESCE(c.name, '')
 		FROM products p
 		LEFT JOIN sellers s ON p.seller_id = s.id
@@ -127,7 +145,7 @@ func GetProductByID(id int64) (*Product, error) {
 		WHERE p.id = ?
 	`, id).Scan(&product.ID, &product.SellerID, &product.CategoryID, &product.Name, &product.Description,
 		&product.Price, &product.OriginalPrice, &product.Stock, &product.Sales, &product.Images,
-		&product.Brand, &product.Status, &product.Rating, &product.RatingCount,
+		&product.Brand, &product.Weight, &product.ShippingTemplateID, &product.Status, &product.Rating, &product.RatingCount,
 		&product.CreatedAt, &product.UpdatedAt, &product.SellerName, &product.CategoryName)
 	if err != nil {
 		return nil, err
@@ -135,21 +153,47 @@ func GetProductByID(id int64) (*Product, error) {
 	return product, nil
 }
 
-// UpdateProduct 更新商品
+// UpdateProduct 更新商品，多规格商品的价格和库存以SKU为准
 func UpdateProduct(product *Product) error {
 	_, err := config.DB.Exec(`
 		UPDATE products SET category_id = ?, name = ?, description = ?, price = ?,
-			original_price = ?, stock = ?, images = ?, brand = ?, status = ?, updated_at = CURRENT_TIMESTAMP
+			original_price = ?, stock = ?, images = ?, brand = ?, weight = ?, shipping_template_id = ?,
+			status = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?
 	`, product.CategoryID, product.Name, product.Description, product.Price,
-		product.OriginalPrice, product.Stock, product.Images, product.Brand, product.Status, product.ID)
-	return err
+		product.OriginalPrice, product.Stock, product.Images, product.Brand, product.Weight,
+		product.ShippingTemplateID, product.Status, product.ID)
+	if err != nil {
+		return err
+	}
+	syncProductIndex(product.ID)
+	return syncProductSKUSummary(config.DB, product.ID)
 }
 
 // DeleteProduct 删除商品
 func DeleteProduct(id int64) error {
 	_, err := config.DB.Exec(`DELETE FROM products WHERE id = ?`, id)
-	return err
+	if err != nil {
+		return err
+	}
+	syncProductIndex(id)
+	return nil
+}
+
+// IsImageReferenced 检查图片是否仍被商品、SKU、订单商品快照、评价或售后凭证引用
+func IsImageReferenced(url string) (bool, error) {
+	var count int
+	err := config.DB.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM products WHERE images LIKE ?)
+			+ (SELECT COUNT(*) FROM product_skus WHERE image = ?)
+			+ (SELECT COUNT(*) FROM order_items WHERE product_image = ?)
+			+ (SELECT COUNT(*) FROM reviews WHERE images LIKE ?)
+			+ (SELECT COUNT(*) FROM after_sales WHERE images LIKE ?)
+	`, "%"+url+"%", url, url, "%"+url+"%", "%"+url+"%").Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
 }
 
 // UpdateProductStatus 更新商品状态
@@ -160,83 +204,15 @@ func UpdateProductStatus(id int64, status int) error {
 
 // GetProducts 获取商品列表
 func GetProducts(page, pageSize int, categoryID int64, sellerID int64, keyword string, status int, sortBy string) ([]*Product, int, error) {
-	offset := (page - 1) * pageSize
-	
-	// 构建查询条件
-	conditions := []string{"1=1"}
-	args := []interface{}{}
-	
-	if categoryID > 0 {
-		conditions = append(conditions, "p.category_id = ?")
-		args = append(args, categoryID)
-	}
-	if sellerID > 0 {
-		conditions = append(conditions, "p.seller_id = ?")
-		args = append(args, sellerID)
-	}
-	if keyword != "" {
-		conditions = append(conditions, "(p.name LIKE ? OR p.description LIKE ?)")
-		args = append(args, "%"+keyword+"%", "%"+keyword+"%")
-	}
-	if status >= 0 {
-		conditions = append(conditions, "p.status = ?")
-		args = append(args, status)
-	}
-	
-	whereClause := strings.Join(conditions, " AND ")
-	
-	// 统计总数
-	var total int
-	countSQL := `SELECT COUNT(*) FROM products p WHERE ` + whereClause
-	err := config.DB.QueryRow(countSQL, args...).Scan(&total)
-	if err != nil {
-		return nil, 0, err
-	}
-	
-	// 排序
-	orderClause := "ORDER BY p.created_at DESC"
-	switch sortBy {
-	case "price_asc":
-		orderClause = "ORDER BY p.price ASC"
-	case "price_desc":
-		orderClause = "ORDER BY p.price DESC"
-	case "sales":
-		orderClause = "ORDER BY p.sales DESC"
-	case "rating":
-		orderClause = "ORDER BY p.rating DESC"
-	}
-	
-	// 查询商品
-	querySQL := `
-		SELECT p.id, p.seller_id, COALESCE(p.category_id, 0), p.name, COALESCE(p.description, ''), 
-			p.price, COALESCE(p.original_price, 0), p.stock, COALESCE(p.sales, 0), COALESCE(p.images, ''), 
-			COALESCE(p.brand, ''), p.status, COALESCE(p.rating, 5.0), COALESCE(p.rating_count, 0),
-			p.created_at, p.updated_at, COALESCE(s.shop_name, ''), COALESCE(c.name, '')
-		FROM products p
-		LEFT JOIN sellers s ON p.seller_id = s.id
-		LEFT JOIN categories c ON p.category_id = c.id
-		WHERE ` + whereClause + ` ` + orderClause + ` LIMIT ? OFFSET ?`
-	
-	args = append(args, pageSize, offset)
-	rows, err := config.DB.Query(querySQL, args...)
-	if err != nil {
-		return nil, 0, err
-	}
-	defer rows.Close()
-
-	var products []*Product
-	for rows.Next() {
-		product := &Product{}
-		err := rows.Scan(&product.ID, &product.SellerID, &product.CategoryID, &product.Name, &product.Description,
-			&product.Price, &product.OriginalPrice, &product.Stock, &product.Sales, &product.Images,
-			&product.Brand, &product.Status, &product.Rating, &product.RatingCount,
-			&product.CreatedAt, &product.UpdatedAt, &product.SellerName, &product.CategoryName)
-		if err != nil {
-			return nil, 0, err
-		}
-		products = append(products, product)
-	}
-	return products, total, nil
+	return SearchProducts(&ProductQuery{
+		Page:       page,
+		PageSize:   pageSize,
+		CategoryID: categoryID,
+		SellerID:   sellerID,
+		Keyword:    keyword,
+		Status:     status,
+		SortBy:     sortBy,
+	})
 }
 
 // UpdateProductStock 更新商品库存
@@ -0,0 +1,516 @@
+package models
+
+import (
+	"database/sql"
+	"ecommerce-platform/internal/config"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ProductQuery 商品列表查询条件
+type ProductQuery struct {
+	Page       int
+	PageSize   int
+	CategoryID int64
+	SellerID   int64
+	Keyword    string
+	Brand      string
+	MinPrice   float64 // 价格下限（含），0 为不限
+	MaxPrice   float64 // 价格上限（不含），0 为不限
+	MinRating  float64 // 最低评分，0 为不限
+	Status     int     // -1 为全部
+	SortBy     string  // relevance、price_asc、price_desc、sales、rating、newest，默认有关键词时按相关度
+}
+
+// CategoryFacet 分类分面
+type CategoryFacet struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// BrandFacet 品牌分面
+type BrandFacet struct {
+	Brand string `json:"brand"`
+	Count int    `json:"count"`
+}
+
+// PriceFacet 价格区间分面，Max 为 0 表示不限
+type PriceFacet struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Count int     `json:"count"`
+}
+
+// RatingFacet 评分分面，统计评分不低于 MinRating 的商品数
+type RatingFacet struct {
+	MinRating float64 `json:"min_rating"`
+	Count     int     `json:"count"`
+}
+
+// ProductFacets 搜索结果的分面统计。每个分面的数量不受该分面自身的筛选条件影响，
+// 选中某个品牌后仍能看到其他品牌的商品数
+type ProductFacets struct {
+	Categories []*CategoryFacet `json:"categories"`
+	Brands     []*BrandFacet    `json:"brands"`
+	Prices     []*PriceFacet    `json:"prices"`
+	Ratings    []*RatingFacet   `json:"ratings"`
+}
+
+// priceRanges 价格分面的区间
+var priceRanges = []PriceFacet{{0, 100, 0}, {100, 500, 0}, {500, 1000, 0}, {1000, 5000, 0}, {5000, 0, 0}}
+
+// ratingLevels 评分分面的档位
+var ratingLevels = []float64{4.5, 4, 3}
+
+// maxBrandFacets 品牌分面最多返回的品牌数
+const maxBrandFacets = 20
+
+// searchTokens 将文本切分为索引词：中文按相邻两字切分（单字保留），字母和数字按连续串切分并转为小写。
+// FTS5 的 unicode61 分词器不能切分中文，索引和查询都先经过该函数处理
+func searchTokens(text string) []string {
+	var tokens []string
+	var han, word []rune
+
+	flushHan := func() {
+		if len(han) == 1 {
+			tokens = append(tokens, string(han))
+		}
+		for i := 0; i+1 < len(han); i++ {
+			tokens = append(tokens, string(han[i:i+2]))
+		}
+		han = han[:0]
+	}
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+		}
+		word = word[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			word = append(word, unicode.ToLower(r))
+		default:
+			flushHan()
+			flushWord()
+		}
+	}
+	flushHan()
+	flushWord()
+	return tokens
+}
+
+// matchQuery 将搜索关键词转换为 FTS5 查询：空格分隔的每个词都要匹配，词内按短语匹配。
+// prefix 为 true 时最后一个词按前缀匹配，用于搜索建议。
+// 关键词含单个汉字时索引中没有对应的词，返回空字符串，由调用方改用 LIKE 查询
+func matchQuery(keyword string, prefix bool) string {
+	var phrases []string
+	for _, term := range strings.Fields(keyword) {
+		tokens := searchTokens(term)
+		if len(tokens) == 0 {
+			continue
+		}
+		for _, token := range tokens {
+			r, _ := utf8.DecodeRuneInString(token)
+			if utf8.RuneCountInString(token) == 1 && unicode.Is(unicode.Han, r) {
+				return ""
+			}
+		}
+		phrases = append(phrases, `"`+strings.Join(tokens, " ")+`"`)
+	}
+	if len(phrases) == 0 {
+		return ""
+	}
+	if prefix {
+		phrases[len(phrases)-1] += "*"
+	}
+	return strings.Join(phrases, " ")
+}
+
+// useFTS 关键词是否使用全文索引查询，返回 FTS5 查询语句
+func useFTS(keyword string) (string, bool) {
+	if !config.FTSEnabled || keyword == "" {
+		return "", false
+	}
+	match := matchQuery(keyword, false)
+	return match, match != ""
+}
+
+// where 生成查询条件，skip 指定的条件不参与（统计该分面时使用）
+func (q *ProductQuery) where(skip string) (string, []interface{}) {
+	conditions := []string{"1=1"}
+	args := []interface{}{}
+
+	if q.CategoryID > 0 && skip != "category" {
+		conditions = append(conditions, "p.category_id = ?")
+		args = append(args, q.CategoryID)
+	}
+	if q.SellerID > 0 {
+		conditions = append(conditions, "p.seller_id = ?")
+		args = append(args, q.SellerID)
+	}
+	if q.Keyword != "" && skip != "keyword" {
+		if match, ok := useFTS(q.Keyword); ok {
+			conditions = append(conditions, "p.id IN (SELECT rowid FROM products_fts WHERE products_fts MATCH ?)")
+			args = append(args, match)
+		} else {
+			like := "%" + q.Keyword + "%"
+			conditions = append(conditions, "(p.name LIKE ? OR p.description LIKE ? OR p.brand LIKE ?)")
+			args = append(args, like, like, like)
+		}
+	}
+	if q.Brand != "" && skip != "brand" {
+		conditions = append(conditions, "p.brand = ?")
+		args = append(args, q.Brand)
+	}
+	if skip != "price" {
+		if q.MinPrice > 0 {
+			conditions = append(conditions, "p.price >= ?")
+			args = append(args, q.MinPrice)
+		}
+		if q.MaxPrice > 0 {
+			conditions = append(conditions, "p.price < ?")
+			args = append(args, q.MaxPrice)
+		}
+	}
+	if q.MinRating > 0 && skip != "rating" {
+		conditions = append(conditions, "COALESCE(p.rating, 5.0) >= ?")
+		args = append(args, q.MinRating)
+	}
+	if q.Status >= 0 {
+		conditions = append(conditions, "p.status = ?")
+		args = append(args, q.Status)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// SearchProducts 按条件查询商品，有关键词时默认按相关度排序
+func SearchProducts(q *ProductQuery) ([]*Product, int, error) {
+	whereClause, args := q.where("")
+
+	var total int
+	err := config.DB.QueryRow(`SELECT COUNT(*) FROM products p WHERE `+whereClause, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	from := `products p`
+	var joinArgs []interface{}
+	orderClause := "ORDER BY p.created_at DESC, p.id DESC"
+	switch q.SortBy {
+	case "price_asc":
+		orderClause = "ORDER BY p.price ASC, p.id DESC"
+	case "price_desc":
+		orderClause = "ORDER BY p.price DESC, p.id DESC"
+	case "sales":
+		orderClause = "ORDER BY p.sales DESC, p.id DESC"
+	case "rating":
+		orderClause = "ORDER BY p.rating DESC, p.id DESC"
+	case "", "relevance":
+		// 按相关度排序：名称、品牌、分类的权重高于描述，相关度相同时销量高的在前
+		if match, ok := useFTS(q.Keyword); ok {
+			from = `products p JOIN (
+				SELECT rowid AS id, bm25(products_fts, 10.0, 1.0, 5.0, 3.0) AS score
+				FROM products_fts WHERE products_fts MATCH ?
+			) f ON f.id = p.id`
+			joinArgs = append(joinArgs, match)
+			whereClause, args = q.where("keyword")
+			orderClause = "ORDER BY f.score, p.sales DESC, p.id DESC"
+		}
+	}
+
+	querySQL := `
+		SELECT p.id, p.seller_id, COALESCE(p.category_id, 0), p.name, COALESCE(p.description, ''),
+			p.price, COALESCE(p.original_price, 0), p.stock, COALESCE(p.sales, 0), COALESCE(p.images, ''),
+			COALESCE(p.brand, ''), p.status, COALESCE(p.rating, 5.0), COALESCE(p.rating_count, 0),
+			p.created_at, p.updated_at, COALESCE(s.shop_name, ''), COALESCE(c.name, '')
+		FROM ` + from + `
+		LEFT JOIN sellers s ON p.seller_id = s.id
+		LEFT JOIN categories c ON p.category_id = c.id
+		WHERE ` + whereClause + ` ` + orderClause + ` LIMIT ? OFFSET ?`
+
+	args = append(joinArgs, args...)
+	args = append(args, q.PageSize, (q.Page-1)*q.PageSize)
+	rows, err := config.DB.Query(querySQL, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var products []*Product
+	for rows.Next() {
+		product := &Product{}
+		err := rows.Scan(&product.ID, &product.SellerID, &product.CategoryID, &product.Name, &product.Description,
+			&product.Price, &product.OriginalPrice, &product.Stock, &product.Sales, &product.Images,
+			&product.Brand, &product.Status, &product.Rating, &product.RatingCount,
+			&product.CreatedAt, &product.UpdatedAt, &product.SellerName, &product.CategoryName)
+		if err != nil {
+			return nil, 0, err
+		}
+		products = append(products, product)
+	}
+	return products, total, nil
+}
+
+// GetProductFacets 统计查询结果按分类、品牌、价格区间和评分的商品数
+func GetProductFacets(q *ProductQuery) (*ProductFacets, error) {
+	facets := &ProductFacets{
+		Categories: []*CategoryFacet{},
+		Brands:     []*BrandFacet{},
+		Prices:     []*PriceFacet{},
+		Ratings:    []*RatingFacet{},
+	}
+
+	whereClause, args := q.where("category")
+	rows, err := config.DB.Query(`
+		SELECT COALESCE(p.category_id, 0), COALESCE(c.name, ''), COUNT(*)
+		FROM products p LEFT JOIN categories c ON p.category_id = c.id
+		WHERE `+whereClause+`
+		GROUP BY COALESCE(p.category_id, 0) ORDER BY COUNT(*) DESC, COALESCE(p.category_id, 0)
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		f := &CategoryFacet{}
+		if err := rows.Scan(&f.ID, &f.Name, &f.Count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		facets.Categories = append(facets.Categories, f)
+	}
+	rows.Close()
+
+	whereClause, args = q.where("brand")
+	args = append(args, maxBrandFacets)
+	rows, err = config.DB.Query(`
+		SELECT p.brand, COUNT(*) FROM products p
+		WHERE `+whereClause+` AND COALESCE(p.brand, '') != ''
+		GROUP BY p.brand ORDER BY COUNT(*) DESC, p.brand LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		f := &BrandFacet{}
+		if err := rows.Scan(&f.Brand, &f.Count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		facets.Brands = append(facets.Brands, f)
+	}
+	rows.Close()
+
+	// 价格区间和评分各用一条查询统计所有档位
+	var sums []string
+	for _, r := range priceRanges {
+		cond := fmt.Sprintf("p.price >= %g", r.Min)
+		if r.Max > 0 {
+			cond += fmt.Sprintf(" AND p.price < %g", r.Max)
+		}
+		sums = append(sums, "COALESCE(SUM(CASE WHEN "+cond+" THEN 1 ELSE 0 END), 0)")
+	}
+	whereClause, args = q.where("price")
+	counts := make([]int, len(priceRanges))
+	if err := scanCounts(`SELECT `+strings.Join(sums, ", ")+` FROM products p WHERE `+whereClause, args, counts); err != nil {
+		return nil, err
+	}
+	for i, r := range priceRanges {
+		if counts[i] > 0 {
+			facets.Prices = append(facets.Prices, &PriceFacet{Min: r.Min, Max: r.Max, Count: counts[i]})
+		}
+	}
+
+	sums = sums[:0]
+	for _, level := range ratingLevels {
+		sums = append(sums, fmt.Sprintf("COALESCE(SUM(CASE WHEN COALESCE(p.rating, 5.0) >= %g THEN 1 ELSE 0 END), 0)", level))
+	}
+	whereClause, args = q.where("rating")
+	counts = make([]int, len(ratingLevels))
+	if err := scanCounts(`SELECT `+strings.Join(sums, ", ")+` FROM products p WHERE `+whereClause, args, counts); err != nil {
+		return nil, err
+	}
+	for i, level := range ratingLevels {
+		if counts[i] > 0 {
+			facets.Ratings = append(facets.Ratings, &RatingFacet{MinRating: level, Count: counts[i]})
+		}
+	}
+
+	return facets, nil
+}
+
+func scanCounts(query string, args []interface{}, counts []int) error {
+	dest := make([]interface{}, len(counts))
+	for i := range counts {
+		dest[i] = &counts[i]
+	}
+	return config.DB.QueryRow(query, args...).Scan(dest...)
+}
+
+// SuggestProducts 搜索建议：返回名称与输入内容匹配的上架商品名称，最后一个词按前缀匹配
+func SuggestProducts(keyword string, limit int) ([]string, error) {
+	keyword = strings.TrimSpace(keyword)
+	if keyword == "" {
+		return []string{}, nil
+	}
+
+	var rows *sql.Rows
+	var err error
+	if match := matchQuery(keyword, true); config.FTSEnabled && match != "" {
+		rows, err = config.DB.Query(`
+			SELECT p.name FROM products_fts f JOIN products p ON p.id = f.rowid
+			WHERE products_fts MATCH ? AND p.status = 1
+			ORDER BY bm25(products_fts, 10.0, 1.0, 5.0, 3.0), p.sales DESC LIMIT ?
+		`, "name : ("+match+")", limit)
+	} else {
+		rows, err = config.DB.Query(`
+			SELECT name FROM products WHERE name LIKE ? AND status = 1 ORDER BY sales DESC LIMIT ?
+		`, "%"+keyword+"%", limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	seen := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// indexProduct 更新商品的全文索引，商品已删除时移除索引
+func indexProduct(id int64) error {
+	if !config.FTSEnabled {
+		return nil
+	}
+
+	var name, description, brand, category string
+	err := config.DB.QueryRow(`
+		SELECT p.name, COALESCE(p.description, ''), COALESCE(p.brand, ''), COALESCE(c.name, '')
+		FROM products p LEFT JOIN categories c ON p.category_id = c.id
+		WHERE p.id = ?
+	`, id).Scan(&name, &description, &brand, &category)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if _, err := config.DB.Exec(`DELETE FROM products_fts WHERE rowid = ?`, id); err != nil {
+		return err
+	}
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	_, err = config.DB.Exec(`
+		INSERT INTO products_fts (rowid, name, description, brand, category) VALUES (?, ?, ?, ?, ?)
+	`, id, strings.Join(searchTokens(name), " "), strings.Join(searchTokens(description), " "),
+		strings.Join(searchTokens(brand), " "), strings.Join(searchTokens(category), " "))
+	return err
+}
+
+// syncProductIndex 商品变更后更新全文索引。索引失败不影响商品本身的保存，可通过重建索引修复
+func syncProductIndex(id int64) {
+	if err := indexProduct(id); err != nil {
+		log.Printf("更新商品 %d 的搜索索引失败: %v", id, err)
+	}
+}
+
+// reindexCategory 分类名称变更或分类删除后更新该分类下商品的索引
+func reindexCategory(categoryID int64) error {
+	if !config.FTSEnabled {
+		return nil
+	}
+
+	rows, err := config.DB.Query(`SELECT id FROM products WHERE category_id = ?`, categoryID)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if err := indexProduct(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SyncSearchIndex 索引与商品表数量不一致时（首次启用、直接写库导入商品等）重建全部商品的全文索引
+func SyncSearchIndex() error {
+	if !config.FTSEnabled {
+		return nil
+	}
+
+	var products, indexed int
+	err := config.DB.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM products), (SELECT COUNT(*) FROM products_fts)
+	`).Scan(&products, &indexed)
+	if err != nil {
+		return err
+	}
+	if products == indexed {
+		return nil
+	}
+	return RebuildSearchIndex()
+}
+
+// RebuildSearchIndex 重建全部商品的全文索引
+func RebuildSearchIndex() error {
+	if !config.FTSEnabled {
+		return nil
+	}
+
+	if _, err := config.DB.Exec(`DELETE FROM products_fts`); err != nil {
+		return err
+	}
+
+	rows, err := config.DB.Query(`SELECT id FROM products`)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if err := indexProduct(id); err != nil {
+			return err
+		}
+	}
+	log.Printf("已重建 %d 个商品的搜索索引", len(ids))
+	return nil
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"ecommerce-platform/internal/config"
+	"ecommerce-platform/internal/testutil"
+	"reflect"
+	"testing"
+)
+
+func TestSearchTokens(t *testing.T) {
+	cases := map[string][]string{
+		"索尼 WH-1000XM5 降噪耳机": {"索尼", "wh", "1000xm5", "降噪", "噪耳", "耳机"},
+		"iPhone 15":          {"iphone", "15"},
+		"书":                  {"书"},
+	}
+	for text, want := range cases {
+		if got := searchTokens(text); !reflect.DeepEqual(got, want) {
+			t.Errorf("searchTokens(%q) = %q，应为 %q", text, got, want)
+		}
+	}
+
+	if got := matchQuery("降噪耳机 sony", true); got != `"降噪 噪耳 耳机" "sony"*` {
+		t.Errorf("matchQuery 结果错误: %s", got)
+	}
+	if got := matchQuery("书", false); got != "" {
+		t.Errorf("单个汉字应改用 LIKE 查询，实际 %s", got)
+	}
+}
+
+// createSearchProducts 创建用于搜索测试的商品，返回商品ID
+func createSearchProducts(t *testing.T) map[string]int64 {
+	t.Helper()
+	testutil.MustExec(t, `INSERT INTO categories (id, name) VALUES (2, '手机数码'), (3, '家用电器')`)
+
+	ids := map[string]int64{}
+	for _, p := range []*Product{
+		{Name: "索尼 WH-1000XM5 耳机", Description: "行业领先降噪", Brand: "索尼", CategoryID: 2, Price: 2699},
+		{Name: "小米手环", Description: "支持蓝牙耳机连接", Brand: "小米", CategoryID: 2, Price: 299},
+		{Name: "戴森吸尘器", Description: "大吸力", Brand: "戴森", CategoryID: 3, Price: 5999},
+		{Name: "已下架耳机", Brand: "索尼", CategoryID: 2, Price: 99, Status: 0},
+	} {
+		p.SellerID = 1
+		if p.Name != "已下架耳机" {
+			p.Status = 1
+		}
+		id, err := CreateProduct(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[p.Name] = id
+	}
+	return ids
+}
+
+func TestSearchProductsWithFacets(t *testing.T) {
+	setupTestDB(t)
+	ids := createSearchProducts(t)
+
+	q := &ProductQuery{Page: 1, PageSize: 10, Keyword: "耳机", Status: 1}
+	products, total, err := SearchProducts(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(products) != 2 {
+		t.Fatalf("应搜索到 2 个上架商品，实际 %d", total)
+	}
+	if config.FTSEnabled && products[0].ID != ids["索尼 WH-1000XM5 耳机"] {
+		t.Errorf("名称匹配的商品应排在描述匹配的商品前面，实际第一个为 %q", products[0].Name)
+	}
+
+	// 品牌筛选不影响品牌分面本身的统计
+	q.Brand = "小米"
+	products, total, _ = SearchProducts(q)
+	if total != 1 || products[0].ID != ids["小米手环"] {
+		t.Errorf("按品牌筛选应只返回小米手环，实际 %d 个", total)
+	}
+	facets, err := GetProductFacets(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(facets.Brands) != 2 {
+		t.Errorf("品牌分面应包含索尼和小米，实际 %d 个", len(facets.Brands))
+	}
+	if len(facets.Categories) != 1 || facets.Categories[0].Name != "手机数码" || facets.Categories[0].Count != 1 {
+		t.Errorf("分类分面错误: %+v", facets.Categories)
+	}
+	if len(facets.Prices) != 1 || facets.Prices[0].Min != 100 || facets.Prices[0].Count != 1 {
+		t.Errorf("价格分面错误: %+v", facets.Prices)
+	}
+
+	// 商品修改和删除后索引同步更新
+	p, _ := GetProductByID(ids["戴森吸尘器"])
+	p.Name = "戴森无线耳机"
+	if err := UpdateProduct(p); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteProduct(ids["小米手环"]); err != nil {
+		t.Fatal(err)
+	}
+	_, total, _ = SearchProducts(&ProductQuery{Page: 1, PageSize: 10, Keyword: "耳机", Status: 1})
+	if total != 2 {
+		t.Errorf("修改和删除商品后应搜索到 2 个商品，实际 %d", total)
+	}
+
+	// 按分类名称搜索
+	_, total, _ = SearchProducts(&ProductQuery{Page: 1, PageSize: 10, Keyword: "家用电器", Status: 1})
+	if config.FTSEnabled && total != 1 {
+		t.Errorf("按分类名称应搜索到 1 个商品，实际 %d", total)
+	}
+
+	names, err := SuggestProducts("索尼 WH", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "索尼 WH-1000XM5 耳机" {
+		t.Errorf("搜索建议错误: %q", names)
+	}
+}
+
+func TestRebuildSearchIndex(t *testing.T) {
+	setupTestDB(t)
+	if !config.FTSEnabled {
+		t.Skip("SQLite 未启用 FTS5，使用 -tags sqlite_fts5 运行")
+	}
+
+	// 直接写库导入的商品没有索引，重建后可以搜索到
+	testutil.MustExec(t, `INSERT INTO products (seller_id, category_id, name, price, status) VALUES (1, 1, '蓝牙音箱', 199, 1)`)
+	if _, total, _ := SearchProducts(&ProductQuery{Page: 1, PageSize: 10, Keyword: "音箱", Status: 1}); total != 0 {
+		t.Fatalf("重建前不应搜索到，实际 %d", total)
+	}
+	if err := SyncSearchIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if _, total, _ := SearchProducts(&ProductQuery{Page: 1, PageSize: 10, Keyword: "音箱", Status: 1}); total != 1 {
+		t.Errorf("重建后应搜索到 1 个商品，实际 %d", total)
+	}
+}
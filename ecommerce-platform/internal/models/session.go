@@ -0,0 +1,74 @@
+package models
+
+import (
+	"ecommerce-platform/internal/config"
+	"time"
+)
+
+// UserSession 登录会话，数据库中只保存令牌的哈希值
+type UserSession struct {
+	ID         int64     `json:"id"`
+	TokenHash  string    `json:"-"`
+	UserID     int64     `json:"user_id"`
+	Username   string    `json:"username"`
+	Role       string    `json:"role"`
+	SellerID   int64     `json:"seller_id"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CreateUserSession 保存会话
+func CreateUserSession(s *UserSession) error {
+	_, err := config.DB.Exec(`
+		INSERT INTO sessions (token_hash, user_id, username, role, seller_id, expires_at, last_seen_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, s.TokenHash, s.UserID, s.Username, s.Role, s.SellerID, s.ExpiresAt.UTC(), time.Now().UTC())
+	return err
+}
+
+// GetUserSession 通过令牌哈希获取会话，不检查是否过期
+func GetUserSession(tokenHash string) (*UserSession, error) {
+	s := &UserSession{}
+	err := config.DB.QueryRow(`
+		SELECT id, token_hash, user_id, username, role, seller_id, expires_at, last_seen_at, created_at
+		FROM sessions WHERE token_hash = ?
+	`, tokenHash).Scan(&s.ID, &s.TokenHash, &s.UserID, &s.Username, &s.Role, &s.SellerID,
+		&s.ExpiresAt, &s.LastSeenAt, &s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// RefreshUserSession 延长会话有效期
+func RefreshUserSession(tokenHash string, expiresAt time.Time) error {
+	_, err := config.DB.Exec(`
+		UPDATE sessions SET expires_at = ?, last_seen_at = ? WHERE token_hash = ?
+	`, expiresAt.UTC(), time.Now().UTC(), tokenHash)
+	return err
+}
+
+// DeleteUserSession 删除会话
+func DeleteUserSession(tokenHash string) error {
+	_, err := config.DB.Exec(`DELETE FROM sessions WHERE token_hash = ?`, tokenHash)
+	return err
+}
+
+// DeleteUserSessions 删除用户的所有会话，返回删除的数量
+func DeleteUserSessions(userID int64) (int64, error) {
+	result, err := config.DB.Exec(`DELETE FROM sessions WHERE user_id = ?`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DeleteExpiredSessions 删除已过期的会话，返回删除的数量
+func DeleteExpiredSessions() (int64, error) {
+	result, err := config.DB.Exec(`DELETE FROM sessions WHERE expires_at < ?`, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
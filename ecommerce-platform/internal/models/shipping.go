@@ -0,0 +1,302 @@
+package models
+
+import (
+	"database/sql"
+	"ecommerce-platform/internal/config"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// 运费模板计费方式
+const (
+	ChargeByItem   = "item"   // 按件数
+	ChargeByWeight = "weight" // 按重量（千克）
+)
+
+// ShippingTemplate 商家运费模板
+type ShippingTemplate struct {
+	ID            int64           `json:"id"`
+	SellerID      int64           `json:"seller_id"`
+	Name          string          `json:"name"`
+	ChargeType    string          `json:"charge_type"`
+	FreeThreshold float64         `json:"free_threshold"` // 商品金额满多少包邮，0 为不包邮
+	IsDefault     int             `json:"is_default"`     // 商品未指定模板时使用商家的默认模板
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	Rules         []*ShippingRule `json:"rules"`
+}
+
+// ShippingRule 运费规则：首件(首重)费用加续件(续重)费用。
+// Provinces 为空的规则是默认规则，适用于未单独设置的省份
+type ShippingRule struct {
+	ID             int64    `json:"id"`
+	TemplateID     int64    `json:"template_id"`
+	Provinces      []string `json:"provinces"`
+	FirstUnit      float64  `json:"first_unit"`
+	FirstFee       float64  `json:"first_fee"`
+	AdditionalUnit float64  `json:"additional_unit"`
+	AdditionalFee  float64  `json:"additional_fee"`
+}
+
+// RuleFor 获取省份适用的运费规则，没有单独设置时使用默认规则
+func (t *ShippingTemplate) RuleFor(province string) *ShippingRule {
+	var fallback *ShippingRule
+	for _, rule := range t.Rules {
+		if len(rule.Provinces) == 0 {
+			fallback = rule
+			continue
+		}
+		for _, p := range rule.Provinces {
+			if p == province {
+				return rule
+			}
+		}
+	}
+	return fallback
+}
+
+// Fee 按数量（件数或重量）计算运费
+func (rule *ShippingRule) Fee(quantity float64) float64 {
+	fee := rule.FirstFee
+	if quantity > rule.FirstUnit && rule.AdditionalUnit > 0 {
+		fee += math.Ceil((quantity-rule.FirstUnit)/rule.AdditionalUnit) * rule.AdditionalFee
+	}
+	return fee
+}
+
+// CreateShippingTemplate 创建运费模板及规则
+func CreateShippingTemplate(template *ShippingTemplate) (int64, error) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if template.IsDefault == 1 {
+		if _, err = tx.Exec(`UPDATE shipping_templates SET is_default = 0 WHERE seller_id = ?`, template.SellerID); err != nil {
+			return 0, err
+		}
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO shipping_templates (seller_id, name, charge_type, free_threshold, is_default)
+		VALUES (?, ?, ?, ?, ?)
+	`, template.SellerID, template.Name, template.ChargeType, template.FreeThreshold, template.IsDefault)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = saveShippingRules(tx, id, template.Rules); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// UpdateShippingTemplate 更新运费模板，规则整体替换
+func UpdateShippingTemplate(template *ShippingTemplate) error {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if template.IsDefault == 1 {
+		if _, err = tx.Exec(`UPDATE shipping_templates SET is_default = 0 WHERE seller_id = ?`, template.SellerID); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE shipping_templates SET name = ?, charge_type = ?, free_threshold = ?, is_default = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, template.Name, template.ChargeType, template.FreeThreshold, template.IsDefault, template.ID)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(`DELETE FROM shipping_rules WHERE template_id = ?`, template.ID); err != nil {
+		return err
+	}
+	if err = saveShippingRules(tx, template.ID, template.Rules); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func saveShippingRules(tx *sql.Tx, templateID int64, rules []*ShippingRule) error {
+	for _, rule := range rules {
+		_, err := tx.Exec(`
+			INSERT INTO shipping_rules (template_id, provinces, first_unit, first_fee, additional_unit, additional_fee)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, templateID, strings.Join(rule.Provinces, ","), rule.FirstUnit, rule.FirstFee, rule.AdditionalUnit, rule.AdditionalFee)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteShippingTemplate 删除运费模板，使用该模板的商品改用商家默认模板
+func DeleteShippingTemplate(id int64) error {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`UPDATE products SET shipping_template_id = 0 WHERE shipping_template_id = ?`,
+		`DELETE FROM shipping_rules WHERE template_id = ?`,
+		`DELETE FROM shipping_templates WHERE id = ?`,
+	} {
+		if _, err = tx.Exec(query, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetShippingTemplateByID 通过ID获取运费模板及规则
+func GetShippingTemplateByID(id int64) (*ShippingTemplate, error) {
+	template := &ShippingTemplate{}
+	err := config.DB.QueryRow(`
+		SELECT id, seller_id, name, charge_type, free_threshold, is_default, created_at, updated_at
+		FROM shipping_templates WHERE id = ?
+	`, id).Scan(&template.ID, &template.SellerID, &template.Name, &template.ChargeType,
+		&template.FreeThreshold, &template.IsDefault, &template.CreatedAt, &template.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	template.Rules, err = getShippingRules(id)
+	return template, err
+}
+
+// GetShippingTemplates 获取商家的运费模板
+func GetShippingTemplates(sellerID int64) ([]*ShippingTemplate, error) {
+	rows, err := config.DB.Query(`
+		SELECT id, seller_id, name, charge_type, free_threshold, is_default, created_at, updated_at
+		FROM shipping_templates WHERE seller_id = ? ORDER BY is_default DESC, id
+	`, sellerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []*ShippingTemplate
+	for rows.Next() {
+		template := &ShippingTemplate{}
+		err := rows.Scan(&template.ID, &template.SellerID, &template.Name, &template.ChargeType,
+			&template.FreeThreshold, &template.IsDefault, &template.CreatedAt, &template.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+	rows.Close()
+
+	for _, template := range templates {
+		if template.Rules, err = getShippingRules(template.ID); err != nil {
+			return nil, err
+		}
+	}
+	return templates, nil
+}
+
+func getShippingRules(templateID int64) ([]*ShippingRule, error) {
+	rows, err := config.DB.Query(`
+		SELECT id, template_id, provinces, first_unit, first_fee, additional_unit, additional_fee
+		FROM shipping_rules WHERE template_id = ? ORDER BY id
+	`, templateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []*ShippingRule
+	for rows.Next() {
+		rule := &ShippingRule{}
+		var provinces string
+		err := rows.Scan(&rule.ID, &rule.TemplateID, &provinces, &rule.FirstUnit, &rule.FirstFee,
+			&rule.AdditionalUnit, &rule.AdditionalFee)
+		if err != nil {
+			return nil, err
+		}
+		rule.Provinces = []string{}
+		if provinces != "" {
+			rule.Provinces = strings.Split(provinces, ",")
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// CalcFreight 计算一个商家订单的运费。商品按所用运费模板分组（未指定模板的使用商家默认模板）分别计费后相加，
+// 分组商品金额达到模板包邮门槛时该组免运费；没有可用模板的商品免运费
+func CalcFreight(sellerID int64, province string, items []*CartItem) (float64, error) {
+	type group struct {
+		count  float64 // 件数
+		weight float64 // 总重量
+		amount float64 // 商品金额
+	}
+	groups := make(map[int64]*group)
+	var templateIDs []int64
+	var defaultID int64
+	defaultLoaded := false
+
+	for _, item := range items {
+		templateID := item.Product.ShippingTemplateID
+		if templateID == 0 {
+			if !defaultLoaded {
+				err := config.DB.QueryRow(`SELECT id FROM shipping_templates WHERE seller_id = ? AND is_default = 1`, sellerID).Scan(&defaultID)
+				if err != nil && err != sql.ErrNoRows {
+					return 0, err
+				}
+				defaultLoaded = true
+			}
+			templateID = defaultID
+		}
+		if templateID == 0 {
+			continue
+		}
+
+		g, ok := groups[templateID]
+		if !ok {
+			g = &group{}
+			groups[templateID] = g
+			templateIDs = append(templateIDs, templateID)
+		}
+		g.count += float64(item.Quantity)
+		g.weight += item.Product.Weight * float64(item.Quantity)
+		g.amount += item.Product.Price * float64(item.Quantity)
+	}
+
+	var freight float64
+	for _, templateID := range templateIDs {
+		template, err := GetShippingTemplateByID(templateID)
+		if err != nil {
+			return 0, err
+		}
+		g := groups[templateID]
+		if template.FreeThreshold > 0 && g.amount >= template.FreeThreshold {
+			continue
+		}
+
+		rule := template.RuleFor(province)
+		if rule == nil {
+			return 0, fmt.Errorf("%s不在配送范围内", province)
+		}
+		if template.ChargeType == ChargeByWeight {
+			freight += rule.Fee(g.weight)
+		} else {
+			freight += rule.Fee(g.count)
+		}
+	}
+	return math.Round(freight*100) / 100, nil
+}
@@ -0,0 +1,69 @@
+package models
+
+import "testing"
+
+func TestCalcFreight(t *testing.T) {
+	_, sellerIDs := setupTestDB(t)
+
+	// 商家 1 的默认模板按件计费，满 100 包邮，新疆单独设置运费
+	byItem, err := CreateShippingTemplate(&ShippingTemplate{
+		SellerID:      sellerIDs[0],
+		Name:          "按件",
+		ChargeType:    ChargeByItem,
+		FreeThreshold: 100,
+		IsDefault:     1,
+		Rules: []*ShippingRule{
+			{FirstUnit: 1, FirstFee: 10, AdditionalUnit: 1, AdditionalFee: 5},
+			{Provinces: []string{"新疆", "西藏"}, FirstUnit: 1, FirstFee: 20, AdditionalUnit: 1, AdditionalFee: 10},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 按重量计费的模板只配送广东和北京，首重 1 千克，续重每 0.5 千克
+	byWeight, err := CreateShippingTemplate(&ShippingTemplate{
+		SellerID:   sellerIDs[0],
+		Name:       "按重量",
+		ChargeType: ChargeByWeight,
+		Rules: []*ShippingRule{
+			{Provinces: []string{"广东", "北京"}, FirstUnit: 1, FirstFee: 8, AdditionalUnit: 0.5, AdditionalFee: 2},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item := func(templateID int64, price, weight float64, quantity int) *CartItem {
+		return &CartItem{Quantity: quantity, Product: &Product{ShippingTemplateID: templateID, Price: price, Weight: weight}}
+	}
+
+	tests := []struct {
+		name     string
+		sellerID int64
+		province string
+		items    []*CartItem
+		want     float64
+		wantErr  bool
+	}{
+		{"未指定模板使用默认模板按件计费", sellerIDs[0], "上海", []*CartItem{item(0, 10, 0, 3)}, 20, false},
+		{"单独设置运费的省份", sellerIDs[0], "新疆", []*CartItem{item(0, 10, 0, 3)}, 40, false},
+		{"同一模板的商品合并计费", sellerIDs[0], "上海", []*CartItem{item(0, 10, 0, 1), item(byItem, 20, 0, 2)}, 20, false},
+		{"达到包邮门槛", sellerIDs[0], "上海", []*CartItem{item(0, 50, 0, 2)}, 0, false},
+		{"按重量续重向上取整", sellerIDs[0], "广东", []*CartItem{item(byWeight, 10, 0.8, 2)}, 12, false},
+		{"不同模板分别计费后相加", sellerIDs[0], "北京", []*CartItem{item(0, 10, 0, 1), item(byWeight, 10, 1, 1)}, 18, false},
+		{"包邮只针对达到门槛的模板", sellerIDs[0], "北京", []*CartItem{item(0, 100, 0, 1), item(byWeight, 10, 1, 1)}, 8, false},
+		{"不在配送范围", sellerIDs[0], "上海", []*CartItem{item(byWeight, 10, 1, 1)}, 0, true},
+		{"商家没有默认模板时免运费", sellerIDs[1], "上海", []*CartItem{item(0, 10, 0, 3)}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CalcFreight(tt.sellerID, tt.province, tt.items)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("期望错误 %v，实际 %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("运费应为 %v，实际 %v", tt.want, got)
+			}
+		})
+	}
+}
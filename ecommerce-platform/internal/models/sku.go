@@ -0,0 +1,281 @@
+package models
+
+import (
+	"database/sql"
+	"ecommerce-platform/internal/config"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ProductOption 商品规格项，如颜色：黑色、白色
+type ProductOption struct {
+	ID        int64    `json:"id"`
+	ProductID int64    `json:"product_id"`
+	Name      string   `json:"name"`
+	Values    []string `json:"values"`
+	SortOrder int      `json:"sort_order"`
+}
+
+// ProductSKU 商品SKU，每个规格组合有独立的价格、库存和图片
+type ProductSKU struct {
+	ID            int64             `json:"id"`
+	ProductID     int64             `json:"product_id"`
+	SKUCode       string            `json:"sku_code"`
+	Specs         map[string]string `json:"specs"`
+	Price         float64           `json:"price"`
+	OriginalPrice float64           `json:"original_price"`
+	Stock         int               `json:"stock"`
+	Sales         int               `json:"sales"`
+	Image         string            `json:"image"`
+	Status        int               `json:"status"` // 1可售 0已停用
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+// SpecsText 返回规格描述，如"颜色:黑色 存储:128G"
+func (sku *ProductSKU) SpecsText() string {
+	return formatSpecs(sku.Specs)
+}
+
+func formatSpecs(specs map[string]string) string {
+	keys := make([]string, 0, len(specs))
+	for k := range specs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+":"+specs[k])
+	}
+	return strings.Join(parts, " ")
+}
+
+// parseSpecs 解析数据库中的规格JSON
+func parseSpecs(data string) map[string]string {
+	specs := make(map[string]string)
+	if data != "" {
+		json.Unmarshal([]byte(data), &specs)
+	}
+	return specs
+}
+
+// GetProductOptions 获取商品规格项
+func GetProductOptions(productID int64) ([]*ProductOption, error) {
+	rows, err := config.DB.Query(`
+		SELECT id, product_id, name, option_values, sort_order
+		FROM product_options WHERE product_id = ? ORDER BY sort_order, id
+	`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var options []*ProductOption
+	for rows.Next() {
+		option := &ProductOption{}
+		var values string
+		err := rows.Scan(&option.ID, &option.ProductID, &option.Name, &values, &option.SortOrder)
+		if err != nil {
+			return nil, err
+		}
+		option.Values = strings.Split(values, ",")
+		options = append(options, option)
+	}
+	return options, nil
+}
+
+// GetProductSKUs 获取商品SKU，onlyActive 为 true 时只返回可售的SKU
+func GetProductSKUs(productID int64, onlyActive bool) ([]*ProductSKU, error) {
+	query := `
+		SELECT id, product_id, COALESCE(sku_code, ''), specs, price, COALESCE(original_price, 0),
+			stock, sales, COALESCE(image, ''), status, created_at, updated_at
+		FROM product_skus WHERE product_id = ?`
+	if onlyActive {
+		query += ` AND status = 1`
+	}
+	rows, err := config.DB.Query(query+` ORDER BY id`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var skus []*ProductSKU
+	for rows.Next() {
+		sku := &ProductSKU{}
+		var specs string
+		err := rows.Scan(&sku.ID, &sku.ProductID, &sku.SKUCode, &specs, &sku.Price, &sku.OriginalPrice,
+			&sku.Stock, &sku.Sales, &sku.Image, &sku.Status, &sku.CreatedAt, &sku.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		sku.Specs = parseSpecs(specs)
+		skus = append(skus, sku)
+	}
+	return skus, nil
+}
+
+// GetSKUByID 通过ID获取SKU
+func GetSKUByID(id int64) (*ProductSKU, error) {
+	sku := &ProductSKU{}
+	var specs string
+	err := config.DB.QueryRow(`
+		SELECT id, product_id, COALESCE(sku_code, ''), specs, price, COALESCE(original_price, 0),
+			stock, sales, COALESCE(image, ''), status, created_at, updated_at
+		FROM product_skus WHERE id = ?
+	`, id).Scan(&sku.ID, &sku.ProductID, &sku.SKUCode, &specs, &sku.Price, &sku.OriginalPrice,
+		&sku.Stock, &sku.Sales, &sku.Image, &sku.Status, &sku.CreatedAt, &sku.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	sku.Specs = parseSpecs(specs)
+	return sku, nil
+}
+
+// HasProductSKUs 检查商品是否启用了多规格
+func HasProductSKUs(productID int64) (bool, error) {
+	var count int
+	err := config.DB.QueryRow(`SELECT COUNT(*) FROM product_skus WHERE product_id = ? AND status = 1`, productID).Scan(&count)
+	return count > 0, err
+}
+
+// SaveProductSKUs 保存商品规格项和SKU。规格组合相同的SKU原地更新，
+// 不再出现的SKU停用而不删除，以保留订单和购物车中的引用；
+// 商品的价格和库存同步为可售SKU的最低价和库存合计
+func SaveProductSKUs(productID int64, options []*ProductOption, skus []*ProductSKU) error {
+	allowed := make(map[string]map[string]bool)
+	for _, option := range options {
+		option.Name = strings.TrimSpace(option.Name)
+		if option.Name == "" || len(option.Values) == 0 {
+			return fmt.Errorf("规格项名称和规格值不能为空")
+		}
+		if allowed[option.Name] != nil {
+			return fmt.Errorf("规格项%s重复", option.Name)
+		}
+		allowed[option.Name] = make(map[string]bool)
+		for i, value := range option.Values {
+			value = strings.TrimSpace(value)
+			if value == "" || strings.Contains(value, ",") {
+				return fmt.Errorf("规格项%s的规格值无效", option.Name)
+			}
+			option.Values[i] = value
+			allowed[option.Name][value] = true
+		}
+	}
+
+	if len(options) == 0 && len(skus) > 0 {
+		return fmt.Errorf("请先设置规格项")
+	}
+
+	keys := make(map[string]bool)
+	for _, sku := range skus {
+		if len(sku.Specs) != len(options) {
+			return fmt.Errorf("SKU规格必须包含全部规格项")
+		}
+		for name, value := range sku.Specs {
+			if !allowed[name][value] {
+				return fmt.Errorf("规格%s:%s不存在", name, value)
+			}
+		}
+		if sku.Price <= 0 || sku.Stock < 0 {
+			return fmt.Errorf("SKU %s 的价格或库存无效", sku.SpecsText())
+		}
+		key, _ := json.Marshal(sku.Specs)
+		if keys[string(key)] {
+			return fmt.Errorf("SKU %s 重复", sku.SpecsText())
+		}
+		keys[string(key)] = true
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`DELETE FROM product_options WHERE product_id = ?`, productID); err != nil {
+		return err
+	}
+	for i, option := range options {
+		_, err = tx.Exec(`
+			INSERT INTO product_options (product_id, name, option_values, sort_order)
+			VALUES (?, ?, ?, ?)
+		`, productID, option.Name, strings.Join(option.Values, ","), i)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 先全部停用，再按规格组合启用或新增
+	if _, err = tx.Exec(`UPDATE product_skus SET status = 0, updated_at = CURRENT_TIMESTAMP WHERE product_id = ?`, productID); err != nil {
+		return err
+	}
+	for _, sku := range skus {
+		specs, _ := json.Marshal(sku.Specs)
+		_, err = tx.Exec(`
+			INSERT INTO product_skus (product_id, sku_code, specs, price, original_price, stock, image, status)
+			VALUES (?, ?, ?, ?, ?, ?, ?, 1)
+			ON CONFLICT(product_id, specs) DO UPDATE SET
+				sku_code = excluded.sku_code,
+				price = excluded.price,
+				original_price = excluded.original_price,
+				stock = excluded.stock,
+				image = excluded.image,
+				status = 1,
+				updated_at = CURRENT_TIMESTAMP
+		`, productID, sku.SKUCode, string(specs), sku.Price, sku.OriginalPrice, sku.Stock, sku.Image)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = syncProductSKUSummary(tx, productID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateSKUStock 调整SKU库存，并同步商品总库存
+func UpdateSKUStock(productID, skuID int64, quantity int) error {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE product_skus SET stock = stock + ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND product_id = ? AND status = 1
+	`, quantity, skuID, productID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	if err = syncProductSKUSummary(tx, productID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// syncProductSKUSummary 将多规格商品的价格、原价和库存同步为可售SKU的汇总，未启用多规格的商品不受影响
+func syncProductSKUSummary(db execer, productID int64) error {
+	_, err := db.Exec(`
+		UPDATE products SET
+			price = (SELECT MIN(price) FROM product_skus WHERE product_id = products.id AND status = 1),
+			original_price = (SELECT original_price FROM product_skus WHERE product_id = products.id AND status = 1 ORDER BY price LIMIT 1),
+			stock = (SELECT SUM(stock) FROM product_skus WHERE product_id = products.id AND status = 1),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND EXISTS (SELECT 1 FROM product_skus WHERE product_id = products.id AND status = 1)
+	`, productID)
+	return err
+}
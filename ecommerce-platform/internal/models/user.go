@@ -45,7 +45,7 @@ func CreateUser(user *User) (int64, error) {
 func GetUserByUsername(username string) (*User, error) {
 	user := &User{}
 	err := config.DB.QueryRow(`
-		SELECT id, username, password, email, phone, role, avatar, status, created_at, updated_at
+		SELECT id, username, password, email, COALESCE(phone, ''), role, COALESCE(avatar, ''), status, created_at, updated_at
 		FROM users WHERE username = ?
 	`, username).Scan(&user.ID, &user.Username, &user.Password, &user.Email, &user.Phone,
 		&user.Role, &user.Avatar, &user.Status, &user.CreatedAt, &user.UpdatedAt)
@@ -59,7 +59,7 @@ func GetUserByUsername(username string) (*User, error) {
 func GetUserByID(id int64) (*User, error) {
 	user := &User{}
 	err := config.DB.QueryRow(`
-		SELECT id, username, password, email, phone, role, avatar, status, created_at, updated_at
+		SELECT id, username, password, email, COALESCE(phone, ''), role, COALESCE(avatar, ''), status, created_at, updated_at
 		FROM users WHERE id = ?
 	`, id).Scan(&user.ID, &user.Username, &user.Password, &user.Email, &user.Phone,
 		&user.Role, &user.Avatar, &user.Status, &user.CreatedAt, &user.UpdatedAt)
@@ -73,7 +73,7 @@ func GetUserByID(id int64) (*User, error) {
 func GetUserByEmail(email string) (*User, error) {
 	user := &User{}
 	err := config.DB.QueryRow(`
-		SELECT id, username, password, email, phone, role, avatar, status, created_at, updated_at
+		SELECT id, username, password, email, COALESCE(phone, ''), role, COALESCE(avatar, ''), status, created_at, updated_at
 		FROM users WHERE email = ?
 	`, email).Scan(&user.ID, &user.Username, &user.Password, &user.Email, &user.Phone,
 		&user.Role, &user.Avatar, &user.Status, &user.CreatedAt, &user.UpdatedAt)
@@ -114,7 +114,7 @@ func GetAllUsers(page, pageSize int, role string) ([]*User, int, error) {
 			return nil, 0, err
 		}
 		rows, err = config.DB.Query(`
-			SELECT id, username, password, email, phone, role, avatar, status, created_at, updated_at
+			SELECT id, username, password, email, COALESCE(phone, ''), role, COALESCE(avatar, ''), status, created_at, updated_at
 			FROM users WHERE role = ? ORDER BY created_at DESC LIMIT ? OFFSET ?
 		`, role, pageSize, offset)
 	} else {
@@ -123,7 +123,7 @@ func GetAllUsers(page, pageSize int, role string) ([]*User, int, error) {
 			return nil, 0, err
 		}
 		rows, err = config.DB.Query(`
-			SELECT id, username, password, email, phone, role, avatar, status, created_at, updated_at
+			SELECT id, username, password, email, COALESCE(phone, ''), role, COALESCE(avatar, ''), status, created_at, updated_at
 			FROM users ORDER BY created_at DESC LIMIT ? OFFSET ?
 		`, pageSize, offset)
 	}
@@ -174,7 +174,7 @@ func CreateSeller(seller *Seller) (int64, error) {
 func GetSellerByUserID(userID int64) (*Seller, error) {
 	seller := &Seller{}
 	err := config.DB.QueryRow(`
-		SELECT id, user_id, shop_name, shop_description, shop_logo, status, created_at
+		SELECT id, user_id, shop_name, COALESCE(shop_description, ''), COALESCE(shop_logo, ''), status, created_at
 		FROM sellers WHERE user_id = ?
 	`, userID).Scan(&seller.ID, &seller.UserID, &seller.ShopName, &seller.ShopDescription,
 		&seller.ShopLogo, &seller.Status, &seller.CreatedAt)
@@ -188,7 +188,7 @@ func GetSellerByUserID(userID int64) (*Seller, error) {
 func GetSellerByID(id int64) (*Seller, error) {
 	seller := &Seller{}
 	err := config.DB.QueryRow(`
-		SELECT id, user_id, shop_name, shop_description, shop_logo, status, created_at
+		SELECT id, user_id, shop_name, COALESCE(shop_description, ''), COALESCE(shop_logo, ''), status, created_at
 		FROM sellers WHERE id = ?
 	`, id).Scan(&seller.ID, &seller.UserID, &seller.ShopName, &seller.ShopDescription,
 		&seller.ShopLogo, &seller.Status, &seller.CreatedAt)
@@ -224,7 +224,7 @@ func GetAllSellers(page, pageSize int) ([]*Seller, int, error) {
 	}
 
 	rows, err := config.DB.Query(`
-		SELECT id, user_id, shop_name, shop_description, shop_logo, status, created_at
+		SELECT id, user_id, shop_name, COALESCE(shop_description, ''), COALESCE(shop_logo, ''), status, created_at
 		FROM sellers ORDER BY created_at DESC LIMIT ? OFFSET ?
 	`, pageSize, offset)
 	if err != nil {
@@ -0,0 +1,147 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MockProvider 模拟支付渠道，不依赖外部服务即可走完发起支付、异步通知和退款的完整流程。
+// 异步通知与真实渠道一样使用 HMAC-SHA256 签名，待支付的交易只保存在内存中
+type MockProvider struct {
+	secret []byte
+
+	mu      sync.Mutex
+	pending map[string]*PaymentRequest
+}
+
+// NewMockProvider 创建模拟支付渠道，secret 为通知签名密钥
+func NewMockProvider(secret string) *MockProvider {
+	return &MockProvider{
+		secret:  []byte(secret),
+		pending: make(map[string]*PaymentRequest),
+	}
+}
+
+func (m *MockProvider) Name() string {
+	return "mock"
+}
+
+func (m *MockProvider) Title() string {
+	return "模拟支付"
+}
+
+// CreatePayment 记录待支付交易，返回的支付地址由 handlers.MockPay 处理
+func (m *MockProvider) CreatePayment(req *PaymentRequest) (*PaymentResult, error) {
+	m.mu.Lock()
+	m.pending[req.PaymentNo] = req
+	m.mu.Unlock()
+
+	return &PaymentResult{
+		TradeNo: "MOCK" + req.PaymentNo,
+		PayURL:  "/api/payment/mock/pay?payment_no=" + url.QueryEscape(req.PaymentNo),
+	}, nil
+}
+
+// Pay 模拟用户完成支付：向发起支付时的通知地址发送已签名的支付成功通知
+func (m *MockProvider) Pay(paymentNo string) error {
+	m.mu.Lock()
+	req, ok := m.pending[paymentNo]
+	m.mu.Unlock()
+	if !ok {
+		return errors.New("支付交易不存在")
+	}
+
+	resp, err := http.PostForm(req.NotifyURL, m.NotifyForm(&Notification{
+		PaymentNo: req.PaymentNo,
+		TradeNo:   "MOCK" + req.PaymentNo,
+		Amount:    req.Amount,
+		Paid:      true,
+	}))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("支付通知处理失败: %s", resp.Status)
+	}
+
+	m.mu.Lock()
+	delete(m.pending, paymentNo)
+	m.mu.Unlock()
+	return nil
+}
+
+// NotifyForm 生成已签名的异步通知表单
+func (m *MockProvider) NotifyForm(n *Notification) url.Values {
+	status := "FAILED"
+	if n.Paid {
+		status = "SUCCESS"
+	}
+	form := url.Values{
+		"payment_no": {n.PaymentNo},
+		"trade_no":   {n.TradeNo},
+		"amount":     {strconv.FormatFloat(n.Amount, 'f', 2, 64)},
+		"status":     {status},
+	}
+	form.Set("sign", m.sign(form))
+	return form
+}
+
+// VerifyCallback 校验通知签名并解析支付结果
+func (m *MockProvider) VerifyCallback(r *http.Request) (*Notification, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	sign := r.PostForm.Get("sign")
+	if sign == "" || !hmac.Equal([]byte(sign), []byte(m.sign(r.PostForm))) {
+		return nil, ErrInvalidSignature
+	}
+
+	amount, err := strconv.ParseFloat(r.PostForm.Get("amount"), 64)
+	if err != nil {
+		return nil, errors.New("支付金额格式错误")
+	}
+	return &Notification{
+		PaymentNo: r.PostForm.Get("payment_no"),
+		TradeNo:   r.PostForm.Get("trade_no"),
+		Amount:    amount,
+		Paid:      r.PostForm.Get("status") == "SUCCESS",
+	}, nil
+}
+
+// Refund 模拟退款，总是成功
+func (m *MockProvider) Refund(req *RefundRequest) (*RefundResult, error) {
+	if req.TradeNo == "" || req.Amount <= 0 {
+		return nil, errors.New("退款参数错误")
+	}
+	return &RefundResult{RefundTradeNo: "MOCKR" + req.RefundNo}, nil
+}
+
+// sign 按参数名排序后拼接 key=value（不含 sign），计算 HMAC-SHA256
+func (m *MockProvider) sign(form url.Values) string {
+	keys := make([]string, 0, len(form))
+	for k := range form {
+		if k != "sign" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+form.Get(k))
+	}
+
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write([]byte(strings.Join(parts, "&")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,94 @@
+package payment
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Provider 支付渠道接口，接入支付宝、微信支付等渠道时实现该接口并通过 Register 注册
+type Provider interface {
+	// Name 渠道标识，记录在支付记录和订单的 pay_type 中
+	Name() string
+	// Title 展示给用户的渠道名称
+	Title() string
+	// CreatePayment 向渠道发起支付，返回渠道交易号和用户完成支付的地址
+	CreatePayment(req *PaymentRequest) (*PaymentResult, error)
+	// VerifyCallback 校验渠道异步通知的签名并解析支付结果，签名无效时返回 ErrInvalidSignature
+	VerifyCallback(r *http.Request) (*Notification, error)
+	// Refund 原路退款
+	Refund(req *RefundRequest) (*RefundResult, error)
+}
+
+// PaymentRequest 发起支付的参数
+type PaymentRequest struct {
+	PaymentNo string  // 本地支付流水号，渠道通知时原样带回
+	Amount    float64 // 支付金额（元）
+	Subject   string  // 支付标题
+	NotifyURL string  // 异步通知地址
+}
+
+// PaymentResult 发起支付的结果
+type PaymentResult struct {
+	TradeNo string // 渠道交易号
+	PayURL  string // 用户完成支付的地址
+}
+
+// Notification 渠道异步通知的支付结果
+type Notification struct {
+	PaymentNo string
+	TradeNo   string
+	Amount    float64
+	Paid      bool // 是否支付成功
+}
+
+// RefundRequest 退款参数
+type RefundRequest struct {
+	PaymentNo string
+	TradeNo   string
+	RefundNo  string // 本地退款流水号
+	Amount    float64
+	Reason    string
+}
+
+// RefundResult 退款结果
+type RefundResult struct {
+	RefundTradeNo string // 渠道退款交易号
+}
+
+var (
+	// ErrInvalidSignature 异步通知签名校验失败
+	ErrInvalidSignature = errors.New("签名校验失败")
+	// ErrUnknownProvider 未注册的支付渠道
+	ErrUnknownProvider = errors.New("不支持的支付方式")
+)
+
+var (
+	providers = make(map[string]Provider)
+	names     []string
+)
+
+// Register 注册支付渠道，应在启动时调用，同名渠道会被替换
+func Register(p Provider) {
+	if _, ok := providers[p.Name()]; !ok {
+		names = append(names, p.Name())
+	}
+	providers[p.Name()] = p
+}
+
+// Get 获取支付渠道
+func Get(name string) (Provider, error) {
+	p, ok := providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+// Providers 按注册顺序返回所有支付渠道
+func Providers() []Provider {
+	list := make([]Provider, 0, len(names))
+	for _, name := range names {
+		list = append(list, providers[name])
+	}
+	return list
+}
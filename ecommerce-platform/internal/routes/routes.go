@@ -3,6 +3,7 @@ package routes
 import (
 	"ecommerce-platform/internal/handlers"
 	"ecommerce-platform/internal/middleware"
+	"ecommerce-platform/internal/payment"
 	"net/http"
 )
 
@@ -23,6 +24,7 @@ func SetupRoutes() http.Handler {
 	mux.HandleFunc("/api/auth/register", handlers.Register)
 	mux.HandleFunc("/api/auth/login", handlers.Login)
 	mux.HandleFunc("/api/auth/logout", handlers.Logout)
+	mux.HandleFunc("/api/auth/logout-all", middleware.Auth(handlers.LogoutAll))
 	mux.HandleFunc("/api/auth/user", middleware.Auth(handlers.GetCurrentUser))
 
 	// 用户管理
@@ -41,6 +43,7 @@ func SetupRoutes() http.Handler {
 	mux.HandleFunc("/api/product", handlers.GetProduct)
 	mux.HandleFunc("/api/products/hot", handlers.GetHotProducts)
 	mux.HandleFunc("/api/products/new", handlers.GetNewProducts)
+	mux.HandleFunc("/api/products/suggest", handlers.SuggestProducts)
 	mux.HandleFunc("/api/upload/image", middleware.Auth(handlers.UploadImage))
 
 	// 商家商品管理
@@ -49,6 +52,7 @@ func SetupRoutes() http.Handler {
 	mux.HandleFunc("/api/seller/product/update", middleware.RequireSeller(handlers.UpdateProduct))
 	mux.HandleFunc("/api/seller/product/delete", middleware.RequireSeller(handlers.DeleteProduct))
 	mux.HandleFunc("/api/seller/product/stock", middleware.RequireSeller(handlers.UpdateProductStock))
+	mux.HandleFunc("/api/seller/product/skus", middleware.RequireSeller(handlers.SaveProductSKUs))
 
 	// 购物车
 	mux.HandleFunc("/api/cart", middleware.Auth(handlers.GetCart))
@@ -68,6 +72,7 @@ func SetupRoutes() http.Handler {
 	mux.HandleFunc("/api/address/default", middleware.Auth(handlers.SetDefaultAddress))
 
 	// 订单
+	mux.HandleFunc("/api/order/preview", middleware.Auth(handlers.PreviewOrder))
 	mux.HandleFunc("/api/order/create", middleware.Auth(handlers.CreateOrder))
 	mux.HandleFunc("/api/orders", middleware.Auth(handlers.GetOrders))
 	mux.HandleFunc("/api/order", middleware.Auth(handlers.GetOrder))
@@ -75,10 +80,46 @@ func SetupRoutes() http.Handler {
 	mux.HandleFunc("/api/order/cancel", middleware.Auth(handlers.CancelOrder))
 	mux.HandleFunc("/api/order/receive", middleware.Auth(handlers.ReceiveOrder))
 
+	// 售后
+	mux.HandleFunc("/api/after-sale/create", middleware.Auth(handlers.CreateAfterSale))
+	mux.HandleFunc("/api/after-sales", middleware.Auth(handlers.GetAfterSales))
+	mux.HandleFunc("/api/after-sale", middleware.Auth(handlers.GetAfterSale))
+	mux.HandleFunc("/api/after-sale/cancel", middleware.Auth(handlers.CancelAfterSale))
+	mux.HandleFunc("/api/after-sale/return", middleware.Auth(handlers.SubmitAfterSaleReturn))
+	mux.HandleFunc("/api/after-sale/arbitrate", middleware.Auth(handlers.RequestAfterSaleArbitration))
+
+	// 支付
+	mux.HandleFunc("/api/payment/providers", handlers.GetPaymentProviders)
+	mux.HandleFunc("/api/payment", middleware.Auth(handlers.GetPayment))
+	mux.HandleFunc("/api/payment/notify/", handlers.PaymentNotify)
+	// 模拟支付渠道仅在开发、测试环境启用，未注册时不提供模拟支付页
+	if _, err := payment.Get("mock"); err == nil {
+		mux.HandleFunc("/api/payment/mock/pay", middleware.Auth(handlers.MockPay))
+	}
+
 	// 商家订单管理
 	mux.HandleFunc("/api/seller/orders", middleware.RequireSeller(handlers.GetSellerOrders))
 	mux.HandleFunc("/api/seller/order/ship", middleware.RequireSeller(handlers.ShipOrder))
 	mux.HandleFunc("/api/seller/order/stats", middleware.RequireSeller(handlers.GetSellerOrderStats))
+	mux.HandleFunc("/api/seller/after-sales", middleware.RequireSeller(handlers.GetSellerAfterSales))
+	mux.HandleFunc("/api/seller/after-sale/approve", middleware.RequireSeller(handlers.ApproveAfterSale))
+	mux.HandleFunc("/api/seller/after-sale/reject", middleware.RequireSeller(handlers.RejectAfterSale))
+	mux.HandleFunc("/api/seller/after-sale/receive", middleware.RequireSeller(handlers.ReceiveAfterSaleReturn))
+
+	// 优惠券
+	mux.HandleFunc("/api/coupons", handlers.GetCoupons)
+	mux.HandleFunc("/api/coupon/claim", middleware.Auth(handlers.ClaimCoupon))
+	mux.HandleFunc("/api/user/coupons", middleware.Auth(handlers.GetUserCoupons))
+	mux.HandleFunc("/api/seller/coupons", middleware.RequireSeller(handlers.SellerGetCoupons))
+	mux.HandleFunc("/api/seller/coupon/create", middleware.RequireSeller(handlers.SellerCreateCoupon))
+	mux.HandleFunc("/api/seller/coupon/status", middleware.RequireSeller(handlers.SellerUpdateCouponStatus))
+
+	// 运费
+	mux.HandleFunc("/api/shipping/quote", middleware.OptionalAuth(handlers.QuoteShipping))
+	mux.HandleFunc("/api/seller/shipping-templates", middleware.RequireSeller(handlers.GetShippingTemplates))
+	mux.HandleFunc("/api/seller/shipping-template/create", middleware.RequireSeller(handlers.CreateShippingTemplate))
+	mux.HandleFunc("/api/seller/shipping-template/update", middleware.RequireSeller(handlers.UpdateShippingTemplate))
+	mux.HandleFunc("/api/seller/shipping-template/delete", middleware.RequireSeller(handlers.DeleteShippingTemplate))
 
 	// 评价
 	mux.HandleFunc("/api/reviews", handlers.GetProductReviews)
@@ -96,6 +137,11 @@ func SetupRoutes() http.Handler {
 	mux.HandleFunc("/api/admin/product/status", middleware.RequireAdmin(handlers.AdminUpdateProductStatus))
 	mux.HandleFunc("/api/admin/orders", middleware.RequireAdmin(handlers.AdminGetOrders))
 	mux.HandleFunc("/api/admin/order/stats", middleware.RequireAdmin(handlers.AdminGetOrderStats))
+	mux.HandleFunc("/api/admin/after-sales", middleware.RequireAdmin(handlers.AdminGetAfterSales))
+	mux.HandleFunc("/api/admin/after-sale/resolve", middleware.RequireAdmin(handlers.AdminResolveAfterSale))
+	mux.HandleFunc("/api/admin/coupons", middleware.RequireAdmin(handlers.AdminGetCoupons))
+	mux.HandleFunc("/api/admin/coupon/create", middleware.RequireAdmin(handlers.AdminCreateCoupon))
+	mux.HandleFunc("/api/admin/coupon/status", middleware.RequireAdmin(handlers.AdminUpdateCouponStatus))
 
 	// 页面路由
 	mux.HandleFunc("/login", serveIndex)
@@ -108,7 +154,7 @@ func SetupRoutes() http.Handler {
 	mux.HandleFunc("/user/", serveIndex)
 	mux.HandleFunc("/seller/", serveIndex)
 	mux.HandleFunc("/admin/", serveIndex)
-	
+
 	// 根路由必须放在最后，且需要精确匹配
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		// 只处理精确的根路径
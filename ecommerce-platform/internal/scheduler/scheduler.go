@@ -0,0 +1,132 @@
+package scheduler
+
+import (
+	"ecommerce-platform/internal/models"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Config 定时任务配置
+type Config struct {
+	Interval         time.Duration // 检查间隔
+	UnpaidTimeout    time.Duration // 下单后超过该时长未支付自动取消
+	AutoReceiveAfter time.Duration // 发货后超过该时长自动确认收货
+}
+
+// DefaultConfig 默认配置：每分钟检查一次，30分钟未支付自动取消，发货7天后自动确认收货
+var DefaultConfig = Config{
+	Interval:         time.Minute,
+	UnpaidTimeout:    30 * time.Minute,
+	AutoReceiveAfter: 7 * 24 * time.Hour,
+}
+
+// batchSize 每轮最多取消的订单数，剩余的下一轮继续处理
+const batchSize = 100
+
+// Scheduler 订单定时任务：超时未支付自动取消、发货后自动确认收货。
+// 每项处理都以订单当前状态为条件，多个实例同时运行或同一轮重复执行也不会重复取消或重复恢复库存
+type Scheduler struct {
+	cfg Config
+
+	mu      sync.Mutex // 保证同一实例内各轮任务不会并发执行
+	stop    chan struct{}
+	done    chan struct{}
+	started sync.Once
+	stopped sync.Once
+}
+
+// New 创建定时任务
+func New(cfg Config) *Scheduler {
+	return &Scheduler{
+		cfg:  cfg,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// Start 在后台启动定时任务，启动时立即执行一轮；重复调用不会启动多个任务
+func (s *Scheduler) Start() {
+	s.started.Do(func() {
+		go s.loop()
+	})
+}
+
+func (s *Scheduler) loop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.RunOnce()
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop 停止定时任务，等待正在执行的一轮结束后返回，可以重复调用
+func (s *Scheduler) Stop() {
+	s.stopped.Do(func() {
+		close(s.stop)
+	})
+	// 未启动时没有需要等待的任务
+	s.started.Do(func() {
+		close(s.done)
+	})
+	<-s.done
+}
+
+// RunOnce 执行一轮：取消超时未支付的订单，并自动确认收货
+func (s *Scheduler) RunOnce() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cfg.UnpaidTimeout > 0 {
+		s.cancelUnpaidOrders()
+	}
+
+	if s.cfg.AutoReceiveAfter > 0 {
+		count, err := models.AutoReceiveOrders(s.cfg.AutoReceiveAfter)
+		if err != nil {
+			log.Println("自动确认收货失败:", err)
+		} else if count > 0 {
+			log.Printf("自动确认收货 %d 个订单", count)
+		}
+	}
+}
+
+func (s *Scheduler) cancelUnpaidOrders() {
+	ids, err := models.GetExpiredUnpaidOrderIDs(s.cfg.UnpaidTimeout, batchSize)
+	if err != nil {
+		log.Println("查询超时未支付订单失败:", err)
+		return
+	}
+
+	cancelled := 0
+	for _, id := range ids {
+		select {
+		case <-s.stop:
+			// 停止时不再处理剩余订单，下次启动后继续
+			return
+		default:
+		}
+
+		// 与 /api/order/cancel 相同的取消逻辑：恢复库存、退回优惠券。
+		// 订单在此期间已被支付或已被其他实例取消时跳过
+		if err := models.CancelOrder(id); err != nil {
+			if !errors.Is(err, models.ErrOrderNotCancellable) {
+				log.Printf("取消超时订单 %d 失败: %v", id, err)
+			}
+			continue
+		}
+		cancelled++
+	}
+	if cancelled > 0 {
+		log.Printf("自动取消 %d 个超时未支付订单", cancelled)
+	}
+}
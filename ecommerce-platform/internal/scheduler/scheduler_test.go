@@ -0,0 +1,130 @@
+package scheduler
+
+import (
+	"ecommerce-platform/internal/models"
+	"ecommerce-platform/internal/testutil"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	testutil.OpenDB(t)
+
+	testutil.SeedBuyer(t)
+	testutil.SeedShop(t, 1)
+	testutil.SeedProduct(t, 1, 10, 10)
+}
+
+func createTestOrder(t *testing.T, quantity int) int64 {
+	t.Helper()
+	id, err := models.CreateOrder(&models.Order{
+		OrderNo:  models.GenerateOrderNo(),
+		UserID:   1,
+		SellerID: 1,
+		Items:    []*models.OrderItem{{ProductID: 1, ProductName: "p", Price: 10, Quantity: quantity, TotalPrice: 10 * float64(quantity)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestCancelUnpaidOrdersConcurrently(t *testing.T) {
+	setupTestDB(t)
+	expired := createTestOrder(t, 2)
+	recent := createTestOrder(t, 3)
+	testutil.MustExec(t, `UPDATE orders SET created_at = datetime('now', '-1 hour') WHERE id = ?`, expired)
+
+	// 两个实例同时执行，超时订单只能被取消一次、库存只能恢复一次
+	cfg := Config{Interval: time.Hour, UnpaidTimeout: 30 * time.Minute}
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			New(cfg).RunOnce()
+		}()
+	}
+	wg.Wait()
+	New(cfg).RunOnce()
+
+	if got := testutil.QueryInt(t, `SELECT status FROM orders WHERE id = ?`, expired); got != 4 {
+		t.Errorf("超时订单应已取消，实际状态 %d", got)
+	}
+	if got := testutil.QueryInt(t, `SELECT status FROM orders WHERE id = ?`, recent); got != 0 {
+		t.Errorf("未超时订单应保持待支付，实际状态 %d", got)
+	}
+	if got := testutil.QueryInt(t, `SELECT stock FROM products WHERE id = 1`); got != 7 {
+		t.Errorf("库存应为 7（恢复超时订单的 2 件），实际 %d", got)
+	}
+}
+
+func TestAutoReceiveShippedOrders(t *testing.T) {
+	setupTestDB(t)
+	old := createTestOrder(t, 1)
+	recent := createTestOrder(t, 1)
+	testutil.MustExec(t, `UPDATE orders SET status = 2, ship_time = datetime('now', '-8 days') WHERE id = ?`, old)
+	testutil.MustExec(t, `UPDATE orders SET status = 2, ship_time = datetime('now', '-1 day') WHERE id = ?`, recent)
+
+	New(Config{Interval: time.Hour, AutoReceiveAfter: 7 * 24 * time.Hour}).RunOnce()
+
+	if got := testutil.QueryInt(t, `SELECT status FROM orders WHERE id = ?`, old); got != 3 {
+		t.Errorf("发货超过7天的订单应自动确认收货，实际状态 %d", got)
+	}
+	if got := testutil.QueryInt(t, `SELECT status FROM orders WHERE id = ?`, recent); got != 2 {
+		t.Errorf("发货不足7天的订单应保持已发货，实际状态 %d", got)
+	}
+}
+
+func TestAutoReceiveSkipsOrdersWithOpenAfterSale(t *testing.T) {
+	setupTestDB(t)
+	open := createTestOrder(t, 1)
+	closed := createTestOrder(t, 1)
+	for i, c := range []struct {
+		orderID int64
+		status  int
+	}{{open, 1}, {closed, 6}} {
+		testutil.MustExec(t, `UPDATE orders SET status = 2, ship_time = datetime('now', '-8 days') WHERE id = ?`, c.orderID)
+		testutil.MustExec(t, `
+			INSERT INTO after_sales (after_sale_no, order_id, order_item_id, user_id, seller_id, type, reason, quantity, amount, status)
+			SELECT ?, order_id, id, 1, 1, 'return', '质量问题', 1, 10, ? FROM order_items WHERE order_id = ?
+		`, fmt.Sprintf("AS%d", i), c.status, c.orderID)
+	}
+
+	New(Config{Interval: time.Hour, AutoReceiveAfter: 7 * 24 * time.Hour}).RunOnce()
+
+	if got := testutil.QueryInt(t, `SELECT status FROM orders WHERE id = ?`, open); got != 2 {
+		t.Errorf("有进行中售后的订单不应自动确认收货，实际状态 %d", got)
+	}
+	if got := testutil.QueryInt(t, `SELECT status FROM orders WHERE id = ?`, closed); got != 3 {
+		t.Errorf("售后已关闭的订单应自动确认收货，实际状态 %d", got)
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	setupTestDB(t)
+	expired := createTestOrder(t, 1)
+	testutil.MustExec(t, `UPDATE orders SET created_at = datetime('now', '-1 hour') WHERE id = ?`, expired)
+
+	s := New(Config{Interval: time.Hour, UnpaidTimeout: 30 * time.Minute})
+	s.Start()
+	s.Start()
+
+	// 启动时立即执行一轮
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.QueryInt(t, `SELECT status FROM orders WHERE id = ?`, expired) != 4 {
+		if time.Now().After(deadline) {
+			t.Fatal("启动后应立即取消超时订单")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s.Stop()
+	s.Stop()
+
+	// 未启动时停止不应阻塞
+	New(DefaultConfig).Stop()
+}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"strings"
+)
+
+// ThumbnailSizes 缩略图最长边尺寸
+var ThumbnailSizes = []int{200, 400}
+
+// MaxImagePixels 允许上传的最大像素数，防止超大图片解码耗尽内存
+const MaxImagePixels = 40000000
+
+// 支持的图片类型及保存扩展名
+var imageExts = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// ImageResult 图片保存结果
+type ImageResult struct {
+	URL         string            `json:"url"`
+	Thumbnails  map[string]string `json:"thumbnails"`
+	ContentType string            `json:"content_type"`
+	Width       int               `json:"width"`
+	Height      int               `json:"height"`
+}
+
+// SaveImage 根据文件内容识别图片类型（不信任客户端声明的类型），保存原图并生成缩略图
+func SaveImage(s Storage, id string, data []byte) (*ImageResult, error) {
+	contentType := http.DetectContentType(data)
+	ext, ok := imageExts[contentType]
+	if !ok {
+		return nil, errors.New("只支持 JPEG、PNG、GIF 格式的图片")
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("图片文件已损坏")
+	}
+	if cfg.Width*cfg.Height > MaxImagePixels {
+		return nil, errors.New("图片尺寸过大")
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("图片文件已损坏")
+	}
+
+	name := id + ext
+	if err := s.Save(name, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+
+	result := &ImageResult{
+		URL:         s.URL(name),
+		Thumbnails:  make(map[string]string),
+		ContentType: contentType,
+		Width:       cfg.Width,
+		Height:      cfg.Height,
+	}
+	for _, size := range ThumbnailSizes {
+		thumbName := thumbnailName(name, size)
+		var buf bytes.Buffer
+		if err := encodeThumbnail(&buf, resize(img, size), contentType); err != nil {
+			DeleteImage(s, result.URL)
+			return nil, err
+		}
+		if err := s.Save(thumbName, &buf); err != nil {
+			DeleteImage(s, result.URL)
+			return nil, err
+		}
+		result.Thumbnails[fmt.Sprint(size)] = s.URL(thumbName)
+	}
+	return result, nil
+}
+
+// DeleteImage 删除原图及其缩略图，不属于该存储的地址直接忽略
+func DeleteImage(s Storage, url string) error {
+	name, ok := s.Name(url)
+	if !ok {
+		return nil
+	}
+
+	if err := s.Delete(name); err != nil {
+		return err
+	}
+	for _, size := range ThumbnailSizes {
+		if err := s.Delete(thumbnailName(name, size)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// thumbnailName 缩略图文件名，如 abc.jpg 的 200 尺寸缩略图为 abc_200.jpg；
+// JPEG 缩略图保持 JPEG，PNG 和 GIF 统一生成 PNG 以保留透明度
+func thumbnailName(name string, size int) string {
+	base, ext := name, ""
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		base, ext = name[:i], name[i:]
+	}
+	if ext != ".jpg" {
+		ext = ".png"
+	}
+	return fmt.Sprintf("%s_%d%s", base, size, ext)
+}
+
+func encodeThumbnail(buf *bytes.Buffer, img image.Image, contentType string) error {
+	if contentType == "image/jpeg" {
+		return jpeg.Encode(buf, img, &jpeg.Options{Quality: 85})
+	}
+	return png.Encode(buf, img)
+}
+
+// resize 按最长边等比缩小图片，使用区域平均采样；图片本身不大于目标尺寸时不放大
+func resize(src image.Image, maxEdge int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxEdge && h <= maxEdge {
+		return src
+	}
+
+	dw, dh := maxEdge, h*maxEdge/w
+	if h > w {
+		dw, dh = w*maxEdge/h, maxEdge
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for dy := 0; dy < dh; dy++ {
+		sy0, sy1 := dy*h/dh, (dy+1)*h/dh
+		if sy1 <= sy0 {
+			sy1 = sy0 + 1
+		}
+		for dx := 0; dx < dw; dx++ {
+			sx0, sx1 := dx*w/dw, (dx+1)*w/dw
+			if sx1 <= sx0 {
+				sx1 = sx0 + 1
+			}
+
+			var r, g, bl, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := src.At(b.Min.X+sx, b.Min.Y+sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					bl += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			dst.Set(dx, dy, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(bl / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
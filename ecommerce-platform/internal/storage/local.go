@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStorage 本地磁盘存储，文件通过静态文件服务以 urlPrefix 访问
+type LocalStorage struct {
+	dir       string
+	urlPrefix string
+}
+
+// NewLocalStorage 创建本地存储，目录不存在时自动创建
+func NewLocalStorage(dir, urlPrefix string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(urlPrefix, "/") {
+		urlPrefix += "/"
+	}
+	return &LocalStorage{dir: dir, urlPrefix: urlPrefix}, nil
+}
+
+func (s *LocalStorage) path(name string) (string, error) {
+	// 只允许单层文件名，防止路径穿越
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", errors.New("无效的文件名")
+	}
+	return filepath.Join(s.dir, name), nil
+}
+
+// Save 先写入临时文件再重命名，避免读到写了一半的文件
+func (s *LocalStorage) Save(name string, r io.Reader) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalStorage) Delete(name string) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStorage) URL(name string) string {
+	return s.urlPrefix + name
+}
+
+func (s *LocalStorage) Name(url string) (string, bool) {
+	if !strings.HasPrefix(url, s.urlPrefix) {
+		return "", false
+	}
+	name := strings.TrimPrefix(url, s.urlPrefix)
+	if _, err := s.path(name); err != nil {
+		return "", false
+	}
+	return name, true
+}
@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"io"
+)
+
+// Storage 文件存储接口，以文件名为键存取文件，可替换为云存储等实现
+type Storage interface {
+	// Save 保存文件内容
+	Save(name string, r io.Reader) error
+	// Delete 删除文件，文件不存在时不报错
+	Delete(name string) error
+	// URL 返回文件的访问地址
+	URL(name string) string
+	// Name 由访问地址反查文件名，不属于本存储的地址返回 false
+	Name(url string) (string, bool)
+}
+
+// Default 默认存储，由 Init 初始化
+var Default Storage
+
+// Init 初始化默认存储为本地磁盘
+func Init(dir, urlPrefix string) error {
+	local, err := NewLocalStorage(dir, urlPrefix)
+	if err != nil {
+		return err
+	}
+	Default = local
+	return nil
+}
@@ -0,0 +1,62 @@
+// Package testutil 提供各包测试共用的临时数据库和测试数据辅助函数
+package testutil
+
+import (
+	"ecommerce-platform/internal/config"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// OpenDB 在测试的临时目录中打开数据库，测试结束时自动关闭
+func OpenDB(t *testing.T) {
+	t.Helper()
+	config.OpenDatabase(filepath.Join(t.TempDir(), "test.db"))
+	t.Cleanup(config.CloseDatabase)
+}
+
+// MustExec 执行语句，失败时终止测试
+func MustExec(t *testing.T, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := config.DB.Exec(query, args...); err != nil {
+		t.Fatalf("执行 %q 失败: %v", query, err)
+	}
+}
+
+// QueryInt 查询单个整数值，失败时终止测试
+func QueryInt(t *testing.T, query string, args ...interface{}) int {
+	t.Helper()
+	var n int
+	if err := config.DB.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// SeedBuyer 创建 ID 为 1 的顾客，返回用户 ID
+func SeedBuyer(t *testing.T) int64 {
+	t.Helper()
+	MustExec(t, `INSERT INTO users (id, username, password, email, role) VALUES (1, 'buyer', 'x', 'buyer@test.com', 'customer')`)
+	return 1
+}
+
+// SeedShop 创建 ID 为 shopID 的店铺及其商家用户，商家用户 ID 为 shopID+1，返回商家用户 ID
+func SeedShop(t *testing.T, shopID int64) int64 {
+	t.Helper()
+	userID := shopID + 1
+	MustExec(t, `INSERT INTO users (id, username, password, email, role) VALUES (?, ?, 'x', ?, 'seller')`,
+		userID, fmt.Sprintf("seller%d", shopID), fmt.Sprintf("seller%d@test.com", shopID))
+	MustExec(t, `INSERT INTO sellers (id, user_id, shop_name, status) VALUES (?, ?, ?, 1)`, shopID, userID, fmt.Sprintf("shop%d", shopID))
+	return userID
+}
+
+// SeedProduct 在店铺下创建一个上架商品，返回商品 ID
+func SeedProduct(t *testing.T, shopID int64, price float64, stock int) int64 {
+	t.Helper()
+	result, err := config.DB.Exec(`INSERT INTO products (seller_id, name, price, stock, status) VALUES (?, 'p', ?, ?, 1)`, shopID, price, stock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := result.LastInsertId()
+	return id
+}
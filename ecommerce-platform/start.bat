@@ -40,7 +40,7 @@ echo.
 
 :: 编译项目
 echo 🔨 正在编译项目...
-go build -o ecommerce-server.exe ./cmd/main.go
+go build -tags sqlite_fts5 -o ecommerce-server.exe ./cmd/main.go
 
 if %errorlevel% neq 0 (
     echo ❌ 编译失败
@@ -54,4 +54,4 @@ echo.
 :: 启动服务
 echo 🚀 正在启动服务...
 echo.
-ecommerce-server.exe
+ecommerce-server.exe -mock-payment
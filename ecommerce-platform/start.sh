@@ -39,7 +39,7 @@ echo ""
 
 # 编译项目
 echo "🔨 正在编译项目..."
-go build -o ecommerce-server ./cmd/main.go
+go build -tags sqlite_fts5 -o ecommerce-server ./cmd/main.go
 
 if [ $? -ne 0 ]; then
     echo "❌ 编译失败"
@@ -52,4 +52,4 @@ echo ""
 # 启动服务
 echo "🚀 正在启动服务..."
 echo ""
-./ecommerce-server
+./ecommerce-server -mock-payment
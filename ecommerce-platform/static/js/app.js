@@ -208,6 +208,13 @@ function logout() {
     }
 }
 
+// 退出登录并使服务端会话失效，all 为 true 时退出所有设备
+async function userLogout(all) {
+    await api(all ? '/auth/logout-all' : '/auth/logout', { method: 'POST' });
+    logout();
+    if (all) showToast('已退出所有设备', 'success');
+}
+
 function updateHeader() {
     const navLinks = $('.nav-links');
     if (!navLinks) return;
@@ -238,7 +245,8 @@ function updateHeader() {
 
         menuItems += `
             <div class="dropdown-divider"></div>
-            <a href="#" onclick="logout(); return false;" class="dropdown-item">🚪 退出登录</a>
+            <a href="#" onclick="userLogout(false); return false;" class="dropdown-item">🚪 退出登录</a>
+            <a href="#" onclick="userLogout(true); return false;" class="dropdown-item">📴 退出所有设备</a>
         `;
 
         navLinks.innerHTML = `
@@ -470,6 +478,29 @@ async function searchProducts() {
     await loadProducts();
 }
 
+// 搜索建议，输入停顿后再请求
+let suggestTimer = null;
+function suggestProducts() {
+    clearTimeout(suggestTimer);
+    suggestTimer = setTimeout(async () => {
+        const keyword = $('#search-input').value.trim();
+        const list = $('#search-suggestions');
+        if (!keyword) {
+            list.innerHTML = '';
+            return;
+        }
+        const res = await api('/products/suggest?keyword=' + encodeURIComponent(keyword));
+        if (res && res.code === 200) {
+            list.innerHTML = '';
+            res.data.forEach(name => {
+                const option = document.createElement('option');
+                option.value = name;
+                list.appendChild(option);
+            });
+        }
+    }, 200);
+}
+
 async function loadProducts() {
     const section = $('#all-products');
     const container = $('#products-container');
@@ -252,8 +252,9 @@ async function loadSellerProducts(status, tab) {
 }
 
 async function showAddProductModal() {
-    const catRes = await api('/categories');
+    const [catRes, tplRes] = await Promise.all([api('/categories'), api('/seller/shipping-templates')]);
     const categories = catRes?.data || [];
+    const templates = tplRes?.data || [];
 
     showModal('添加商品', `
         <form id="product-form">
@@ -280,6 +281,17 @@ async function showAddProductModal() {
                 <label class="form-label">库存 *</label>
                 <input type="number" class="form-input" id="prod-stock" required>
             </div>
+            <div class="form-group">
+                <label class="form-label">重量(千克)</label>
+                <input type="number" class="form-input" id="prod-weight" step="0.001" min="0">
+            </div>
+            <div class="form-group">
+                <label class="form-label">运费模板</label>
+                <select class="form-input form-select" id="prod-shipping-template">
+                    <option value="0">使用店铺默认模板</option>
+                    ${templates.map(t => `<option value="${t.id}">${t.name}</option>`).join('')}
+                </select>
+            </div>
             <div class="form-group">
                 <label class="form-label">商品图片URL</label>
                 <input type="text" class="form-input" id="prod-images" placeholder="多个图片用逗号分隔">
@@ -302,6 +314,8 @@ async function saveProduct() {
         price: parseFloat($('#prod-price').value),
         original_price: parseFloat($('#prod-original-price').value) || 0,
         stock: parseInt($('#prod-stock').value),
+        weight: parseFloat($('#prod-weight').value) || 0,
+        shipping_template_id: parseInt($('#prod-shipping-template').value) || 0,
         images: $('#prod-images').value,
         description: $('#prod-desc').value
     };
@@ -333,8 +347,9 @@ async function editProduct(id) {
     }
 
     const p = res.data;
-    const catRes = await api('/categories');
+    const [catRes, tplRes] = await Promise.all([api('/categories'), api('/seller/shipping-templates')]);
     const categories = catRes?.data || [];
+    const templates = tplRes?.data || [];
 
     showModal('编辑商品', `
         <form id="product-form">
@@ -362,6 +377,17 @@ async function editProduct(id) {
                 <label class="form-label">库存 *</label>
                 <input type="number" class="form-input" id="prod-stock" value="${p.stock}" required>
             </div>
+            <div class="form-group">
+                <label class="form-label">重量(千克)</label>
+                <input type="number" class="form-input" id="prod-weight" step="0.001" min="0" value="${p.weight || ''}">
+            </div>
+            <div class="form-group">
+                <label class="form-label">运费模板</label>
+                <select class="form-input form-select" id="prod-shipping-template">
+                    <option value="0">使用店铺默认模板</option>
+                    ${templates.map(t => `<option value="${t.id}" ${t.id === p.shipping_template_id ? 'selected' : ''}>${t.name}</option>`).join('')}
+                </select>
+            </div>
             <div class="form-group">
                 <label class="form-label">商品图片URL</label>
                 <input type="text" class="form-input" id="prod-images" value="${p.images || ''}">
@@ -393,6 +419,8 @@ async function updateProduct() {
         price: parseFloat($('#prod-price').value),
         original_price: parseFloat($('#prod-original-price').value) || 0,
         stock: parseInt($('#prod-stock').value),
+        weight: parseFloat($('#prod-weight').value) || 0,
+        shipping_template_id: parseInt($('#prod-shipping-template').value) || 0,
         images: $('#prod-images').value,
         description: $('#prod-desc').value,
         status: parseInt($('#prod-status').value)
@@ -487,6 +515,7 @@ async function loadSellerOrders(status, tab) {
                         </div>
                         <div class="order-product-info">
                             <h4 class="order-product-name">${item.product_name}</h4>
+                            ${item.sku_specs ? `<p class="text-muted" style="font-size: 0.85rem;">${item.sku_specs}</p>` : ''}
                             <p class="order-product-price">${formatPrice(item.price)} × ${item.quantity}</p>
                         </div>
                     </div>
@@ -499,6 +528,7 @@ async function loadSellerOrders(status, tab) {
             </div>
             <div class="order-footer">
                 <div class="order-total">
+                    ${order.discount_amount > 0 ? `<span class="text-muted">优惠 -${formatPrice(order.discount_amount)}</span>` : ''}
                     实付：<strong>${formatPrice(order.pay_amount)}</strong>
                 </div>
                 <div class="order-actions">
@@ -134,6 +134,9 @@ async function handleRegister(e) {
 // ========================================
 // 商品详情页
 // ========================================
+let currentProduct = null;
+let selectedSpecs = {};
+
 async function renderProductDetail(id) {
     const content = $('#app-content');
     const res = await api('/product?id=' + id);
@@ -145,6 +148,8 @@ async function renderProductDetail(id) {
 
     const product = res.data;
     const images = product.images ? product.images.split(',') : ['https://via.placeholder.com/400'];
+    currentProduct = product;
+    selectedSpecs = {};
 
     content.innerHTML = `
         <div class="product-detail">
@@ -164,16 +169,26 @@ async function renderProductDetail(id) {
             </div>
             <div class="product-detail-info">
                 <h1>${product.name}</h1>
-                <div class="detail-price">
-                    <span class="current">${formatPrice(product.price)}</span>
+                <div class="detail-price" id="detail-price">
+                    <span class="current">${formatPrice(product.price)}${product.skus ? ' 起' : ''}</span>
                     ${product.original_price > product.price ? 
                         `<span class="original">${formatPrice(product.original_price)}</span>` : ''}
                 </div>
                 <div class="detail-meta">
                     <span>⭐ ${(product.rating || 5).toFixed(1)} 分</span>
                     <span>📦 已售 ${product.sales || 0}</span>
-                    <span>📊 库存 ${product.stock}</span>
+                    <span id="detail-stock">📊 库存 ${product.stock}</span>
                 </div>
+                ${(product.options || []).map(opt => `
+                    <div class="mb-2">
+                        <label class="form-label">${opt.name}</label>
+                        <div class="d-flex gap-1">
+                            ${opt.values.map(v => `
+                                <button class="btn btn-sm btn-secondary spec-option" data-option="${opt.name}" data-value="${v}" onclick="selectSpec(this)">${v}</button>
+                            `).join('')}
+                        </div>
+                    </div>
+                `).join('')}
                 <div class="mb-2">
                     <p class="text-muted">店铺：${product.seller_name || '官方自营'}</p>
                     <p class="text-muted">分类：${product.category_name || '未分类'}</p>
@@ -220,6 +235,33 @@ async function renderProductDetail(id) {
     loadProductReviews(id);
 }
 
+function selectSpec(btn) {
+    const option = btn.dataset.option;
+    selectedSpecs[option] = btn.dataset.value;
+    $$(`.spec-option[data-option="${option}"]`).forEach(el => {
+        el.classList.toggle('btn-primary', el === btn);
+        el.classList.toggle('btn-secondary', el !== btn);
+    });
+
+    const sku = getSelectedSku();
+    if (!sku) return;
+    $('#detail-price').innerHTML = `
+        <span class="current">${formatPrice(sku.price)}</span>
+        ${sku.original_price > sku.price ? `<span class="original">${formatPrice(sku.original_price)}</span>` : ''}
+    `;
+    $('#detail-stock').textContent = `📊 库存 ${sku.stock}`;
+    $('#buy-quantity').max = sku.stock;
+    if (sku.image) $('#main-image').src = sku.image;
+}
+
+// 所有规格项都已选择时返回匹配的SKU
+function getSelectedSku() {
+    if (!currentProduct || !currentProduct.skus) return null;
+    const options = currentProduct.options || [];
+    if (options.some(opt => !selectedSpecs[opt.name])) return null;
+    return currentProduct.skus.find(sku => options.every(opt => sku.specs[opt.name] === selectedSpecs[opt.name])) || null;
+}
+
 function changeImage(src, thumb) {
     $('#main-image').src = src;
     $$('.gallery-thumb').forEach(el => el.classList.remove('active'));
@@ -239,17 +281,29 @@ async function addToCart(productId) {
         return;
     }
 
+    let skuId = 0;
+    if (currentProduct && currentProduct.id === productId && currentProduct.skus) {
+        const sku = getSelectedSku();
+        if (!sku) {
+            showToast('请选择商品规格', 'warning');
+            return false;
+        }
+        skuId = sku.id;
+    }
+
     const quantity = parseInt($('#buy-quantity')?.value || 1);
     const res = await api('/cart/add', {
         method: 'POST',
-        body: { product_id: productId, quantity }
+        body: { product_id: productId, sku_id: skuId, quantity }
     });
 
     if (res && res.code === 200) {
         showToast('已添加到购物车', 'success');
         updateCartCount();
+        return true;
     } else {
         showToast(res?.message || '添加失败', 'error');
+        return false;
     }
 }
 
@@ -260,8 +314,9 @@ async function buyNow(productId) {
         return;
     }
 
-    await addToCart(productId);
-    navigate('/cart');
+    if (await addToCart(productId)) {
+        navigate('/cart');
+    }
 }
 
 async function loadProductReviews(productId) {
@@ -340,6 +395,7 @@ async function renderCart() {
                         </div>
                         <div class="cart-details">
                             <h4 class="cart-name" onclick="navigate('/product/${item.product_id}')">${item.product.name}</h4>
+                            ${item.sku_specs ? `<p class="text-muted" style="font-size: 0.85rem;">${item.sku_specs}</p>` : ''}
                             <p class="cart-price">${formatPrice(item.product.price)}</p>
                             <p class="text-muted" style="font-size: 0.85rem;">店铺：${item.product.seller_name || '官方自营'}</p>
                         </div>
@@ -429,15 +485,28 @@ async function renderCheckout() {
 
     const content = $('#app-content');
     
-    // 获取地址和购物车
-    const [addressRes, cartRes] = await Promise.all([
+    // 获取地址、购物车和结算金额明细，运费按所选收货地址计算
+    window.checkoutCoupons = window.checkoutCoupons || {};
+    const [addressRes, cartRes, previewRes] = await Promise.all([
         api('/addresses'),
-        api('/cart')
+        api('/cart'),
+        api('/order/preview', { method: 'POST', body: { address_id: window.selectedAddressId || 0, coupons: window.checkoutCoupons } })
     ]);
 
     const addresses = addressRes?.data || [];
     const cartData = cartRes?.data || {};
     const items = (cartData.items || []).filter(i => i.selected);
+    const preview = previewRes?.code === 200 ? previewRes.data : null;
+    if (previewRes && previewRes.code !== 200 && Object.keys(window.checkoutCoupons).length > 0) {
+        // 所选优惠券不可用时清空选择后重新计算
+        showToast(previewRes.message || '优惠券不可用', 'warning');
+        window.checkoutCoupons = {};
+        return renderCheckout();
+    }
+    if (previewRes && previewRes.code !== 200 && items.length > 0) {
+        // 如所选地址不在配送范围内
+        showToast(previewRes.message || '结算信息获取失败', 'warning');
+    }
 
     if (items.length === 0) {
         content.innerHTML = `
@@ -450,7 +519,8 @@ async function renderCheckout() {
         return;
     }
 
-    const defaultAddress = addresses.find(a => a.is_default) || addresses[0];
+    const defaultAddress = addresses.find(a => a.id === window.selectedAddressId)
+        || addresses.find(a => a.is_default) || addresses[0];
 
     content.innerHTML = `
         <div style="display: grid; grid-template-columns: 1fr 350px; gap: 20px;">
@@ -495,6 +565,25 @@ async function renderCheckout() {
                     `).join('')}
                 </div>
 
+                ${preview ? `
+                    <div class="checkout-section">
+                        <h3 class="checkout-title">🎫 优惠券</h3>
+                        ${preview.orders.map(o => `
+                            <div class="d-flex align-center justify-between mb-1">
+                                <span>${o.seller_name || '官方自营'}</span>
+                                <select class="form-input" style="max-width: 260px;" onchange="selectCheckoutCoupon(${o.seller_id}, this.value)">
+                                    <option value="0">${(o.available_coupons || []).length ? '不使用优惠券' : '暂无可用优惠券'}</option>
+                                    ${(o.available_coupons || []).map(c => `
+                                        <option value="${c.user_coupon_id}" ${c.user_coupon_id === o.user_coupon_id ? 'selected' : ''}>
+                                            ${c.name}（-${formatPrice(c.discount)}）
+                                        </option>
+                                    `).join('')}
+                                </select>
+                            </div>
+                        `).join('')}
+                    </div>
+                ` : ''}
+
                 <div class="checkout-section">
                     <h3 class="checkout-title">📝 订单备注</h3>
                     <textarea class="form-input form-textarea" id="order-remark" placeholder="选填，可以告诉商家您的特殊需求"></textarea>
@@ -506,17 +595,23 @@ async function renderCheckout() {
                     <h3 class="checkout-title">订单汇总</h3>
                     <div class="summary-row">
                         <span>商品金额</span>
-                        <span>${formatPrice(cartData.total_price)}</span>
+                        <span>${formatPrice(preview ? preview.total_amount : cartData.total_price)}</span>
                     </div>
                     <div class="summary-row">
                         <span>运费</span>
-                        <span>免运费</span>
+                        <span>${preview && preview.freight_amount > 0 ? formatPrice(preview.freight_amount) : '免运费'}</span>
                     </div>
+                    ${preview && preview.discount_amount > 0 ? `
+                        <div class="summary-row">
+                            <span>优惠</span>
+                            <span class="text-danger">-${formatPrice(preview.discount_amount)}</span>
+                        </div>
+                    ` : ''}
                     <div class="summary-row summary-total">
                         <span>应付金额</span>
-                        <span>${formatPrice(cartData.total_price)}</span>
+                        <span>${formatPrice(preview ? preview.pay_amount : cartData.total_price)}</span>
                     </div>
-                    <button class="btn btn-primary btn-block btn-lg mt-3" onclick="submitOrder()" ${addresses.length === 0 ? 'disabled' : ''}>
+                    <button class="btn btn-primary btn-block btn-lg mt-3" onclick="submitOrder()" ${addresses.length === 0 || !preview ? 'disabled' : ''}>
                         提交订单
                     </button>
                 </div>
@@ -527,10 +622,28 @@ async function renderCheckout() {
     window.selectedAddressId = defaultAddress?.id;
 }
 
+function selectCheckoutCoupon(sellerId, userCouponId) {
+    const id = parseInt(userCouponId);
+    // 同一张平台券只能用于一个订单
+    for (const key of Object.keys(window.checkoutCoupons)) {
+        if (window.checkoutCoupons[key] === id) delete window.checkoutCoupons[key];
+    }
+    if (id > 0) {
+        window.checkoutCoupons[sellerId] = id;
+    } else {
+        delete window.checkoutCoupons[sellerId];
+    }
+    renderCheckout();
+}
+
 function selectAddress(id, element) {
     $$('.address-card').forEach(el => el.classList.remove('selected'));
     element.classList.add('selected');
-    window.selectedAddressId = id;
+    if (window.selectedAddressId !== id) {
+        window.selectedAddressId = id;
+        // 重新计算运费
+        renderCheckout();
+    }
 }
 
 function showAddAddressModal() {
@@ -607,11 +720,13 @@ async function submitOrder() {
         method: 'POST',
         body: {
             address_id: window.selectedAddressId,
-            remark: $('#order-remark')?.value || ''
+            remark: $('#order-remark')?.value || '',
+            coupons: window.checkoutCoupons || {}
         }
     });
 
     if (res && res.code === 200) {
+        window.checkoutCoupons = {};
         showToast('订单创建成功', 'success');
         updateCartCount();
         
@@ -623,28 +738,58 @@ async function submitOrder() {
     }
 }
 
-function showPaymentModal(orderIds) {
+async function showPaymentModal(orderIds) {
+    const res = await api('/payment/providers');
+    const providers = res?.data || [];
+
     showModal('订单支付', `
         <div class="text-center">
             <p class="mb-2">订单已创建成功！</p>
             <p class="text-muted">请选择支付方式</p>
             <div class="d-flex gap-2 justify-center mt-3">
-                <button class="btn btn-outline" onclick="payOrders(${JSON.stringify(orderIds)}, 'alipay')">支付宝</button>
-                <button class="btn btn-outline" onclick="payOrders(${JSON.stringify(orderIds)}, 'wechat')">微信支付</button>
+                ${providers.map(p => `
+                    <button class="btn btn-outline" onclick="payOrders(${JSON.stringify(orderIds)}, '${p.name}')">${p.title}</button>
+                `).join('')}
             </div>
         </div>
     `);
 }
 
 async function payOrders(orderIds, payType) {
+    const payments = [];
     for (const id of orderIds) {
-        await api('/order/pay', {
+        const res = await api('/order/pay', {
             method: 'POST',
             body: { order_id: id, pay_type: payType }
         });
+        if (!res || res.code !== 200) {
+            showToast(res?.message || '发起支付失败', 'error');
+            return;
+        }
+        payments.push(res.data);
     }
-    
-    showToast('支付成功', 'success');
+
+    // 模拟支付直接确认，真实渠道跳转到渠道的支付页
+    for (const p of payments) {
+        if (p.provider === 'mock') {
+            await api(p.pay_url.replace(API_BASE, ''), { method: 'POST' });
+        } else {
+            window.open(p.pay_url, '_blank');
+        }
+    }
+
+    // 订单在收到渠道的异步通知后才变为已支付，轮询支付结果
+    const paid = await waitForPayments(payments.map(p => p.payment_no));
+    showToast(paid ? '支付成功' : '支付结果确认中，请稍后在订单中查看', paid ? 'success' : 'info');
     closeModal();
     navigate('/orders');
 }
+
+async function waitForPayments(paymentNos, attempts = 10) {
+    for (let i = 0; i < attempts; i++) {
+        const results = await Promise.all(paymentNos.map(no => api('/payment?payment_no=' + encodeURIComponent(no))));
+        if (results.every(res => res?.data?.status === 1)) return true;
+        await new Promise(resolve => setTimeout(resolve, 500));
+    }
+    return false;
+}
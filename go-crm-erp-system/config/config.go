@@ -2,12 +2,17 @@ package config
 
 import (
 	"os"
+	"strconv"
 )
 
 type Config struct {
 	Port      string
 	JWTSecret string
 	DBPath    string
+	TaxRate   float64
+	// 库存预警检查间隔（分钟）及销量统计天数
+	AlertIntervalMinutes int
+	SalesVelocityDays    int
 }
 
 var AppConfig *Config
@@ -17,6 +22,10 @@ func LoadConfig() {
 		Port:      getEnv("PORT", "8080"),
 		JWTSecret: getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
 		DBPath:    getEnv("DB_PATH", "./crm_erp.db"),
+		TaxRate:   getEnvFloat("TAX_RATE", 0),
+
+		AlertIntervalMinutes: getEnvInt("ALERT_INTERVAL_MINUTES", 10),
+		SalesVelocityDays:    getEnvInt("SALES_VELOCITY_DAYS", 30),
 	}
 }
 
@@ -27,3 +36,19 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
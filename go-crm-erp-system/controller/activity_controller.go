@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"crm-erp-system/middleware"
+	"crm-erp-system/model"
+	"crm-erp-system/service"
+	"crm-erp-system/utils"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+type ActivityController struct {
+	activityService *service.ActivityService
+}
+
+func NewActivityController() *ActivityController {
+	return &ActivityController{
+		activityService: &service.ActivityService{},
+	}
+}
+
+// Create 记录客户活动或创建跟进任务
+func (ctrl *ActivityController) Create(c *gin.Context) {
+	var activity model.Activity
+	if err := c.ShouldBindJSON(&activity); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	id, err := ctrl.activityService.Create(&activity, userID.(int64), middleware.OwnerScope(c))
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{"id": id})
+}
+
+// Get 获取活动详情
+func (ctrl *ActivityController) Get(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的活动ID")
+		return
+	}
+
+	activity, err := ctrl.activityService.GetByID(id, middleware.OwnerScope(c))
+	if err != nil {
+		utils.NotFound(c, err.Error())
+		return
+	}
+
+	utils.Success(c, activity)
+}
+
+// List 查询活动，支持按客户、类型、负责人、状态和是否逾期筛选
+func (ctrl *ActivityController) List(c *gin.Context) {
+	var query model.ActivityQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	activities, err := ctrl.activityService.List(&query, middleware.OwnerScope(c))
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list":      activities,
+		"page":      query.Page,
+		"page_size": query.PageSize,
+	})
+}
+
+// Update 更新活动
+func (ctrl *ActivityController) Update(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的活动ID")
+		return
+	}
+
+	var activity model.Activity
+	if err := c.ShouldBindJSON(&activity); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	if err := ctrl.activityService.Update(id, &activity, userID.(int64), middleware.OwnerScope(c)); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "更新成功", nil)
+}
+
+// Complete 完成跟进任务
+func (ctrl *ActivityController) Complete(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的活动ID")
+		return
+	}
+
+	if err := ctrl.activityService.Complete(id, middleware.OwnerScope(c)); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "已完成", nil)
+}
+
+// Delete 删除活动
+func (ctrl *ActivityController) Delete(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的活动ID")
+		return
+	}
+
+	if err := ctrl.activityService.Delete(id, middleware.OwnerScope(c)); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "删除成功", nil)
+}
+
+// Timeline 客户时间线，合并活动与订单
+func (ctrl *ActivityController) Timeline(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的客户ID")
+		return
+	}
+
+	entries, err := ctrl.activityService.Timeline(id, middleware.OwnerScope(c))
+	if err != nil {
+		utils.NotFound(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list": entries,
+	})
+}
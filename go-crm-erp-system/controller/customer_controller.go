@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"crm-erp-system/middleware"
 	"crm-erp-system/model"
 	"crm-erp-system/service"
 	"crm-erp-system/utils"
@@ -44,7 +45,7 @@ func (ctrl *CustomerController) Get(c *gin.Context) {
 		return
 	}
 
-	customer, err := ctrl.customerService.GetByID(id)
+	customer, err := ctrl.customerService.GetByID(id, middleware.OwnerScope(c))
 	if err != nil {
 		utils.NotFound(c, err.Error())
 		return
@@ -58,7 +59,7 @@ func (ctrl *CustomerController) List(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
 
-	customers, err := ctrl.customerService.List(page, pageSize)
+	customers, err := ctrl.customerService.List(page, pageSize, middleware.OwnerScope(c))
 	if err != nil {
 		utils.InternalError(c, err.Error())
 		return
@@ -85,7 +86,13 @@ func (ctrl *CustomerController) Update(c *gin.Context) {
 		return
 	}
 
-	if err := ctrl.customerService.Update(id, &customer); err != nil {
+	// 销售代表不能转移客户负责人
+	ownerID := middleware.OwnerScope(c)
+	if ownerID > 0 {
+		customer.UserID = 0
+	}
+
+	if err := ctrl.customerService.Update(id, &customer, ownerID); err != nil {
 		utils.Error(c, 400, err.Error())
 		return
 	}
@@ -101,7 +108,7 @@ func (ctrl *CustomerController) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := ctrl.customerService.Delete(id); err != nil {
+	if err := ctrl.customerService.Delete(id, middleware.OwnerScope(c)); err != nil {
 		utils.Error(c, 400, err.Error())
 		return
 	}
@@ -9,12 +9,16 @@ import (
 )
 
 type InventoryController struct {
-	inventoryService *service.InventoryService
+	inventoryService     *service.InventoryService
+	stockMovementService *service.StockMovementService
+	stockAlertService    *service.StockAlertService
 }
 
 func NewInventoryController() *InventoryController {
 	return &InventoryController{
-		inventoryService: &service.InventoryService{},
+		inventoryService:     &service.InventoryService{},
+		stockMovementService: &service.StockMovementService{},
+		stockAlertService:    &service.StockAlertService{},
 	}
 }
 
@@ -26,7 +30,8 @@ func (ctrl *InventoryController) Create(c *gin.Context) {
 		return
 	}
 
-	id, err := ctrl.inventoryService.Create(&inventory)
+	userID, _ := c.Get("user_id")
+	id, err := ctrl.inventoryService.Create(&inventory, userID.(int64))
 	if err != nil {
 		utils.Error(c, 400, err.Error())
 		return
@@ -60,15 +65,14 @@ func (ctrl *InventoryController) Update(c *gin.Context) {
 		return
 	}
 
-	var req struct {
-		Quantity int `json:"quantity" binding:"required,gte=0"`
-	}
+	var req model.UpdateInventoryRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.BadRequest(c, "参数错误: "+err.Error())
 		return
 	}
 
-	if err := ctrl.inventoryService.Update(productID, req.Quantity); err != nil {
+	userID, _ := c.Get("user_id")
+	if err := ctrl.inventoryService.Update(productID, req.WarehouseID, *req.Quantity, userID.(int64), req.Note); err != nil {
 		utils.Error(c, 400, err.Error())
 		return
 	}
@@ -76,9 +80,11 @@ func (ctrl *InventoryController) Update(c *gin.Context) {
 	utils.SuccessWithMessage(c, "更新成功", nil)
 }
 
-// List 获取所有库存列表
+// List 获取库存列表，可按仓库筛选
 func (ctrl *InventoryController) List(c *gin.Context) {
-	inventories, err := ctrl.inventoryService.List()
+	warehouseID, _ := strconv.ParseInt(c.Query("warehouse_id"), 10, 64)
+
+	inventories, err := ctrl.inventoryService.List(warehouseID)
 	if err != nil {
 		utils.InternalError(c, err.Error())
 		return
@@ -88,3 +94,87 @@ func (ctrl *InventoryController) List(c *gin.Context) {
 		"list": inventories,
 	})
 }
+
+// Summary 按产品汇总各仓库库存
+func (ctrl *InventoryController) Summary(c *gin.Context) {
+	stocks, err := ctrl.inventoryService.Summary()
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list": stocks,
+	})
+}
+
+// Transfer 仓库间调拨库存
+func (ctrl *InventoryController) Transfer(c *gin.Context) {
+	var req model.TransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	if err := ctrl.inventoryService.Transfer(&req, userID.(int64)); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "调拨成功", nil)
+}
+
+// Movements 查询库存流水，支持按产品、仓库、类型和日期范围筛选
+func (ctrl *InventoryController) Movements(c *gin.Context) {
+	var query model.MovementQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	movements, err := ctrl.stockMovementService.List(&query)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list":      movements,
+		"page":      query.Page,
+		"page_size": query.PageSize,
+	})
+}
+
+// Reconcile 核对库存与流水，返回不一致的记录
+func (ctrl *InventoryController) Reconcile(c *gin.Context) {
+	discrepancies, err := ctrl.stockMovementService.Reconcile()
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list": discrepancies,
+	})
+}
+
+// Alerts 获取低库存预警及建议补货量，refresh=true 时立即重新检查
+func (ctrl *InventoryController) Alerts(c *gin.Context) {
+	if c.Query("refresh") == "true" {
+		if err := ctrl.stockAlertService.Check(); err != nil {
+			utils.InternalError(c, err.Error())
+			return
+		}
+	}
+
+	alerts, err := ctrl.stockAlertService.List()
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list": alerts,
+	})
+}
@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"crm-erp-system/middleware"
+	"crm-erp-system/model"
+	"crm-erp-system/service"
+	"crm-erp-system/utils"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+type LeadController struct {
+	leadService *service.LeadService
+}
+
+func NewLeadController() *LeadController {
+	return &LeadController{
+		leadService: &service.LeadService{},
+	}
+}
+
+// Create 创建线索
+func (ctrl *LeadController) Create(c *gin.Context) {
+	var lead model.Lead
+	if err := c.ShouldBindJSON(&lead); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	id, err := ctrl.leadService.Create(&lead, userID.(int64))
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{"id": id})
+}
+
+// Get 获取线索详情
+func (ctrl *LeadController) Get(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的线索ID")
+		return
+	}
+
+	lead, err := ctrl.leadService.GetByID(id, middleware.OwnerScope(c))
+	if err != nil {
+		utils.NotFound(c, err.Error())
+		return
+	}
+
+	utils.Success(c, lead)
+}
+
+// List 获取线索列表，可按状态筛选
+func (ctrl *LeadController) List(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+
+	leads, err := ctrl.leadService.List(c.Query("status"), page, pageSize, middleware.OwnerScope(c))
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list":      leads,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// Update 更新线索
+func (ctrl *LeadController) Update(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的线索ID")
+		return
+	}
+
+	var lead model.Lead
+	if err := c.ShouldBindJSON(&lead); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	if err := ctrl.leadService.Update(id, &lead, middleware.OwnerScope(c)); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "更新成功", nil)
+}
+
+// Delete 删除线索
+func (ctrl *LeadController) Delete(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的线索ID")
+		return
+	}
+
+	if err := ctrl.leadService.Delete(id, middleware.OwnerScope(c)); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "删除成功", nil)
+}
+
+// Convert 线索转化为客户
+func (ctrl *LeadController) Convert(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的线索ID")
+		return
+	}
+
+	var req model.ConvertLeadRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.BadRequest(c, "参数错误: "+err.Error())
+			return
+		}
+	}
+
+	// 销售代表转化的商机归属线索负责人
+	ownerID := middleware.OwnerScope(c)
+	if ownerID > 0 && req.Opportunity != nil {
+		req.Opportunity.UserID = 0
+	}
+
+	userID, _ := c.Get("user_id")
+	customerID, opportunityID, err := ctrl.leadService.Convert(id, &req, userID.(int64), ownerID)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "转化成功", gin.H{
+		"customer_id":    customerID,
+		"opportunity_id": opportunityID,
+	})
+}
@@ -0,0 +1,231 @@
+package controller
+
+import (
+	"crm-erp-system/middleware"
+	"crm-erp-system/model"
+	"crm-erp-system/service"
+	"crm-erp-system/utils"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+type OpportunityController struct {
+	opportunityService *service.OpportunityService
+}
+
+func NewOpportunityController() *OpportunityController {
+	return &OpportunityController{
+		opportunityService: &service.OpportunityService{},
+	}
+}
+
+// ListStages 获取销售阶段列表
+func (ctrl *OpportunityController) ListStages(c *gin.Context) {
+	stages, err := ctrl.opportunityService.ListStages()
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list": stages,
+	})
+}
+
+// CreateStage 创建销售阶段
+func (ctrl *OpportunityController) CreateStage(c *gin.Context) {
+	var stage model.PipelineStage
+	if err := c.ShouldBindJSON(&stage); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	id, err := ctrl.opportunityService.CreateStage(&stage)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{"id": id})
+}
+
+// UpdateStage 更新销售阶段
+func (ctrl *OpportunityController) UpdateStage(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的阶段ID")
+		return
+	}
+
+	var stage model.PipelineStage
+	if err := c.ShouldBindJSON(&stage); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	if err := ctrl.opportunityService.UpdateStage(id, &stage); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "更新成功", nil)
+}
+
+// DeleteStage 删除销售阶段
+func (ctrl *OpportunityController) DeleteStage(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的阶段ID")
+		return
+	}
+
+	if err := ctrl.opportunityService.DeleteStage(id); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "删除成功", nil)
+}
+
+// Create 创建商机
+func (ctrl *OpportunityController) Create(c *gin.Context) {
+	var opportunity model.Opportunity
+	if err := c.ShouldBindJSON(&opportunity); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	// 销售代表只能为自己的客户创建商机，负责人为本人
+	ownerID := middleware.OwnerScope(c)
+	if ownerID > 0 {
+		opportunity.UserID = 0
+	}
+
+	userID, _ := c.Get("user_id")
+	id, err := ctrl.opportunityService.Create(&opportunity, userID.(int64), ownerID)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{"id": id})
+}
+
+// Get 获取商机详情
+func (ctrl *OpportunityController) Get(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的商机ID")
+		return
+	}
+
+	opportunity, err := ctrl.opportunityService.GetByID(id, middleware.OwnerScope(c))
+	if err != nil {
+		utils.NotFound(c, err.Error())
+		return
+	}
+
+	utils.Success(c, opportunity)
+}
+
+// List 获取商机列表，可按阶段、负责人和客户筛选
+func (ctrl *OpportunityController) List(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	stageID, _ := strconv.ParseInt(c.Query("stage_id"), 10, 64)
+	ownerID, _ := strconv.ParseInt(c.Query("user_id"), 10, 64)
+	customerID, _ := strconv.ParseInt(c.Query("customer_id"), 10, 64)
+	if scope := middleware.OwnerScope(c); scope > 0 {
+		ownerID = scope
+	}
+
+	opportunities, err := ctrl.opportunityService.List(stageID, ownerID, customerID, page, pageSize)
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list":      opportunities,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// Update 更新商机
+func (ctrl *OpportunityController) Update(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的商机ID")
+		return
+	}
+
+	var opportunity model.Opportunity
+	if err := c.ShouldBindJSON(&opportunity); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	ownerID := middleware.OwnerScope(c)
+	if ownerID > 0 {
+		opportunity.UserID = 0
+	}
+
+	if err := ctrl.opportunityService.Update(id, &opportunity, ownerID); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "更新成功", nil)
+}
+
+// MoveStage 推进商机阶段
+func (ctrl *OpportunityController) MoveStage(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的商机ID")
+		return
+	}
+
+	var req struct {
+		StageID int64 `json:"stage_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	if err := ctrl.opportunityService.MoveStage(id, req.StageID, middleware.OwnerScope(c)); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "更新成功", nil)
+}
+
+// Delete 删除商机
+func (ctrl *OpportunityController) Delete(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的商机ID")
+		return
+	}
+
+	if err := ctrl.opportunityService.Delete(id, middleware.OwnerScope(c)); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "删除成功", nil)
+}
+
+// Pipeline 销售漏斗汇总：按阶段和负责人统计金额与加权预测
+func (ctrl *OpportunityController) Pipeline(c *gin.Context) {
+	summary, err := ctrl.opportunityService.Summary(c.Query("start_date"), c.Query("end_date"), middleware.OwnerScope(c))
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, summary)
+}
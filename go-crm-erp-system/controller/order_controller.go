@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"crm-erp-system/middleware"
 	"crm-erp-system/model"
 	"crm-erp-system/service"
 	"crm-erp-system/utils"
@@ -20,14 +21,14 @@ func NewOrderController() *OrderController {
 
 // Create 创建订单
 func (ctrl *OrderController) Create(c *gin.Context) {
-	var order model.Order
-	if err := c.ShouldBindJSON(&order); err != nil {
+	var req model.CreateOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.BadRequest(c, "参数错误: "+err.Error())
 		return
 	}
 
 	userID, _ := c.Get("user_id")
-	id, err := ctrl.orderService.Create(&order, userID.(int64))
+	id, err := ctrl.orderService.Create(&req, userID.(int64), middleware.OwnerScope(c))
 	if err != nil {
 		utils.Error(c, 400, err.Error())
 		return
@@ -44,7 +45,7 @@ func (ctrl *OrderController) Get(c *gin.Context) {
 		return
 	}
 
-	order, err := ctrl.orderService.GetByID(id)
+	order, err := ctrl.orderService.GetByID(id, middleware.OwnerScope(c))
 	if err != nil {
 		utils.NotFound(c, err.Error())
 		return
@@ -58,7 +59,7 @@ func (ctrl *OrderController) List(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
 
-	orders, err := ctrl.orderService.List(page, pageSize)
+	orders, err := ctrl.orderService.List(page, pageSize, middleware.OwnerScope(c))
 	if err != nil {
 		utils.InternalError(c, err.Error())
 		return
@@ -79,15 +80,14 @@ func (ctrl *OrderController) UpdateStatus(c *gin.Context) {
 		return
 	}
 
-	var req struct {
-		Status string `json:"status" binding:"required"`
-	}
+	var req model.UpdateOrderStatusRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.BadRequest(c, "参数错误: "+err.Error())
 		return
 	}
 
-	if err := ctrl.orderService.UpdateStatus(id, req.Status); err != nil {
+	userID, _ := c.Get("user_id")
+	if err := ctrl.orderService.UpdateStatus(id, req.Status, userID.(int64), req.Note, middleware.OwnerScope(c)); err != nil {
 		utils.Error(c, 400, err.Error())
 		return
 	}
@@ -95,6 +95,25 @@ func (ctrl *OrderController) UpdateStatus(c *gin.Context) {
 	utils.SuccessWithMessage(c, "更新成功", nil)
 }
 
+// History 获取订单状态变更记录
+func (ctrl *OrderController) History(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的订单ID")
+		return
+	}
+
+	history, err := ctrl.orderService.History(id, middleware.OwnerScope(c))
+	if err != nil {
+		utils.NotFound(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list": history,
+	})
+}
+
 // Delete 删除订单
 func (ctrl *OrderController) Delete(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
@@ -103,7 +122,7 @@ func (ctrl *OrderController) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := ctrl.orderService.Delete(id); err != nil {
+	if err := ctrl.orderService.Delete(id, middleware.OwnerScope(c)); err != nil {
 		utils.Error(c, 400, err.Error())
 		return
 	}
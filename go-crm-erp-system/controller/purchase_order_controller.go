@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"crm-erp-system/model"
+	"crm-erp-system/service"
+	"crm-erp-system/utils"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+type PurchaseOrderController struct {
+	purchaseOrderService *service.PurchaseOrderService
+}
+
+func NewPurchaseOrderController() *PurchaseOrderController {
+	return &PurchaseOrderController{
+		purchaseOrderService: &service.PurchaseOrderService{},
+	}
+}
+
+// Create 创建采购单
+func (ctrl *PurchaseOrderController) Create(c *gin.Context) {
+	var req model.CreatePurchaseOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	id, err := ctrl.purchaseOrderService.Create(&req, userID.(int64))
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{"id": id})
+}
+
+// Get 获取采购单详情
+func (ctrl *PurchaseOrderController) Get(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的采购单ID")
+		return
+	}
+
+	po, err := ctrl.purchaseOrderService.GetByID(id)
+	if err != nil {
+		utils.NotFound(c, err.Error())
+		return
+	}
+
+	utils.Success(c, po)
+}
+
+// List 获取采购单列表，可按供应商和状态筛选
+func (ctrl *PurchaseOrderController) List(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	supplierID, _ := strconv.ParseInt(c.Query("supplier_id"), 10, 64)
+
+	orders, err := ctrl.purchaseOrderService.List(supplierID, c.Query("status"), page, pageSize)
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list":      orders,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// Receive 采购收货（支持部分收货）
+func (ctrl *PurchaseOrderController) Receive(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的采购单ID")
+		return
+	}
+
+	var req model.ReceiveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	if err := ctrl.purchaseOrderService.Receive(id, &req, userID.(int64)); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "收货成功", nil)
+}
+
+// Cancel 取消采购单
+func (ctrl *PurchaseOrderController) Cancel(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的采购单ID")
+		return
+	}
+
+	if err := ctrl.purchaseOrderService.Cancel(id); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "取消成功", nil)
+}
+
+// Close 关闭部分收货的采购单
+func (ctrl *PurchaseOrderController) Close(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的采购单ID")
+		return
+	}
+
+	if err := ctrl.purchaseOrderService.Close(id); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "关闭成功", nil)
+}
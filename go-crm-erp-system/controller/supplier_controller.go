@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"crm-erp-system/model"
+	"crm-erp-system/service"
+	"crm-erp-system/utils"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+type SupplierController struct {
+	supplierService *service.SupplierService
+}
+
+func NewSupplierController() *SupplierController {
+	return &SupplierController{
+		supplierService: &service.SupplierService{},
+	}
+}
+
+// Create 创建供应商
+func (ctrl *SupplierController) Create(c *gin.Context) {
+	var supplier model.Supplier
+	if err := c.ShouldBindJSON(&supplier); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	id, err := ctrl.supplierService.Create(&supplier)
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{"id": id})
+}
+
+// Get 获取供应商详情
+func (ctrl *SupplierController) Get(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的供应商ID")
+		return
+	}
+
+	supplier, err := ctrl.supplierService.GetByID(id)
+	if err != nil {
+		utils.NotFound(c, err.Error())
+		return
+	}
+
+	utils.Success(c, supplier)
+}
+
+// List 获取供应商列表
+func (ctrl *SupplierController) List(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+
+	suppliers, err := ctrl.supplierService.List(page, pageSize)
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list":      suppliers,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// Update 更新供应商
+func (ctrl *SupplierController) Update(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的供应商ID")
+		return
+	}
+
+	var supplier model.Supplier
+	if err := c.ShouldBindJSON(&supplier); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	if err := ctrl.supplierService.Update(id, &supplier); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "更新成功", nil)
+}
+
+// Delete 删除供应商
+func (ctrl *SupplierController) Delete(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的供应商ID")
+		return
+	}
+
+	if err := ctrl.supplierService.Delete(id); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "删除成功", nil)
+}
+
+// AddPrice 录入供应商报价
+func (ctrl *SupplierController) AddPrice(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的供应商ID")
+		return
+	}
+
+	var price model.SupplierPrice
+	if err := c.ShouldBindJSON(&price); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	priceID, err := ctrl.supplierService.AddPrice(id, &price)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{"id": priceID})
+}
+
+// Prices 获取供应商价格历史
+func (ctrl *SupplierController) Prices(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的供应商ID")
+		return
+	}
+	productID, _ := strconv.ParseInt(c.Query("product_id"), 10, 64)
+
+	prices, err := ctrl.supplierService.Prices(id, productID)
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list": prices,
+	})
+}
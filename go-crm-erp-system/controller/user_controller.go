@@ -5,6 +5,7 @@ import (
 	"crm-erp-system/service"
 	"crm-erp-system/utils"
 	"github.com/gin-gonic/gin"
+	"strconv"
 )
 
 type UserController struct {
@@ -64,3 +65,43 @@ func (ctrl *UserController) GetUserInfo(c *gin.Context) {
 
 	utils.Success(c, user)
 }
+
+// List 获取用户列表
+func (ctrl *UserController) List(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+
+	users, err := ctrl.userService.List(page, pageSize)
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list":      users,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// UpdateRole 分配用户角色
+func (ctrl *UserController) UpdateRole(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的用户ID")
+		return
+	}
+
+	var req model.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	if err := ctrl.userService.UpdateRole(id, req.Role); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "更新成功", nil)
+}
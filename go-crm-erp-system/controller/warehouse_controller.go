@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"crm-erp-system/model"
+	"crm-erp-system/service"
+	"crm-erp-system/utils"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+type WarehouseController struct {
+	warehouseService *service.WarehouseService
+}
+
+func NewWarehouseController() *WarehouseController {
+	return &WarehouseController{
+		warehouseService: &service.WarehouseService{},
+	}
+}
+
+// Create 创建仓库
+func (ctrl *WarehouseController) Create(c *gin.Context) {
+	var warehouse model.Warehouse
+	if err := c.ShouldBindJSON(&warehouse); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	id, err := ctrl.warehouseService.Create(&warehouse)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{"id": id})
+}
+
+// Get 获取仓库详情
+func (ctrl *WarehouseController) Get(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的仓库ID")
+		return
+	}
+
+	warehouse, err := ctrl.warehouseService.GetByID(id)
+	if err != nil {
+		utils.NotFound(c, err.Error())
+		return
+	}
+
+	utils.Success(c, warehouse)
+}
+
+// List 获取仓库列表
+func (ctrl *WarehouseController) List(c *gin.Context) {
+	warehouses, err := ctrl.warehouseService.List()
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list": warehouses,
+	})
+}
+
+// Update 更新仓库
+func (ctrl *WarehouseController) Update(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的仓库ID")
+		return
+	}
+
+	var warehouse model.Warehouse
+	if err := c.ShouldBindJSON(&warehouse); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	if err := ctrl.warehouseService.Update(id, &warehouse); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "更新成功", nil)
+}
+
+// Delete 删除仓库
+func (ctrl *WarehouseController) Delete(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "无效的仓库ID")
+		return
+	}
+
+	if err := ctrl.warehouseService.Delete(id); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "删除成功", nil)
+}
@@ -1,8 +1,8 @@
 package database
 
 import (
-	"database/sql"
 	"crm-erp-system/config"
+	"database/sql"
 	_ "github.com/mattn/go-sqlite3"
 	"log"
 )
@@ -38,6 +38,7 @@ func createTables() error {
 		password VARCHAR(255) NOT NULL,
 		email VARCHAR(100),
 		phone VARCHAR(20),
+		role VARCHAR(20) DEFAULT 'sales_rep',
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
@@ -56,6 +57,72 @@ func createTables() error {
 		FOREIGN KEY (user_id) REFERENCES users(id)
 	);
 
+	CREATE TABLE IF NOT EXISTS leads (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name VARCHAR(100) NOT NULL,
+		company VARCHAR(100),
+		email VARCHAR(100),
+		phone VARCHAR(20),
+		source VARCHAR(50),
+		status VARCHAR(20) DEFAULT 'new',
+		notes TEXT,
+		customer_id INTEGER,
+		user_id INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (customer_id) REFERENCES customers(id),
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS pipeline_stages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name VARCHAR(50) NOT NULL,
+		probability INTEGER DEFAULT 0,
+		sort_order INTEGER DEFAULT 0,
+		is_won BOOLEAN DEFAULT 0,
+		is_lost BOOLEAN DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS opportunities (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name VARCHAR(100) NOT NULL,
+		customer_id INTEGER NOT NULL,
+		lead_id INTEGER,
+		stage_id INTEGER NOT NULL,
+		amount DECIMAL(12,2) DEFAULT 0,
+		expected_close_date VARCHAR(20),
+		notes TEXT,
+		user_id INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (customer_id) REFERENCES customers(id),
+		FOREIGN KEY (lead_id) REFERENCES leads(id),
+		FOREIGN KEY (stage_id) REFERENCES pipeline_stages(id),
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS activities (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		customer_id INTEGER NOT NULL,
+		type VARCHAR(20) NOT NULL,
+		subject VARCHAR(200) NOT NULL,
+		content TEXT,
+		due_date VARCHAR(20),
+		assigned_to INTEGER,
+		status VARCHAR(20) DEFAULT 'done',
+		completed_at DATETIME,
+		user_id INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (customer_id) REFERENCES customers(id),
+		FOREIGN KEY (assigned_to) REFERENCES users(id),
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_activities_customer ON activities(customer_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_activities_assigned ON activities(assigned_to, status, due_date);
+
 	CREATE TABLE IF NOT EXISTS products (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		name VARCHAR(100) NOT NULL,
@@ -64,6 +131,19 @@ func createTables() error {
 		price DECIMAL(10,2) NOT NULL,
 		cost DECIMAL(10,2),
 		category VARCHAR(50),
+		reorder_point INTEGER DEFAULT 0,
+		safety_stock INTEGER DEFAULT 0,
+		lead_time_days INTEGER DEFAULT 7,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS warehouses (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		code VARCHAR(50) UNIQUE NOT NULL,
+		name VARCHAR(100) NOT NULL,
+		address TEXT,
+		status VARCHAR(20) DEFAULT 'active',
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
@@ -71,9 +151,111 @@ func createTables() error {
 	CREATE TABLE IF NOT EXISTS inventory (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		product_id INTEGER NOT NULL,
+		warehouse_id INTEGER,
 		quantity INTEGER DEFAULT 0,
-		warehouse VARCHAR(50),
+		reserved INTEGER DEFAULT 0,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (product_id) REFERENCES products(id),
+		FOREIGN KEY (warehouse_id) REFERENCES warehouses(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS stock_movements (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		product_id INTEGER NOT NULL,
+		warehouse_id INTEGER NOT NULL,
+		type VARCHAR(20) NOT NULL,
+		quantity INTEGER NOT NULL,
+		balance_after INTEGER NOT NULL,
+		ref_type VARCHAR(20),
+		ref_id INTEGER,
+		note TEXT,
+		user_id INTEGER,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (product_id) REFERENCES products(id),
+		FOREIGN KEY (warehouse_id) REFERENCES warehouses(id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_stock_movements_product ON stock_movements(product_id, created_at);
+
+	-- 库存流水只允许追加
+	CREATE TRIGGER IF NOT EXISTS stock_movements_no_update BEFORE UPDATE ON stock_movements
+	BEGIN
+		SELECT RAISE(ABORT, 'stock_movements is append-only');
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS stock_movements_no_delete BEFORE DELETE ON stock_movements
+	BEGIN
+		SELECT RAISE(ABORT, 'stock_movements is append-only');
+	END;
+
+	CREATE TABLE IF NOT EXISTS stock_alerts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		product_id INTEGER UNIQUE NOT NULL,
+		available INTEGER NOT NULL,
+		reorder_point INTEGER NOT NULL,
+		safety_stock INTEGER NOT NULL,
+		daily_velocity DECIMAL(10,4) DEFAULT 0,
+		suggested_quantity INTEGER DEFAULT 0,
+		status VARCHAR(20) DEFAULT 'open',
+		triggered_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		resolved_at DATETIME,
+		FOREIGN KEY (product_id) REFERENCES products(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS suppliers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name VARCHAR(100) NOT NULL,
+		contact VARCHAR(50),
+		phone VARCHAR(20),
+		email VARCHAR(100),
+		address TEXT,
+		status VARCHAR(20) DEFAULT 'active',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS supplier_prices (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		supplier_id INTEGER NOT NULL,
+		product_id INTEGER NOT NULL,
+		price DECIMAL(10,2) NOT NULL,
+		purchase_order_id INTEGER,
+		purchase_order_item_id INTEGER,
+		note TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (supplier_id) REFERENCES suppliers(id),
+		FOREIGN KEY (product_id) REFERENCES products(id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_supplier_prices_product ON supplier_prices(product_id, created_at);
+
+	CREATE TABLE IF NOT EXISTS purchase_orders (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		po_no VARCHAR(50) UNIQUE NOT NULL,
+		supplier_id INTEGER NOT NULL,
+		warehouse_id INTEGER NOT NULL,
+		total_amount DECIMAL(10,2) NOT NULL,
+		status VARCHAR(20) DEFAULT 'pending',
+		expected_date VARCHAR(20),
+		note TEXT,
+		user_id INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (supplier_id) REFERENCES suppliers(id),
+		FOREIGN KEY (warehouse_id) REFERENCES warehouses(id),
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS purchase_order_items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		purchase_order_id INTEGER NOT NULL,
+		product_id INTEGER NOT NULL,
+		quantity INTEGER NOT NULL,
+		received_quantity INTEGER DEFAULT 0,
+		unit_cost DECIMAL(10,2) NOT NULL,
+		amount DECIMAL(10,2) NOT NULL,
+		FOREIGN KEY (purchase_order_id) REFERENCES purchase_orders(id),
 		FOREIGN KEY (product_id) REFERENCES products(id)
 	);
 
@@ -81,24 +263,318 @@ func createTables() error {
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		order_no VARCHAR(50) UNIQUE NOT NULL,
 		customer_id INTEGER NOT NULL,
-		product_id INTEGER NOT NULL,
-		quantity INTEGER NOT NULL,
-		unit_price DECIMAL(10,2) NOT NULL,
+		subtotal DECIMAL(10,2) DEFAULT 0,
+		discount_amount DECIMAL(10,2) DEFAULT 0,
+		tax_rate DECIMAL(5,4) DEFAULT 0,
+		tax_amount DECIMAL(10,2) DEFAULT 0,
 		total_amount DECIMAL(10,2) NOT NULL,
 		status VARCHAR(20) DEFAULT 'pending',
 		user_id INTEGER NOT NULL,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY (customer_id) REFERENCES customers(id),
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS order_items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		order_id INTEGER NOT NULL,
+		product_id INTEGER NOT NULL,
+		warehouse_id INTEGER,
+		quantity INTEGER NOT NULL,
+		unit_price DECIMAL(10,2) NOT NULL,
+		discount DECIMAL(10,2) DEFAULT 0,
+		amount DECIMAL(10,2) NOT NULL,
+		stock_tracked BOOLEAN DEFAULT 1,
+		FOREIGN KEY (order_id) REFERENCES orders(id),
 		FOREIGN KEY (product_id) REFERENCES products(id),
+		FOREIGN KEY (warehouse_id) REFERENCES warehouses(id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_order_items_order_id ON order_items(order_id);
+
+	CREATE TABLE IF NOT EXISTS order_status_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		order_id INTEGER NOT NULL,
+		from_status VARCHAR(20),
+		to_status VARCHAR(20) NOT NULL,
+		user_id INTEGER NOT NULL,
+		note TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (order_id) REFERENCES orders(id),
 		FOREIGN KEY (user_id) REFERENCES users(id)
 	);
+
+	CREATE INDEX IF NOT EXISTS idx_order_status_history_order_id ON order_status_history(order_id);
 	`
 
-	_, err := DB.Exec(schema)
+	if _, err := DB.Exec(schema); err != nil {
+		return err
+	}
+
+	return migrateTables()
+}
+
+// migrateTables 为旧版本数据库补充新增字段
+func migrateTables() error {
+	columns := []struct{ table, column, definition string }{
+		{"users", "role", "VARCHAR(20) DEFAULT 'sales_rep'"},
+		{"inventory", "reserved", "INTEGER DEFAULT 0"},
+		{"products", "reorder_point", "INTEGER DEFAULT 0"},
+		{"products", "safety_stock", "INTEGER DEFAULT 0"},
+		{"products", "lead_time_days", "INTEGER DEFAULT 7"},
+		{"order_items", "stock_tracked", "BOOLEAN DEFAULT 1"},
+		{"supplier_prices", "purchase_order_item_id", "INTEGER"},
+	}
+	for _, col := range columns {
+		if err := addColumnIfNotExists(col.table, col.column, col.definition); err != nil {
+			return err
+		}
+	}
+	if err := migrateOrderItems(); err != nil {
+		return err
+	}
+	if err := migrateWarehouses(); err != nil {
+		return err
+	}
+	if err := migrateSupplierPrices(); err != nil {
+		return err
+	}
+	if err := ensureAdmin(); err != nil {
+		return err
+	}
+	return seedPipelineStages()
+}
+
+// ensureAdmin 引入角色前的老数据没有管理员，将最早注册的用户设为管理员，避免无人可以分配角色
+func ensureAdmin() error {
+	var count int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM users WHERE role = 'admin'").Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	_, err := DB.Exec("UPDATE users SET role = 'admin' WHERE id = (SELECT MIN(id) FROM users)")
 	return err
 }
 
+// seedPipelineStages 首次启动时初始化默认销售阶段
+func seedPipelineStages() error {
+	var count int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM pipeline_stages").Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	stages := []struct {
+		name        string
+		probability int
+		isWon       bool
+		isLost      bool
+	}{
+		{"初步接洽", 10, false, false},
+		{"需求确认", 30, false, false},
+		{"方案报价", 60, false, false},
+		{"商务谈判", 80, false, false},
+		{"赢单", 100, true, false},
+		{"输单", 0, false, true},
+	}
+	for i, stage := range stages {
+		if _, err := DB.Exec(
+			"INSERT INTO pipeline_stages (name, probability, sort_order, is_won, is_lost) VALUES (?, ?, ?, ?, ?)",
+			stage.name, stage.probability, i+1, stage.isWon, stage.isLost,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DefaultWarehouseCode 未指定仓库时使用的默认仓库编码
+const DefaultWarehouseCode = "DEFAULT"
+
+// migrateWarehouses 将旧版自由文本仓库字段迁移为仓库实体，并保证每个(产品, 仓库)只有一条库存记录
+func migrateWarehouses() error {
+	if _, err := DB.Exec("INSERT OR IGNORE INTO warehouses (code, name) VALUES (?, ?)", DefaultWarehouseCode, "默认仓库"); err != nil {
+		return err
+	}
+	if err := addColumnIfNotExists("inventory", "warehouse_id", "INTEGER REFERENCES warehouses(id)"); err != nil {
+		return err
+	}
+	if err := addColumnIfNotExists("order_items", "warehouse_id", "INTEGER REFERENCES warehouses(id)"); err != nil {
+		return err
+	}
+
+	var statements []string
+	legacy, err := hasColumn("inventory", "warehouse")
+	if err != nil {
+		return err
+	}
+	if legacy {
+		statements = append(statements,
+			`INSERT OR IGNORE INTO warehouses (code, name)
+				SELECT DISTINCT warehouse, warehouse FROM inventory
+				WHERE warehouse_id IS NULL AND COALESCE(warehouse, '') <> ''`,
+			`UPDATE inventory SET warehouse_id = (SELECT id FROM warehouses WHERE code = inventory.warehouse)
+				WHERE warehouse_id IS NULL AND COALESCE(warehouse, '') <> ''`,
+		)
+	}
+	statements = append(statements,
+		`UPDATE inventory SET warehouse_id = (SELECT id FROM warehouses WHERE code = '`+DefaultWarehouseCode+`')
+			WHERE warehouse_id IS NULL`,
+		// 合并同一产品同一仓库的重复记录
+		`UPDATE inventory SET
+			quantity = (SELECT SUM(quantity) FROM inventory i WHERE i.product_id = inventory.product_id AND i.warehouse_id = inventory.warehouse_id),
+			reserved = (SELECT SUM(reserved) FROM inventory i WHERE i.product_id = inventory.product_id AND i.warehouse_id = inventory.warehouse_id)
+			WHERE id IN (SELECT MIN(id) FROM inventory GROUP BY product_id, warehouse_id HAVING COUNT(*) > 1)`,
+		`DELETE FROM inventory WHERE id NOT IN (SELECT MIN(id) FROM inventory GROUP BY product_id, warehouse_id)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_inventory_product_warehouse ON inventory(product_id, warehouse_id)`,
+		`UPDATE order_items SET warehouse_id = (SELECT warehouse_id FROM inventory WHERE inventory.product_id = order_items.product_id ORDER BY id LIMIT 1)
+			WHERE warehouse_id IS NULL`,
+		// 没有流水的历史库存补记期初余额，使流水汇总与库存一致
+		`INSERT INTO stock_movements (product_id, warehouse_id, type, quantity, balance_after, note)
+			SELECT product_id, warehouse_id, 'adjustment', quantity, quantity, '期初余额' FROM inventory i
+			WHERE quantity <> 0 AND NOT EXISTS (
+				SELECT 1 FROM stock_movements m WHERE m.product_id = i.product_id AND m.warehouse_id = i.warehouse_id
+			)`,
+	)
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// migrateSupplierPrices 采购价按采购明细记录：同一采购单中同一产品可以有多行不同单价，
+// 旧数据先按产品和单价找回对应的明细，找不到时取该产品的第一行明细，同一明细重复记录的采购价只保留一条
+func migrateSupplierPrices() error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		`DROP INDEX IF EXISTS idx_supplier_prices_po_product`,
+		`UPDATE supplier_prices SET purchase_order_item_id = (
+			SELECT MIN(poi.id) FROM purchase_order_items poi
+			WHERE poi.purchase_order_id = supplier_prices.purchase_order_id AND poi.product_id = supplier_prices.product_id
+				AND poi.unit_cost = supplier_prices.price
+		) WHERE purchase_order_id IS NOT NULL AND purchase_order_item_id IS NULL`,
+		`UPDATE supplier_prices SET purchase_order_item_id = (
+			SELECT MIN(poi.id) FROM purchase_order_items poi
+			WHERE poi.purchase_order_id = supplier_prices.purchase_order_id AND poi.product_id = supplier_prices.product_id
+		) WHERE purchase_order_id IS NOT NULL AND purchase_order_item_id IS NULL`,
+		`DELETE FROM supplier_prices WHERE purchase_order_item_id IS NOT NULL AND id NOT IN (
+			SELECT MIN(id) FROM supplier_prices WHERE purchase_order_item_id IS NOT NULL GROUP BY purchase_order_item_id
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_supplier_prices_po_item ON supplier_prices(purchase_order_item_id)`,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// migrateOrderItems 将旧版单产品订单拆分为订单头 + order_items 明细。
+// 旧版下单时没有预留库存，未发货订单的明细标记为未跟踪库存，首次状态变更时再按新状态占用；
+// 已发货的订单视为已出库
+func migrateOrderItems() error {
+	legacy, err := hasColumn("orders", "product_id")
+	if err != nil || !legacy {
+		return err
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`INSERT INTO order_items (order_id, product_id, quantity, unit_price, discount, amount, stock_tracked)
+			SELECT id, product_id, quantity, unit_price, 0, total_amount,
+				CASE WHEN status IN ('pending', 'confirmed') THEN 0 ELSE 1 END FROM orders`,
+		`CREATE TABLE orders_new (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_no VARCHAR(50) UNIQUE NOT NULL,
+			customer_id INTEGER NOT NULL,
+			subtotal DECIMAL(10,2) DEFAULT 0,
+			discount_amount DECIMAL(10,2) DEFAULT 0,
+			tax_rate DECIMAL(5,4) DEFAULT 0,
+			tax_amount DECIMAL(10,2) DEFAULT 0,
+			total_amount DECIMAL(10,2) NOT NULL,
+			status VARCHAR(20) DEFAULT 'pending',
+			user_id INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (customer_id) REFERENCES customers(id),
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)`,
+		`INSERT INTO orders_new (id, order_no, customer_id, subtotal, total_amount, status, user_id, created_at, updated_at)
+			SELECT id, order_no, customer_id, total_amount, total_amount, status, user_id, created_at, updated_at FROM orders`,
+		`DROP TABLE orders`,
+		`ALTER TABLE orders_new RENAME TO orders`,
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	log.Println("订单表已迁移为多明细结构")
+	return tx.Commit()
+}
+
+func addColumnIfNotExists(table, column, definition string) error {
+	exists, err := hasColumn(table, column)
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = DB.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
+	return err
+}
+
+func hasColumn(table, column string) (bool, error) {
+	rows, err := DB.Query("PRAGMA table_info(" + table + ")")
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    int
+			defaultVal sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
 func CloseDB() {
 	if DB != nil {
 		DB.Close()
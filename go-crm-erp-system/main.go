@@ -4,7 +4,9 @@ import (
 	"crm-erp-system/config"
 	"crm-erp-system/database"
 	"crm-erp-system/router"
+	"crm-erp-system/service"
 	"log"
+	"time"
 )
 
 func main() {
@@ -17,6 +19,10 @@ func main() {
 	}
 	defer database.CloseDB()
 
+	// 启动库存预警后台检查
+	stopAlertChecker := service.StartAlertChecker(time.Duration(config.AppConfig.AlertIntervalMinutes) * time.Minute)
+	defer stopAlertChecker()
+
 	// 初始化路由
 	r := router.SetupRouter()
 
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"crm-erp-system/config"
+	"crm-erp-system/database"
 	"crm-erp-system/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -11,6 +12,7 @@ import (
 type Claims struct {
 	UserID   int64  `json:"user_id"`
 	Username string `json:"username"`
+	Role     string `json:"role"`
 	jwt.RegisteredClaims
 }
 
@@ -44,9 +46,18 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// 角色以数据库为准，修改角色或删除用户后立即生效，不等token过期
+		var role string
+		if err := database.DB.QueryRow("SELECT role FROM users WHERE id = ?", claims.UserID).Scan(&role); err != nil {
+			utils.Unauthorized(c, "用户不存在，请重新登录")
+			c.Abort()
+			return
+		}
+
 		// 将用户信息存入上下文
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
+		c.Set("role", role)
 		c.Next()
 	}
 }
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"crm-erp-system/model"
+	"crm-erp-system/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRoles 只允许指定角色访问，需放在 AuthMiddleware 之后
+func RequireRoles(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, r := range roles {
+			if r == role {
+				c.Next()
+				return
+			}
+		}
+
+		utils.Forbidden(c, "没有权限执行该操作")
+		c.Abort()
+	}
+}
+
+// OwnerScope 返回行级数据权限范围：销售代表只能访问自己负责的数据，返回其用户ID；
+// 其他角色返回 0，表示不限制
+func OwnerScope(c *gin.Context) int64 {
+	if c.GetString("role") != model.RoleSalesRep {
+		return 0
+	}
+	return c.GetInt64("user_id")
+}
@@ -0,0 +1,51 @@
+package model
+
+import "time"
+
+// 活动类型
+const (
+	ActivityNote     = "note"
+	ActivityCall     = "call"
+	ActivityEmail    = "email"
+	ActivityMeeting  = "meeting"
+	ActivityFollowUp = "follow_up"
+)
+
+// 活动状态：已发生的沟通记录为 done，待跟进任务为 open，完成后变为 done
+const (
+	ActivityOpen = "open"
+	ActivityDone = "done"
+)
+
+type Activity struct {
+	ID          int64      `json:"id"`
+	CustomerID  int64      `json:"customer_id" binding:"required"`
+	Type        string     `json:"type" binding:"required,oneof=note call email meeting follow_up"`
+	Subject     string     `json:"subject" binding:"required"`
+	Content     string     `json:"content"`
+	DueDate     string     `json:"due_date" binding:"omitempty,datetime=2006-01-02"`
+	AssignedTo  int64      `json:"assigned_to"`
+	Status      string     `json:"status" binding:"omitempty,oneof=open done"`
+	CompletedAt *time.Time `json:"completed_at"`
+	UserID      int64      `json:"user_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type ActivityQuery struct {
+	CustomerID int64  `form:"customer_id"`
+	Type       string `form:"type"`
+	AssignedTo int64  `form:"assigned_to"`
+	Status     string `form:"status"`
+	Overdue    bool   `form:"overdue"`
+	Page       int    `form:"page"`
+	PageSize   int    `form:"page_size"`
+}
+
+// TimelineEntry 客户时间线条目，Type 为 activity 或 order
+type TimelineEntry struct {
+	Type       string    `json:"type"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Activity   *Activity `json:"activity,omitempty"`
+	Order      *Order    `json:"order,omitempty"`
+}
@@ -3,9 +3,35 @@ package model
 import "time"
 
 type Inventory struct {
-	ID        int64     `json:"id"`
-	ProductID int64     `json:"product_id" binding:"required"`
-	Quantity  int       `json:"quantity" binding:"required,gte=0"`
-	Warehouse string    `json:"warehouse"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            int64     `json:"id"`
+	ProductID     int64     `json:"product_id" binding:"required"`
+	WarehouseID   int64     `json:"warehouse_id"`
+	WarehouseName string    `json:"warehouse_name"`
+	Quantity      int       `json:"quantity" binding:"required,gte=0"`
+	Reserved      int       `json:"reserved"`
+	Available     int       `json:"available"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ProductStock 产品在所有仓库的汇总库存
+type ProductStock struct {
+	ProductID  int64       `json:"product_id"`
+	Quantity   int         `json:"quantity"`
+	Reserved   int         `json:"reserved"`
+	Available  int         `json:"available"`
+	Warehouses []Inventory `json:"warehouses,omitempty"`
+}
+
+type UpdateInventoryRequest struct {
+	WarehouseID int64  `json:"warehouse_id"`
+	Quantity    *int   `json:"quantity" binding:"required,gte=0"`
+	Note        string `json:"note"`
+}
+
+type TransferRequest struct {
+	ProductID       int64  `json:"product_id" binding:"required"`
+	FromWarehouseID int64  `json:"from_warehouse_id" binding:"required"`
+	ToWarehouseID   int64  `json:"to_warehouse_id" binding:"required,nefield=FromWarehouseID"`
+	Quantity        int    `json:"quantity" binding:"required,gt=0"`
+	Note            string `json:"note"`
 }
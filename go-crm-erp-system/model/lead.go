@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// 线索状态
+const (
+	LeadNew       = "new"
+	LeadContacted = "contacted"
+	LeadQualified = "qualified"
+	LeadConverted = "converted"
+	LeadLost      = "lost"
+)
+
+type Lead struct {
+	ID         int64     `json:"id"`
+	Name       string    `json:"name" binding:"required"`
+	Company    string    `json:"company"`
+	Email      string    `json:"email"`
+	Phone      string    `json:"phone"`
+	Source     string    `json:"source"`
+	Status     string    `json:"status" binding:"omitempty,oneof=new contacted qualified lost"`
+	Notes      string    `json:"notes"`
+	CustomerID int64     `json:"customer_id"`
+	UserID     int64     `json:"user_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ConvertLeadRequest 线索转化为客户，可同时创建商机
+type ConvertLeadRequest struct {
+	Opportunity *Opportunity `json:"opportunity"`
+}
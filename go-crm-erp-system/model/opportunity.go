@@ -0,0 +1,55 @@
+package model
+
+import "time"
+
+// PipelineStage 销售阶段，Probability 为赢单概率百分比
+type PipelineStage struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name" binding:"required"`
+	Probability int       `json:"probability" binding:"gte=0,lte=100"`
+	SortOrder   int       `json:"sort_order"`
+	IsWon       bool      `json:"is_won"`
+	IsLost      bool      `json:"is_lost"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type Opportunity struct {
+	ID                int64     `json:"id"`
+	Name              string    `json:"name" binding:"required"`
+	CustomerID        int64     `json:"customer_id"`
+	LeadID            int64     `json:"lead_id"`
+	StageID           int64     `json:"stage_id"`
+	StageName         string    `json:"stage_name"`
+	Probability       int       `json:"probability"`
+	Amount            float64   `json:"amount" binding:"gte=0"`
+	ExpectedCloseDate string    `json:"expected_close_date" binding:"omitempty,datetime=2006-01-02"`
+	Notes             string    `json:"notes"`
+	UserID            int64     `json:"user_id"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// PipelineSummary 销售漏斗汇总，加权预测 = 金额 × 阶段赢单概率
+type PipelineSummary struct {
+	ByStage       []StageForecast `json:"by_stage"`
+	ByUser        []UserForecast  `json:"by_user"`
+	TotalAmount   float64         `json:"total_amount"`
+	TotalWeighted float64         `json:"total_weighted"`
+}
+
+type StageForecast struct {
+	StageID        int64   `json:"stage_id"`
+	StageName      string  `json:"stage_name"`
+	Probability    int     `json:"probability"`
+	Count          int     `json:"count"`
+	Amount         float64 `json:"amount"`
+	WeightedAmount float64 `json:"weighted_amount"`
+}
+
+type UserForecast struct {
+	UserID         int64   `json:"user_id"`
+	Username       string  `json:"username"`
+	Count          int     `json:"count"`
+	Amount         float64 `json:"amount"`
+	WeightedAmount float64 `json:"weighted_amount"`
+}
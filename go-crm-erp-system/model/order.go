@@ -3,15 +3,51 @@ package model
 import "time"
 
 type Order struct {
-	ID          int64     `json:"id"`
-	OrderNo     string    `json:"order_no"`
-	CustomerID  int64     `json:"customer_id" binding:"required"`
-	ProductID   int64     `json:"product_id" binding:"required"`
-	Quantity    int       `json:"quantity" binding:"required,gt=0"`
-	UnitPrice   float64   `json:"unit_price" binding:"required,gt=0"`
-	TotalAmount float64   `json:"total_amount"`
-	Status      string    `json:"status"`
-	UserID      int64     `json:"user_id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID             int64       `json:"id"`
+	OrderNo        string      `json:"order_no"`
+	CustomerID     int64       `json:"customer_id"`
+	Subtotal       float64     `json:"subtotal"`
+	DiscountAmount float64     `json:"discount_amount"`
+	TaxRate        float64     `json:"tax_rate"`
+	TaxAmount      float64     `json:"tax_amount"`
+	TotalAmount    float64     `json:"total_amount"`
+	Status         string      `json:"status"`
+	UserID         int64       `json:"user_id"`
+	Items          []OrderItem `json:"items"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+}
+
+type OrderItem struct {
+	ID          int64   `json:"id"`
+	OrderID     int64   `json:"order_id"`
+	ProductID   int64   `json:"product_id" binding:"required"`
+	WarehouseID int64   `json:"warehouse_id"`
+	Quantity    int     `json:"quantity" binding:"required,gt=0"`
+	UnitPrice   float64 `json:"unit_price" binding:"gte=0"`
+	Discount    float64 `json:"discount" binding:"gte=0"`
+	Amount      float64 `json:"amount"`
+	// StockTracked 为 false 表示迁移前未发货的历史明细，尚未预留库存
+	StockTracked bool `json:"-"`
+}
+
+type CreateOrderRequest struct {
+	CustomerID int64       `json:"customer_id" binding:"required"`
+	TaxRate    *float64    `json:"tax_rate" binding:"omitempty,gte=0,lte=1"`
+	Items      []OrderItem `json:"items" binding:"required,min=1,dive"`
+}
+
+type OrderStatusHistory struct {
+	ID         int64     `json:"id"`
+	OrderID    int64     `json:"order_id"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	UserID     int64     `json:"user_id"`
+	Note       string    `json:"note"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type UpdateOrderStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+	Note   string `json:"note"`
 }
@@ -0,0 +1,57 @@
+package model
+
+import "time"
+
+// 采购单状态
+const (
+	PurchasePending   = "pending"   // 待收货
+	PurchasePartial   = "partial"   // 部分收货
+	PurchaseReceived  = "received"  // 已全部收货
+	PurchaseCancelled = "cancelled" // 已取消
+	PurchaseClosed    = "closed"    // 部分收货后关闭，剩余数量不再收货
+)
+
+type PurchaseOrder struct {
+	ID           int64               `json:"id"`
+	PONo         string              `json:"po_no"`
+	SupplierID   int64               `json:"supplier_id"`
+	WarehouseID  int64               `json:"warehouse_id"`
+	TotalAmount  float64             `json:"total_amount"`
+	Status       string              `json:"status"`
+	ExpectedDate string              `json:"expected_date"`
+	Note         string              `json:"note"`
+	UserID       int64               `json:"user_id"`
+	Items        []PurchaseOrderItem `json:"items,omitempty"`
+	CreatedAt    time.Time           `json:"created_at"`
+	UpdatedAt    time.Time           `json:"updated_at"`
+}
+
+type PurchaseOrderItem struct {
+	ID               int64   `json:"id"`
+	PurchaseOrderID  int64   `json:"purchase_order_id"`
+	ProductID        int64   `json:"product_id" binding:"required"`
+	Quantity         int     `json:"quantity" binding:"required,gt=0"`
+	ReceivedQuantity int     `json:"received_quantity"`
+	UnitCost         float64 `json:"unit_cost" binding:"required,gt=0"`
+	Amount           float64 `json:"amount"`
+}
+
+type CreatePurchaseOrderRequest struct {
+	SupplierID   int64               `json:"supplier_id" binding:"required"`
+	WarehouseID  int64               `json:"warehouse_id"`
+	ExpectedDate string              `json:"expected_date"`
+	Note         string              `json:"note"`
+	Items        []PurchaseOrderItem `json:"items" binding:"required,min=1,dive"`
+}
+
+// ReceiveRequest 采购收货，可分多次部分收货
+type ReceiveRequest struct {
+	WarehouseID int64         `json:"warehouse_id"`
+	Note        string        `json:"note"`
+	Items       []ReceiveItem `json:"items" binding:"required,min=1,dive"`
+}
+
+type ReceiveItem struct {
+	ItemID   int64 `json:"item_id" binding:"required"`
+	Quantity int   `json:"quantity" binding:"required,gt=0"`
+}
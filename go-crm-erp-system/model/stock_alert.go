@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+type StockAlert struct {
+	ID                int64     `json:"id"`
+	ProductID         int64     `json:"product_id"`
+	ProductName       string    `json:"product_name"`
+	SKU               string    `json:"sku"`
+	Available         int       `json:"available"`
+	ReorderPoint      int       `json:"reorder_point"`
+	SafetyStock       int       `json:"safety_stock"`
+	DailyVelocity     float64   `json:"daily_velocity"`
+	SuggestedQuantity int       `json:"suggested_quantity"`
+	Status            string    `json:"status"`
+	TriggeredAt       time.Time `json:"triggered_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
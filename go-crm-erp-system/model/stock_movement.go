@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+// 库存流水类型，数量为带符号的变动值
+const (
+	MovementReceipt    = "receipt"    // 入库
+	MovementSale       = "sale"       // 销售出库
+	MovementAdjustment = "adjustment" // 盘点调整
+	MovementTransfer   = "transfer"   // 仓库调拨
+	MovementReturn     = "return"     // 退货入库
+)
+
+type StockMovement struct {
+	ID           int64     `json:"id"`
+	ProductID    int64     `json:"product_id"`
+	WarehouseID  int64     `json:"warehouse_id"`
+	Type         string    `json:"type"`
+	Quantity     int       `json:"quantity"`
+	BalanceAfter int       `json:"balance_after"`
+	RefType      string    `json:"ref_type"`
+	RefID        int64     `json:"ref_id"`
+	Note         string    `json:"note"`
+	UserID       int64     `json:"user_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type MovementQuery struct {
+	ProductID   int64  `form:"product_id"`
+	WarehouseID int64  `form:"warehouse_id"`
+	Type        string `form:"type"`
+	StartDate   string `form:"start_date"`
+	EndDate     string `form:"end_date"`
+	Page        int    `form:"page"`
+	PageSize    int    `form:"page_size"`
+}
+
+// StockDiscrepancy 库存表数量与流水汇总不一致的记录
+type StockDiscrepancy struct {
+	ProductID      int64 `json:"product_id"`
+	WarehouseID    int64 `json:"warehouse_id"`
+	Quantity       int   `json:"quantity"`
+	LedgerQuantity int   `json:"ledger_quantity"`
+	Difference     int   `json:"difference"`
+}
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+type Supplier struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name" binding:"required"`
+	Contact   string    `json:"contact"`
+	Phone     string    `json:"phone"`
+	Email     string    `json:"email"`
+	Address   string    `json:"address"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SupplierPrice 供应商报价/采购价历史
+type SupplierPrice struct {
+	ID              int64     `json:"id"`
+	SupplierID      int64     `json:"supplier_id"`
+	ProductID       int64     `json:"product_id" binding:"required"`
+	Price           float64   `json:"price" binding:"required,gt=0"`
+	PurchaseOrderID int64     `json:"purchase_order_id"`
+	Note            string    `json:"note"`
+	CreatedAt       time.Time `json:"created_at"`
+}
@@ -2,12 +2,21 @@ package model
 
 import "time"
 
+// 用户角色
+const (
+	RoleAdmin        = "admin"
+	RoleSalesManager = "sales_manager"
+	RoleSalesRep     = "sales_rep"
+	RoleWarehouse    = "warehouse"
+)
+
 type User struct {
 	ID        int64     `json:"id"`
 	Username  string    `json:"username" binding:"required"`
 	Password  string    `json:"password,omitempty" binding:"required"`
 	Email     string    `json:"email"`
 	Phone     string    `json:"phone"`
+	Role      string    `json:"role"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -23,3 +32,7 @@ type RegisterRequest struct {
 	Email    string `json:"email" binding:"omitempty,email"`
 	Phone    string `json:"phone"`
 }
+
+type UpdateRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=admin sales_manager sales_rep warehouse"`
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+type Warehouse struct {
+	ID        int64     `json:"id"`
+	Code      string    `json:"code" binding:"required"`
+	Name      string    `json:"name" binding:"required"`
+	Address   string    `json:"address"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -3,6 +3,7 @@ package router
 import (
 	"crm-erp-system/controller"
 	"crm-erp-system/middleware"
+	"crm-erp-system/model"
 	"github.com/gin-gonic/gin"
 	"net/http"
 )
@@ -21,9 +22,22 @@ func SetupRouter() *gin.Engine {
 	// 初始化控制器
 	userCtrl := controller.NewUserController()
 	customerCtrl := controller.NewCustomerController()
+	activityCtrl := controller.NewActivityController()
+	leadCtrl := controller.NewLeadController()
+	opportunityCtrl := controller.NewOpportunityController()
 	productCtrl := controller.NewProductController()
 	inventoryCtrl := controller.NewInventoryController()
+	warehouseCtrl := controller.NewWarehouseController()
 	orderCtrl := controller.NewOrderController()
+	supplierCtrl := controller.NewSupplierController()
+	purchaseOrderCtrl := controller.NewPurchaseOrderController()
+
+	// 角色权限：管理员拥有全部权限，销售经理和销售代表负责 CRM 与销售订单，仓库负责产品、库存与采购
+	adminOnly := middleware.RequireRoles(model.RoleAdmin)
+	managers := middleware.RequireRoles(model.RoleAdmin, model.RoleSalesManager)
+	sales := middleware.RequireRoles(model.RoleAdmin, model.RoleSalesManager, model.RoleSalesRep)
+	stock := middleware.RequireRoles(model.RoleAdmin, model.RoleWarehouse)
+	purchasing := middleware.RequireRoles(model.RoleAdmin, model.RoleSalesManager, model.RoleWarehouse)
 
 	// API v1
 	v1 := r.Group("/api/v1")
@@ -42,43 +56,133 @@ func SetupRouter() *gin.Engine {
 			// 用户信息
 			authorized.GET("/user/info", userCtrl.GetUserInfo)
 
+			// 用户与角色管理
+			users := authorized.Group("/users")
+			{
+				users.GET("", managers, userCtrl.List)
+				users.PUT("/:id/role", adminOnly, userCtrl.UpdateRole)
+			}
+
 			// CRM客户管理
-			customers := authorized.Group("/customers")
+			customers := authorized.Group("/customers", sales)
 			{
 				customers.POST("", customerCtrl.Create)
 				customers.GET("", customerCtrl.List)
 				customers.GET("/:id", customerCtrl.Get)
 				customers.PUT("/:id", customerCtrl.Update)
-				customers.DELETE("/:id", customerCtrl.Delete)
+				customers.DELETE("/:id", managers, customerCtrl.Delete)
+				customers.GET("/:id/timeline", activityCtrl.Timeline)
+			}
+
+			// CRM客户活动与跟进任务
+			activities := authorized.Group("/activities", sales)
+			{
+				activities.POST("", activityCtrl.Create)
+				activities.GET("", activityCtrl.List)
+				activities.GET("/:id", activityCtrl.Get)
+				activities.PUT("/:id", activityCtrl.Update)
+				activities.PUT("/:id/complete", activityCtrl.Complete)
+				activities.DELETE("/:id", activityCtrl.Delete)
+			}
+
+			// CRM线索管理
+			leads := authorized.Group("/leads", sales)
+			{
+				leads.POST("", leadCtrl.Create)
+				leads.GET("", leadCtrl.List)
+				leads.GET("/:id", leadCtrl.Get)
+				leads.PUT("/:id", leadCtrl.Update)
+				leads.DELETE("/:id", leadCtrl.Delete)
+				leads.POST("/:id/convert", leadCtrl.Convert)
+			}
+
+			// CRM销售阶段
+			stages := authorized.Group("/pipeline-stages", sales)
+			{
+				stages.POST("", managers, opportunityCtrl.CreateStage)
+				stages.GET("", opportunityCtrl.ListStages)
+				stages.PUT("/:id", managers, opportunityCtrl.UpdateStage)
+				stages.DELETE("/:id", managers, opportunityCtrl.DeleteStage)
+			}
+
+			// CRM商机管理
+			opportunities := authorized.Group("/opportunities", sales)
+			{
+				opportunities.POST("", opportunityCtrl.Create)
+				opportunities.GET("", opportunityCtrl.List)
+				opportunities.GET("/pipeline", opportunityCtrl.Pipeline)
+				opportunities.GET("/:id", opportunityCtrl.Get)
+				opportunities.PUT("/:id", opportunityCtrl.Update)
+				opportunities.PUT("/:id/stage", opportunityCtrl.MoveStage)
+				opportunities.DELETE("/:id", opportunityCtrl.Delete)
 			}
 
 			// ERP产品管理
 			products := authorized.Group("/products")
 			{
-				products.POST("", productCtrl.Create)
+				products.POST("", stock, productCtrl.Create)
 				products.GET("", productCtrl.List)
 				products.GET("/:id", productCtrl.Get)
-				products.PUT("/:id", productCtrl.Update)
-				products.DELETE("/:id", productCtrl.Delete)
+				products.PUT("/:id", stock, productCtrl.Update)
+				products.DELETE("/:id", adminOnly, productCtrl.Delete)
+			}
+
+			// ERP仓库管理
+			warehouses := authorized.Group("/warehouses")
+			{
+				warehouses.POST("", stock, warehouseCtrl.Create)
+				warehouses.GET("", warehouseCtrl.List)
+				warehouses.GET("/:id", warehouseCtrl.Get)
+				warehouses.PUT("/:id", stock, warehouseCtrl.Update)
+				warehouses.DELETE("/:id", adminOnly, warehouseCtrl.Delete)
 			}
 
 			// ERP库存管理
 			inventory := authorized.Group("/inventory")
 			{
-				inventory.POST("", inventoryCtrl.Create)
+				inventory.POST("", stock, inventoryCtrl.Create)
 				inventory.GET("", inventoryCtrl.List)
+				inventory.GET("/summary", inventoryCtrl.Summary)
+				inventory.POST("/transfer", stock, inventoryCtrl.Transfer)
+				inventory.GET("/movements", stock, inventoryCtrl.Movements)
+				inventory.GET("/reconcile", stock, inventoryCtrl.Reconcile)
+				inventory.GET("/alerts", inventoryCtrl.Alerts)
 				inventory.GET("/product/:product_id", inventoryCtrl.GetByProductID)
-				inventory.PUT("/product/:product_id", inventoryCtrl.Update)
+				inventory.PUT("/product/:product_id", stock, inventoryCtrl.Update)
 			}
 
 			// ERP订单管理
 			orders := authorized.Group("/orders")
 			{
-				orders.POST("", orderCtrl.Create)
+				orders.POST("", sales, orderCtrl.Create)
 				orders.GET("", orderCtrl.List)
 				orders.GET("/:id", orderCtrl.Get)
 				orders.PUT("/:id/status", orderCtrl.UpdateStatus)
-				orders.DELETE("/:id", orderCtrl.Delete)
+				orders.GET("/:id/history", orderCtrl.History)
+				orders.DELETE("/:id", managers, orderCtrl.Delete)
+			}
+
+			// ERP供应商管理
+			suppliers := authorized.Group("/suppliers", purchasing)
+			{
+				suppliers.POST("", stock, supplierCtrl.Create)
+				suppliers.GET("", supplierCtrl.List)
+				suppliers.GET("/:id", supplierCtrl.Get)
+				suppliers.PUT("/:id", stock, supplierCtrl.Update)
+				suppliers.DELETE("/:id", adminOnly, supplierCtrl.Delete)
+				suppliers.GET("/:id/prices", supplierCtrl.Prices)
+				suppliers.POST("/:id/prices", stock, supplierCtrl.AddPrice)
+			}
+
+			// ERP采购管理
+			purchaseOrders := authorized.Group("/purchase-orders", purchasing)
+			{
+				purchaseOrders.POST("", stock, purchaseOrderCtrl.Create)
+				purchaseOrders.GET("", purchaseOrderCtrl.List)
+				purchaseOrders.GET("/:id", purchaseOrderCtrl.Get)
+				purchaseOrders.POST("/:id/receive", stock, purchaseOrderCtrl.Receive)
+				purchaseOrders.PUT("/:id/cancel", stock, purchaseOrderCtrl.Cancel)
+				purchaseOrders.PUT("/:id/close", stock, purchaseOrderCtrl.Close)
 			}
 		}
 	}
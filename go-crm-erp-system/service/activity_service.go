@@ -0,0 +1,256 @@
+package service
+
+import (
+	"crm-erp-system/database"
+	"crm-erp-system/model"
+	"errors"
+	"sort"
+	"time"
+)
+
+type ActivityService struct {
+	orderService OrderService
+}
+
+const activityColumns = "id, customer_id, type, subject, COALESCE(content, ''), COALESCE(due_date, ''), COALESCE(assigned_to, 0), status, completed_at, user_id, created_at, updated_at"
+
+func scanActivity(scanner interface{ Scan(...interface{}) error }) (model.Activity, error) {
+	var a model.Activity
+	err := scanner.Scan(&a.ID, &a.CustomerID, &a.Type, &a.Subject, &a.Content, &a.DueDate, &a.AssignedTo, &a.Status, &a.CompletedAt, &a.UserID, &a.CreatedAt, &a.UpdatedAt)
+	return a, err
+}
+
+// activityOwnerFilter 活动的行级权限：创建人或被指派人可以访问
+func activityOwnerFilter(ownerID int64) (string, []interface{}) {
+	if ownerID == 0 {
+		return "", nil
+	}
+	return " AND (user_id = ? OR assigned_to = ?)", []interface{}{ownerID, ownerID}
+}
+
+// prepare 校验客户与负责人，并补全默认值：跟进任务必须有截止日期，默认指派给创建人且状态为待办
+func (s *ActivityService) prepare(activity *model.Activity, userID, ownerID int64) error {
+	if err := checkCustomer(database.DB, activity.CustomerID, ownerID); err != nil {
+		return err
+	}
+
+	if activity.Type == model.ActivityFollowUp {
+		if activity.DueDate == "" {
+			return errors.New("跟进任务必须设置截止日期")
+		}
+		if activity.AssignedTo == 0 {
+			activity.AssignedTo = userID
+		}
+		if activity.Status == "" {
+			activity.Status = model.ActivityOpen
+		}
+	}
+	if activity.Status == "" {
+		activity.Status = model.ActivityDone
+	}
+
+	if activity.AssignedTo > 0 {
+		var count int
+		err := database.DB.QueryRow("SELECT COUNT(*) FROM users WHERE id = ?", activity.AssignedTo).Scan(&count)
+		if err != nil || count == 0 {
+			return errors.New("负责人不存在")
+		}
+	}
+	return nil
+}
+
+func (s *ActivityService) Create(activity *model.Activity, userID, ownerID int64) (int64, error) {
+	if err := s.prepare(activity, userID, ownerID); err != nil {
+		return 0, err
+	}
+	activity.UserID = userID
+
+	var assignedTo interface{}
+	if activity.AssignedTo > 0 {
+		assignedTo = activity.AssignedTo
+	}
+	var completedAt interface{}
+	if activity.Status == model.ActivityDone {
+		completedAt = time.Now().UTC().Format("2006-01-02 15:04:05")
+	}
+
+	result, err := database.DB.Exec(
+		"INSERT INTO activities (customer_id, type, subject, content, due_date, assigned_to, status, completed_at, user_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		activity.CustomerID, activity.Type, activity.Subject, activity.Content, activity.DueDate, assignedTo, activity.Status, completedAt, activity.UserID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (s *ActivityService) GetByID(id, ownerID int64) (*model.Activity, error) {
+	filter, args := activityOwnerFilter(ownerID)
+	activity, err := scanActivity(database.DB.QueryRow("SELECT "+activityColumns+" FROM activities WHERE id = ?"+filter, append([]interface{}{id}, args...)...))
+	if err != nil {
+		return nil, errors.New("活动不存在")
+	}
+	return &activity, nil
+}
+
+// List 查询活动，支持按客户、类型、负责人、状态筛选；overdue 只返回已过截止日期的待办
+func (s *ActivityService) List(q *model.ActivityQuery, ownerID int64) ([]model.Activity, error) {
+	filter, args := activityOwnerFilter(ownerID)
+	query := "SELECT " + activityColumns + " FROM activities WHERE 1=1" + filter
+
+	if q.CustomerID > 0 {
+		query += " AND customer_id = ?"
+		args = append(args, q.CustomerID)
+	}
+	if q.Type != "" {
+		query += " AND type = ?"
+		args = append(args, q.Type)
+	}
+	if q.AssignedTo > 0 {
+		query += " AND assigned_to = ?"
+		args = append(args, q.AssignedTo)
+	}
+	if q.Status != "" {
+		query += " AND status = ?"
+		args = append(args, q.Status)
+	}
+	if q.Overdue {
+		query += " AND status = ? AND due_date <> '' AND due_date < ?"
+		args = append(args, model.ActivityOpen, time.Now().Format("2006-01-02"))
+	}
+
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+	if q.PageSize <= 0 {
+		q.PageSize = 20
+	}
+	// 待办按截止日期排列，其余按创建时间倒序
+	if q.Status == model.ActivityOpen || q.Overdue {
+		query += " ORDER BY due_date, id"
+	} else {
+		query += " ORDER BY created_at DESC, id DESC"
+	}
+	query += " LIMIT ? OFFSET ?"
+	args = append(args, q.PageSize, (q.Page-1)*q.PageSize)
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var activities []model.Activity
+	for rows.Next() {
+		activity, err := scanActivity(rows)
+		if err != nil {
+			continue
+		}
+		activities = append(activities, activity)
+	}
+	return activities, nil
+}
+
+func (s *ActivityService) Update(id int64, activity *model.Activity, userID, ownerID int64) error {
+	current, err := s.GetByID(id, ownerID)
+	if err != nil {
+		return err
+	}
+	if err := s.prepare(activity, userID, ownerID); err != nil {
+		return err
+	}
+
+	var assignedTo interface{}
+	if activity.AssignedTo > 0 {
+		assignedTo = activity.AssignedTo
+	}
+	// 状态变化时同步完成时间
+	completedAt := interface{}(current.CompletedAt)
+	if activity.Status == model.ActivityOpen {
+		completedAt = nil
+	} else if current.Status != model.ActivityDone {
+		completedAt = time.Now().UTC().Format("2006-01-02 15:04:05")
+	}
+
+	_, err = database.DB.Exec(
+		"UPDATE activities SET customer_id=?, type=?, subject=?, content=?, due_date=?, assigned_to=?, status=?, completed_at=?, updated_at=CURRENT_TIMESTAMP WHERE id=?",
+		activity.CustomerID, activity.Type, activity.Subject, activity.Content, activity.DueDate, assignedTo, activity.Status, completedAt, id,
+	)
+	return err
+}
+
+// Complete 完成跟进任务
+func (s *ActivityService) Complete(id, ownerID int64) error {
+	filter, args := activityOwnerFilter(ownerID)
+	result, err := database.DB.Exec(
+		"UPDATE activities SET status=?, completed_at=CURRENT_TIMESTAMP, updated_at=CURRENT_TIMESTAMP WHERE id=? AND status=?"+filter,
+		append([]interface{}{model.ActivityDone, id, model.ActivityOpen}, args...)...,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return errors.New("活动不存在或已完成")
+	}
+	return nil
+}
+
+func (s *ActivityService) Delete(id, ownerID int64) error {
+	filter, args := activityOwnerFilter(ownerID)
+	result, err := database.DB.Exec("DELETE FROM activities WHERE id = ?"+filter, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return err
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return errors.New("活动不存在")
+	}
+	return nil
+}
+
+// Timeline 客户时间线：合并客户的活动和订单，按发生时间倒序
+func (s *ActivityService) Timeline(customerID, ownerID int64) ([]model.TimelineEntry, error) {
+	if err := checkCustomer(database.DB, customerID, ownerID); err != nil {
+		return nil, err
+	}
+
+	rows, err := database.DB.Query("SELECT "+activityColumns+" FROM activities WHERE customer_id = ?", customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []model.TimelineEntry
+	for rows.Next() {
+		activity, err := scanActivity(rows)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, model.TimelineEntry{
+			Type:       "activity",
+			OccurredAt: activity.CreatedAt,
+			Activity:   &activity,
+		})
+	}
+	rows.Close()
+
+	orders, err := s.orderService.ListByCustomer(customerID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range orders {
+		entries = append(entries, model.TimelineEntry{
+			Type:       "order",
+			OccurredAt: orders[i].CreatedAt,
+			Order:      &orders[i],
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].OccurredAt.After(entries[j].OccurredAt)
+	})
+	return entries, nil
+}
@@ -3,11 +3,35 @@ package service
 import (
 	"crm-erp-system/database"
 	"crm-erp-system/model"
+	"database/sql"
 	"errors"
 )
 
 type CustomerService struct{}
 
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// ownerFilter 生成行级权限过滤条件，ownerID 为 0 时不限制
+func ownerFilter(column string, ownerID int64) (string, []interface{}) {
+	if ownerID == 0 {
+		return "", nil
+	}
+	return " AND " + column + " = ?", []interface{}{ownerID}
+}
+
+// checkCustomer 校验客户存在，ownerID 大于 0 时还要求客户归属该用户
+func checkCustomer(q queryRower, customerID, ownerID int64) error {
+	filter, args := ownerFilter("user_id", ownerID)
+	var count int
+	err := q.QueryRow("SELECT COUNT(*) FROM customers WHERE id = ?"+filter, append([]interface{}{customerID}, args...)...).Scan(&count)
+	if err != nil || count == 0 {
+		return errors.New("客户不存在")
+	}
+	return nil
+}
+
 func (s *CustomerService) Create(customer *model.Customer, userID int64) (int64, error) {
 	customer.UserID = userID
 	customer.Status = "active"
@@ -22,11 +46,12 @@ func (s *CustomerService) Create(customer *model.Customer, userID int64) (int64,
 	return result.LastInsertId()
 }
 
-func (s *CustomerService) GetByID(id int64) (*model.Customer, error) {
+func (s *CustomerService) GetByID(id, ownerID int64) (*model.Customer, error) {
+	filter, args := ownerFilter("user_id", ownerID)
 	var customer model.Customer
 	err := database.DB.QueryRow(
-		"SELECT id, name, company, email, phone, address, status, user_id, created_at, updated_at FROM customers WHERE id = ?",
-		id,
+		"SELECT id, name, company, email, phone, address, status, user_id, created_at, updated_at FROM customers WHERE id = ?"+filter,
+		append([]interface{}{id}, args...)...,
 	).Scan(&customer.ID, &customer.Name, &customer.Company, &customer.Email, &customer.Phone, &customer.Address, &customer.Status, &customer.UserID, &customer.CreatedAt, &customer.UpdatedAt)
 
 	if err != nil {
@@ -35,11 +60,12 @@ func (s *CustomerService) GetByID(id int64) (*model.Customer, error) {
 	return &customer, nil
 }
 
-func (s *CustomerService) List(page, pageSize int) ([]model.Customer, error) {
+func (s *CustomerService) List(page, pageSize int, ownerID int64) ([]model.Customer, error) {
 	offset := (page - 1) * pageSize
+	filter, args := ownerFilter("user_id", ownerID)
 	rows, err := database.DB.Query(
-		"SELECT id, name, company, email, phone, address, status, user_id, created_at, updated_at FROM customers ORDER BY created_at DESC LIMIT ? OFFSET ?",
-		pageSize, offset,
+		"SELECT id, name, company, email, phone, address, status, user_id, created_at, updated_at FROM customers WHERE 1=1"+filter+" ORDER BY created_at DESC LIMIT ? OFFSET ?",
+		append(args, pageSize, offset)...,
 	)
 	if err != nil {
 		return nil, err
@@ -57,10 +83,20 @@ func (s *CustomerService) List(page, pageSize int) ([]model.Customer, error) {
 	return customers, nil
 }
 
-func (s *CustomerService) Update(id int64, customer *model.Customer) error {
+// Update 更新客户，customer.UserID 大于 0 时同时变更负责人
+func (s *CustomerService) Update(id int64, customer *model.Customer, ownerID int64) error {
+	if customer.UserID > 0 {
+		var count int
+		err := database.DB.QueryRow("SELECT COUNT(*) FROM users WHERE id = ?", customer.UserID).Scan(&count)
+		if err != nil || count == 0 {
+			return errors.New("负责人不存在")
+		}
+	}
+
+	filter, args := ownerFilter("user_id", ownerID)
 	result, err := database.DB.Exec(
-		"UPDATE customers SET name=?, company=?, email=?, phone=?, address=?, status=?, updated_at=CURRENT_TIMESTAMP WHERE id=?",
-		customer.Name, customer.Company, customer.Email, customer.Phone, customer.Address, customer.Status, id,
+		"UPDATE customers SET name=?, company=?, email=?, phone=?, address=?, status=?, user_id=CASE WHEN ? > 0 THEN ? ELSE user_id END, updated_at=CURRENT_TIMESTAMP WHERE id=?"+filter,
+		append([]interface{}{customer.Name, customer.Company, customer.Email, customer.Phone, customer.Address, customer.Status, customer.UserID, customer.UserID, id}, args...)...,
 	)
 	if err != nil {
 		return err
@@ -73,8 +109,15 @@ func (s *CustomerService) Update(id int64, customer *model.Customer) error {
 	return nil
 }
 
-func (s *CustomerService) Delete(id int64) error {
-	result, err := database.DB.Exec("DELETE FROM customers WHERE id = ?", id)
+func (s *CustomerService) Delete(id, ownerID int64) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	filter, args := ownerFilter("user_id", ownerID)
+	result, err := tx.Exec("DELETE FROM customers WHERE id = ?"+filter, append([]interface{}{id}, args...)...)
 	if err != nil {
 		return err
 	}
@@ -83,5 +126,9 @@ func (s *CustomerService) Delete(id int64) error {
 	if affected == 0 {
 		return errors.New("客户不存在")
 	}
-	return nil
+	// 客户和跟进记录一起删除，避免只删掉一部分
+	if _, err := tx.Exec("DELETE FROM activities WHERE customer_id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
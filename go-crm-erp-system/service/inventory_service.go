@@ -3,62 +3,201 @@ package service
 import (
 	"crm-erp-system/database"
 	"crm-erp-system/model"
+	"database/sql"
 	"errors"
 )
 
 type InventoryService struct{}
 
-func (s *InventoryService) Create(inventory *model.Inventory) (int64, error) {
+const inventorySelect = `SELECT i.id, i.product_id, i.warehouse_id, w.name, i.quantity, i.reserved, i.updated_at
+	FROM inventory i JOIN warehouses w ON w.id = i.warehouse_id`
+
+func scanInventory(scanner interface{ Scan(...interface{}) error }) (model.Inventory, error) {
+	var inventory model.Inventory
+	err := scanner.Scan(&inventory.ID, &inventory.ProductID, &inventory.WarehouseID, &inventory.WarehouseName, &inventory.Quantity, &inventory.Reserved, &inventory.UpdatedAt)
+	inventory.Available = inventory.Quantity - inventory.Reserved
+	return inventory, err
+}
+
+func (s *InventoryService) Create(inventory *model.Inventory, userID int64) (int64, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
 	// 检查产品是否存在
 	var count int
-	err := database.DB.QueryRow("SELECT COUNT(*) FROM products WHERE id = ?", inventory.ProductID).Scan(&count)
+	err = tx.QueryRow("SELECT COUNT(*) FROM products WHERE id = ?", inventory.ProductID).Scan(&count)
 	if err != nil || count == 0 {
 		return 0, errors.New("产品不存在")
 	}
 
-	result, err := database.DB.Exec(
-		"INSERT INTO inventory (product_id, quantity, warehouse) VALUES (?, ?, ?)",
-		inventory.ProductID, inventory.Quantity, inventory.Warehouse,
+	// 未指定仓库时使用默认仓库
+	if inventory.WarehouseID == 0 {
+		err = tx.QueryRow("SELECT id FROM warehouses WHERE code = ?", database.DefaultWarehouseCode).Scan(&inventory.WarehouseID)
+		if err != nil {
+			return 0, err
+		}
+	}
+	err = tx.QueryRow("SELECT COUNT(*) FROM warehouses WHERE id = ?", inventory.WarehouseID).Scan(&count)
+	if err != nil || count == 0 {
+		return 0, errors.New("仓库不存在")
+	}
+
+	// 每个产品在每个仓库只允许一条库存记录
+	err = tx.QueryRow("SELECT COUNT(*) FROM inventory WHERE product_id = ? AND warehouse_id = ?", inventory.ProductID, inventory.WarehouseID).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		return 0, errors.New("该产品在此仓库的库存记录已存在")
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO inventory (product_id, warehouse_id, quantity) VALUES (?, ?, ?)",
+		inventory.ProductID, inventory.WarehouseID, inventory.Quantity,
 	)
 	if err != nil {
 		return 0, err
 	}
-	return result.LastInsertId()
-}
 
-func (s *InventoryService) GetByProductID(productID int64) (*model.Inventory, error) {
-	var inventory model.Inventory
-	err := database.DB.QueryRow(
-		"SELECT id, product_id, quantity, warehouse, updated_at FROM inventory WHERE product_id = ?",
-		productID,
-	).Scan(&inventory.ID, &inventory.ProductID, &inventory.Quantity, &inventory.Warehouse, &inventory.UpdatedAt)
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	// 初始数量记为入库
+	if err := recordMovement(tx, &model.StockMovement{
+		ProductID:   inventory.ProductID,
+		WarehouseID: inventory.WarehouseID,
+		Type:        model.MovementReceipt,
+		Quantity:    inventory.Quantity,
+		Note:        "初始入库",
+		UserID:      userID,
+	}); err != nil {
+		return 0, err
+	}
 
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetByProductID 查询产品在各仓库的库存及汇总
+func (s *InventoryService) GetByProductID(productID int64) (*model.ProductStock, error) {
+	rows, err := database.DB.Query(inventorySelect+" WHERE i.product_id = ? ORDER BY i.warehouse_id", productID)
 	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stock := model.ProductStock{ProductID: productID}
+	for rows.Next() {
+		inventory, err := scanInventory(rows)
+		if err != nil {
+			continue
+		}
+		stock.Quantity += inventory.Quantity
+		stock.Reserved += inventory.Reserved
+		stock.Warehouses = append(stock.Warehouses, inventory)
+	}
+	if len(stock.Warehouses) == 0 {
 		return nil, errors.New("库存记录不存在")
 	}
-	return &inventory, nil
+	stock.Available = stock.Quantity - stock.Reserved
+	return &stock, nil
 }
 
-func (s *InventoryService) Update(productID int64, quantity int) error {
-	result, err := database.DB.Exec(
-		"UPDATE inventory SET quantity=?, updated_at=CURRENT_TIMESTAMP WHERE product_id=?",
-		quantity, productID,
+// Summary 按产品汇总所有仓库的库存
+func (s *InventoryService) Summary() ([]model.ProductStock, error) {
+	rows, err := database.DB.Query(
+		"SELECT product_id, SUM(quantity), SUM(reserved) FROM inventory GROUP BY product_id ORDER BY product_id",
 	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stocks []model.ProductStock
+	for rows.Next() {
+		var stock model.ProductStock
+		if err := rows.Scan(&stock.ProductID, &stock.Quantity, &stock.Reserved); err != nil {
+			continue
+		}
+		stock.Available = stock.Quantity - stock.Reserved
+		stocks = append(stocks, stock)
+	}
+	return stocks, nil
+}
+
+// Available 查询产品在所有仓库的可用库存（总库存减去已被订单预留的数量）
+func (s *InventoryService) Available(productID int64) (int, error) {
+	stock, err := s.GetByProductID(productID)
+	if err != nil {
+		return 0, err
+	}
+	return stock.Available, nil
+}
+
+// Update 盘点更新产品在指定仓库的库存，差额记为调整流水；产品只有一个仓库时可省略仓库
+func (s *InventoryService) Update(productID, warehouseID int64, quantity int, userID int64, note string) error {
+	tx, err := database.DB.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	affected, _ := result.RowsAffected()
-	if affected == 0 {
+	if warehouseID == 0 {
+		var count int
+		err := tx.QueryRow("SELECT COUNT(*), COALESCE(MAX(warehouse_id), 0) FROM inventory WHERE product_id = ?", productID).Scan(&count, &warehouseID)
+		if err != nil || count == 0 {
+			return errors.New("库存记录不存在")
+		}
+		if count > 1 {
+			return errors.New("该产品存在多个仓库的库存，请指定仓库")
+		}
+	}
+
+	var current, reserved int
+	err = tx.QueryRow("SELECT quantity, reserved FROM inventory WHERE product_id = ? AND warehouse_id = ?", productID, warehouseID).Scan(&current, &reserved)
+	if err != nil {
 		return errors.New("库存记录不存在")
 	}
-	return nil
+	if quantity < reserved {
+		return errors.New("库存数量不能小于已预留数量")
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE inventory SET quantity=?, updated_at=CURRENT_TIMESTAMP WHERE product_id=? AND warehouse_id=?",
+		quantity, productID, warehouseID,
+	); err != nil {
+		return err
+	}
+
+	if err := recordMovement(tx, &model.StockMovement{
+		ProductID:   productID,
+		WarehouseID: warehouseID,
+		Type:        model.MovementAdjustment,
+		Quantity:    quantity - current,
+		Note:        note,
+		UserID:      userID,
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (s *InventoryService) List() ([]model.Inventory, error) {
-	rows, err := database.DB.Query(
-		"SELECT id, product_id, quantity, warehouse, updated_at FROM inventory ORDER BY updated_at DESC",
-	)
+func (s *InventoryService) List(warehouseID int64) ([]model.Inventory, error) {
+	query := inventorySelect
+	var args []interface{}
+	if warehouseID > 0 {
+		query += " WHERE i.warehouse_id = ?"
+		args = append(args, warehouseID)
+	}
+
+	rows, err := database.DB.Query(query+" ORDER BY i.updated_at DESC", args...)
 	if err != nil {
 		return nil, err
 	}
@@ -66,11 +205,248 @@ func (s *InventoryService) List() ([]model.Inventory, error) {
 
 	var inventories []model.Inventory
 	for rows.Next() {
-		var inventory model.Inventory
-		if err := rows.Scan(&inventory.ID, &inventory.ProductID, &inventory.Quantity, &inventory.Warehouse, &inventory.UpdatedAt); err != nil {
+		inventory, err := scanInventory(rows)
+		if err != nil {
 			continue
 		}
 		inventories = append(inventories, inventory)
 	}
 	return inventories, nil
 }
+
+// Transfer 在仓库之间调拨库存，调出与调入在同一事务内完成
+func (s *InventoryService) Transfer(req *model.TransferRequest, userID int64) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var count int
+	err = tx.QueryRow("SELECT COUNT(*) FROM warehouses WHERE id = ?", req.ToWarehouseID).Scan(&count)
+	if err != nil || count == 0 {
+		return errors.New("调入仓库不存在")
+	}
+
+	// 只能调拨未被预留的库存
+	result, err := tx.Exec(
+		"UPDATE inventory SET quantity = quantity - ?, updated_at=CURRENT_TIMESTAMP WHERE product_id = ? AND warehouse_id = ? AND quantity - reserved >= ?",
+		req.Quantity, req.ProductID, req.FromWarehouseID, req.Quantity,
+	)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errors.New("调出仓库可用库存不足")
+	}
+
+	if _, err := tx.Exec(
+		`INSERT INTO inventory (product_id, warehouse_id, quantity) VALUES (?, ?, ?)
+			ON CONFLICT(product_id, warehouse_id) DO UPDATE SET quantity = quantity + excluded.quantity, updated_at=CURRENT_TIMESTAMP`,
+		req.ProductID, req.ToWarehouseID, req.Quantity,
+	); err != nil {
+		return err
+	}
+
+	movements := []model.StockMovement{
+		{ProductID: req.ProductID, WarehouseID: req.FromWarehouseID, Quantity: -req.Quantity, RefID: req.ToWarehouseID},
+		{ProductID: req.ProductID, WarehouseID: req.ToWarehouseID, Quantity: req.Quantity, RefID: req.FromWarehouseID},
+	}
+	for i := range movements {
+		m := &movements[i]
+		m.Type = model.MovementTransfer
+		m.RefType = "warehouse"
+		m.Note = req.Note
+		m.UserID = userID
+		if err := recordMovement(tx, m); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Receive 在事务中将入库数量计入指定仓库（无库存记录时自动创建），并记入库流水
+func (s *InventoryService) Receive(tx *sql.Tx, m *model.StockMovement) error {
+	if _, err := tx.Exec(
+		`INSERT INTO inventory (product_id, warehouse_id, quantity) VALUES (?, ?, ?)
+			ON CONFLICT(product_id, warehouse_id) DO UPDATE SET quantity = quantity + excluded.quantity, updated_at=CURRENT_TIMESTAMP`,
+		m.ProductID, m.WarehouseID, m.Quantity,
+	); err != nil {
+		return err
+	}
+
+	m.Type = model.MovementReceipt
+	return recordMovement(tx, m)
+}
+
+// 订单状态对应的库存占用方式
+const (
+	stockNone     = iota // 不占用库存（已取消）
+	stockReserved        // 已预留，尚未出库
+	stockConsumed        // 已出库扣减
+)
+
+func stockStateOf(status string) int {
+	switch status {
+	case "pending", "confirmed":
+		return stockReserved
+	case "shipped", "completed":
+		return stockConsumed
+	default:
+		return stockNone
+	}
+}
+
+// Reserve 在事务中为订单预留库存，返回实际预留的仓库ID。
+// 未指定仓库时选择可用库存最多且足够的仓库，可用库存不足时返回错误
+func (s *InventoryService) Reserve(tx *sql.Tx, productID, warehouseID int64, quantity int) (int64, error) {
+	if warehouseID == 0 {
+		err := tx.QueryRow(
+			"SELECT warehouse_id FROM inventory WHERE product_id = ? AND quantity - reserved >= ? ORDER BY quantity - reserved DESC, warehouse_id LIMIT 1",
+			productID, quantity,
+		).Scan(&warehouseID)
+		if err == sql.ErrNoRows {
+			return 0, s.shortageError(tx, productID)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	result, err := tx.Exec(
+		"UPDATE inventory SET reserved = reserved + ?, updated_at=CURRENT_TIMESTAMP WHERE product_id = ? AND warehouse_id = ? AND quantity - reserved >= ?",
+		quantity, productID, warehouseID, quantity,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return 0, s.shortageError(tx, productID)
+	}
+	return warehouseID, nil
+}
+
+func (s *InventoryService) shortageError(tx *sql.Tx, productID int64) error {
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM inventory WHERE product_id = ?", productID).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("库存记录不存在")
+	}
+	return errors.New("库存不足")
+}
+
+// Release 在事务中释放订单预留的库存
+func (s *InventoryService) Release(tx *sql.Tx, productID, warehouseID int64, quantity int) error {
+	return s.execStock(tx,
+		"UPDATE inventory SET reserved = reserved - ?, updated_at=CURRENT_TIMESTAMP WHERE product_id = ? AND warehouse_id = ? AND reserved >= ?",
+		quantity, productID, warehouseID, quantity,
+	)
+}
+
+// Deduct 在事务中将订单明细已预留的库存正式出库扣减，并记销售流水
+func (s *InventoryService) Deduct(tx *sql.Tx, item model.OrderItem, userID int64) error {
+	if err := s.execStock(tx,
+		"UPDATE inventory SET quantity = quantity - ?, reserved = reserved - ?, updated_at=CURRENT_TIMESTAMP WHERE product_id = ? AND warehouse_id = ? AND reserved >= ? AND quantity >= ?",
+		item.Quantity, item.Quantity, item.ProductID, item.WarehouseID, item.Quantity, item.Quantity,
+	); err != nil {
+		return err
+	}
+
+	return recordMovement(tx, &model.StockMovement{
+		ProductID:   item.ProductID,
+		WarehouseID: item.WarehouseID,
+		Type:        model.MovementSale,
+		Quantity:    -item.Quantity,
+		RefType:     "order",
+		RefID:       item.OrderID,
+		UserID:      userID,
+	})
+}
+
+// Restock 在事务中将订单明细已出库的数量退回库存，并记退货流水
+func (s *InventoryService) Restock(tx *sql.Tx, item model.OrderItem, userID int64) error {
+	if err := s.execStock(tx,
+		"UPDATE inventory SET quantity = quantity + ?, updated_at=CURRENT_TIMESTAMP WHERE product_id = ? AND warehouse_id = ?",
+		item.Quantity, item.ProductID, item.WarehouseID,
+	); err != nil {
+		return err
+	}
+
+	return recordMovement(tx, &model.StockMovement{
+		ProductID:   item.ProductID,
+		WarehouseID: item.WarehouseID,
+		Type:        model.MovementReturn,
+		Quantity:    item.Quantity,
+		RefType:     "order",
+		RefID:       item.OrderID,
+		UserID:      userID,
+	})
+}
+
+// ApplyOrderStatus 根据订单状态变化调整订单明细所在仓库的库存：预留→出库为扣减，预留→取消为释放，出库→取消为退回。
+// 迁移前未发货的历史明细没有预留库存，首次变更时只按新状态占用，之后正常跟踪
+func (s *InventoryService) ApplyOrderStatus(tx *sql.Tx, item model.OrderItem, from, to string, userID int64) error {
+	fromState, toState := stockStateOf(from), stockStateOf(to)
+	if !item.StockTracked {
+		fromState = stockNone
+	}
+	if fromState == toState {
+		return s.trackOrderItem(tx, item)
+	}
+
+	// 先撤销原状态的占用
+	switch fromState {
+	case stockReserved:
+		if err := s.Release(tx, item.ProductID, item.WarehouseID, item.Quantity); err != nil {
+			return err
+		}
+	case stockConsumed:
+		if err := s.Restock(tx, item, userID); err != nil {
+			return err
+		}
+	}
+
+	// 再按新状态重新占用
+	if toState != stockNone {
+		warehouseID, err := s.Reserve(tx, item.ProductID, item.WarehouseID, item.Quantity)
+		if err != nil {
+			return err
+		}
+		item.WarehouseID = warehouseID
+	}
+	if toState == stockConsumed {
+		if err := s.Deduct(tx, item, userID); err != nil {
+			return err
+		}
+	}
+	return s.trackOrderItem(tx, item)
+}
+
+// trackOrderItem 历史明细按新状态占用库存后开始跟踪，并记录实际占用的仓库
+func (s *InventoryService) trackOrderItem(tx *sql.Tx, item model.OrderItem) error {
+	if item.StockTracked {
+		return nil
+	}
+	_, err := tx.Exec(
+		"UPDATE order_items SET stock_tracked = 1, warehouse_id = COALESCE(NULLIF(?, 0), warehouse_id) WHERE id = ?",
+		item.WarehouseID, item.ID,
+	)
+	return err
+}
+
+func (s *InventoryService) execStock(tx *sql.Tx, query string, args ...interface{}) error {
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return errors.New("库存记录异常，无法调整库存")
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"crm-erp-system/database"
+	"crm-erp-system/model"
+	"errors"
+)
+
+type LeadService struct{}
+
+const leadColumns = "id, name, COALESCE(company, ''), COALESCE(email, ''), COALESCE(phone, ''), COALESCE(source, ''), status, COALESCE(notes, ''), COALESCE(customer_id, 0), user_id, created_at, updated_at"
+
+func (s *LeadService) Create(lead *model.Lead, userID int64) (int64, error) {
+	lead.UserID = userID
+	if lead.Status == "" {
+		lead.Status = model.LeadNew
+	}
+
+	result, err := database.DB.Exec(
+		"INSERT INTO leads (name, company, email, phone, source, status, notes, user_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		lead.Name, lead.Company, lead.Email, lead.Phone, lead.Source, lead.Status, lead.Notes, lead.UserID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (s *LeadService) GetByID(id, ownerID int64) (*model.Lead, error) {
+	filter, args := ownerFilter("user_id", ownerID)
+	var lead model.Lead
+	err := database.DB.QueryRow(
+		"SELECT "+leadColumns+" FROM leads WHERE id = ?"+filter,
+		append([]interface{}{id}, args...)...,
+	).Scan(&lead.ID, &lead.Name, &lead.Company, &lead.Email, &lead.Phone, &lead.Source, &lead.Status, &lead.Notes, &lead.CustomerID, &lead.UserID, &lead.CreatedAt, &lead.UpdatedAt)
+
+	if err != nil {
+		return nil, errors.New("线索不存在")
+	}
+	return &lead, nil
+}
+
+func (s *LeadService) List(status string, page, pageSize int, ownerID int64) ([]model.Lead, error) {
+	filter, args := ownerFilter("user_id", ownerID)
+	query := "SELECT " + leadColumns + " FROM leads WHERE 1=1" + filter
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY created_at DESC LIMIT ? OFFSET ?"
+	args = append(args, pageSize, (page-1)*pageSize)
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var leads []model.Lead
+	for rows.Next() {
+		var lead model.Lead
+		if err := rows.Scan(&lead.ID, &lead.Name, &lead.Company, &lead.Email, &lead.Phone, &lead.Source, &lead.Status, &lead.Notes, &lead.CustomerID, &lead.UserID, &lead.CreatedAt, &lead.UpdatedAt); err != nil {
+			continue
+		}
+		leads = append(leads, lead)
+	}
+	return leads, nil
+}
+
+func (s *LeadService) Update(id int64, lead *model.Lead, ownerID int64) error {
+	if lead.Status == "" {
+		lead.Status = model.LeadNew
+	}
+
+	// 已转化的线索不再修改
+	filter, args := ownerFilter("user_id", ownerID)
+	result, err := database.DB.Exec(
+		"UPDATE leads SET name=?, company=?, email=?, phone=?, source=?, status=?, notes=?, updated_at=CURRENT_TIMESTAMP WHERE id=? AND status <> ?"+filter,
+		append([]interface{}{lead.Name, lead.Company, lead.Email, lead.Phone, lead.Source, lead.Status, lead.Notes, id, model.LeadConverted}, args...)...,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return errors.New("线索不存在或已转化")
+	}
+	return nil
+}
+
+func (s *LeadService) Delete(id, ownerID int64) error {
+	filter, args := ownerFilter("user_id", ownerID)
+	result, err := database.DB.Exec("DELETE FROM leads WHERE id = ? AND status <> ?"+filter, append([]interface{}{id, model.LeadConverted}, args...)...)
+	if err != nil {
+		return err
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return errors.New("线索不存在或已转化")
+	}
+	return nil
+}
+
+// Convert 将线索转化为客户，可同时创建商机，返回客户ID和商机ID
+func (s *LeadService) Convert(id int64, req *model.ConvertLeadRequest, userID, ownerID int64) (int64, int64, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	filter, args := ownerFilter("user_id", ownerID)
+	var lead model.Lead
+	err = tx.QueryRow(
+		"SELECT name, COALESCE(company, ''), COALESCE(email, ''), COALESCE(phone, ''), status, user_id FROM leads WHERE id = ?"+filter,
+		append([]interface{}{id}, args...)...,
+	).Scan(&lead.Name, &lead.Company, &lead.Email, &lead.Phone, &lead.Status, &lead.UserID)
+	if err != nil {
+		return 0, 0, errors.New("线索不存在")
+	}
+	if lead.Status == model.LeadConverted || lead.Status == model.LeadLost {
+		return 0, 0, errors.New("线索已转化或已丢失")
+	}
+
+	// 客户归属线索负责人
+	result, err := tx.Exec(
+		"INSERT INTO customers (name, company, email, phone, address, status, user_id) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		lead.Name, lead.Company, lead.Email, lead.Phone, "", "active", lead.UserID,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+	customerID, err := result.LastInsertId()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE leads SET status=?, customer_id=?, updated_at=CURRENT_TIMESTAMP WHERE id=?",
+		model.LeadConverted, customerID, id,
+	); err != nil {
+		return 0, 0, err
+	}
+
+	var opportunityID int64
+	if req.Opportunity != nil {
+		req.Opportunity.CustomerID = customerID
+		req.Opportunity.LeadID = id
+		if req.Opportunity.UserID == 0 {
+			req.Opportunity.UserID = lead.UserID
+		}
+		if opportunityID, err = createOpportunity(tx, req.Opportunity, userID, 0); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return customerID, opportunityID, nil
+}
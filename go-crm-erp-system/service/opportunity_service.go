@@ -0,0 +1,334 @@
+package service
+
+import (
+	"crm-erp-system/database"
+	"crm-erp-system/model"
+	"database/sql"
+	"errors"
+)
+
+type OpportunityService struct{}
+
+const opportunitySelect = `SELECT o.id, o.name, o.customer_id, COALESCE(o.lead_id, 0), o.stage_id, s.name, s.probability,
+	o.amount, COALESCE(o.expected_close_date, ''), COALESCE(o.notes, ''), o.user_id, o.created_at, o.updated_at
+	FROM opportunities o JOIN pipeline_stages s ON s.id = o.stage_id`
+
+func scanOpportunity(scanner interface{ Scan(...interface{}) error }) (model.Opportunity, error) {
+	var o model.Opportunity
+	err := scanner.Scan(&o.ID, &o.Name, &o.CustomerID, &o.LeadID, &o.StageID, &o.StageName, &o.Probability,
+		&o.Amount, &o.ExpectedCloseDate, &o.Notes, &o.UserID, &o.CreatedAt, &o.UpdatedAt)
+	return o, err
+}
+
+// ListStages 获取销售阶段，按排序号排列
+func (s *OpportunityService) ListStages() ([]model.PipelineStage, error) {
+	rows, err := database.DB.Query(
+		"SELECT id, name, probability, sort_order, is_won, is_lost, created_at FROM pipeline_stages ORDER BY sort_order, id",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stages []model.PipelineStage
+	for rows.Next() {
+		var stage model.PipelineStage
+		if err := rows.Scan(&stage.ID, &stage.Name, &stage.Probability, &stage.SortOrder, &stage.IsWon, &stage.IsLost, &stage.CreatedAt); err != nil {
+			continue
+		}
+		stages = append(stages, stage)
+	}
+	return stages, nil
+}
+
+func (s *OpportunityService) CreateStage(stage *model.PipelineStage) (int64, error) {
+	if stage.IsWon && stage.IsLost {
+		return 0, errors.New("阶段不能同时为赢单和输单")
+	}
+
+	result, err := database.DB.Exec(
+		"INSERT INTO pipeline_stages (name, probability, sort_order, is_won, is_lost) VALUES (?, ?, ?, ?, ?)",
+		stage.Name, stage.Probability, stage.SortOrder, stage.IsWon, stage.IsLost,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (s *OpportunityService) UpdateStage(id int64, stage *model.PipelineStage) error {
+	if stage.IsWon && stage.IsLost {
+		return errors.New("阶段不能同时为赢单和输单")
+	}
+
+	result, err := database.DB.Exec(
+		"UPDATE pipeline_stages SET name=?, probability=?, sort_order=?, is_won=?, is_lost=? WHERE id=?",
+		stage.Name, stage.Probability, stage.SortOrder, stage.IsWon, stage.IsLost, id,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return errors.New("销售阶段不存在")
+	}
+	return nil
+}
+
+func (s *OpportunityService) DeleteStage(id int64) error {
+	// 仍有商机处于该阶段时不允许删除
+	var count int
+	err := database.DB.QueryRow("SELECT COUNT(*) FROM opportunities WHERE stage_id = ?", id).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("该阶段下仍有商机，无法删除")
+	}
+
+	result, err := database.DB.Exec("DELETE FROM pipeline_stages WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return errors.New("销售阶段不存在")
+	}
+	return nil
+}
+
+func (s *OpportunityService) Create(opportunity *model.Opportunity, userID, ownerID int64) (int64, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, err := createOpportunity(tx, opportunity, userID, ownerID)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// createOpportunity 在事务中创建商机，未指定阶段时放入第一个阶段，未指定负责人时为创建人
+func createOpportunity(tx *sql.Tx, opportunity *model.Opportunity, userID, ownerID int64) (int64, error) {
+	if err := checkCustomer(tx, opportunity.CustomerID, ownerID); err != nil {
+		return 0, err
+	}
+
+	var err error
+	if opportunity.StageID == 0 {
+		err = tx.QueryRow("SELECT id FROM pipeline_stages ORDER BY sort_order, id LIMIT 1").Scan(&opportunity.StageID)
+	} else {
+		err = tx.QueryRow("SELECT id FROM pipeline_stages WHERE id = ?", opportunity.StageID).Scan(&opportunity.StageID)
+	}
+	if err != nil {
+		return 0, errors.New("销售阶段不存在")
+	}
+
+	if opportunity.UserID == 0 {
+		opportunity.UserID = userID
+	}
+
+	var leadID interface{}
+	if opportunity.LeadID > 0 {
+		leadID = opportunity.LeadID
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO opportunities (name, customer_id, lead_id, stage_id, amount, expected_close_date, notes, user_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		opportunity.Name, opportunity.CustomerID, leadID, opportunity.StageID, opportunity.Amount, opportunity.ExpectedCloseDate, opportunity.Notes, opportunity.UserID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (s *OpportunityService) GetByID(id, ownerID int64) (*model.Opportunity, error) {
+	filter, args := ownerFilter("o.user_id", ownerID)
+	opportunity, err := scanOpportunity(database.DB.QueryRow(opportunitySelect+" WHERE o.id = ?"+filter, append([]interface{}{id}, args...)...))
+	if err != nil {
+		return nil, errors.New("商机不存在")
+	}
+	return &opportunity, nil
+}
+
+// List 获取商机列表，可按阶段、负责人和客户筛选
+func (s *OpportunityService) List(stageID, userID, customerID int64, page, pageSize int) ([]model.Opportunity, error) {
+	query := opportunitySelect + " WHERE 1=1"
+	var args []interface{}
+	if stageID > 0 {
+		query += " AND o.stage_id = ?"
+		args = append(args, stageID)
+	}
+	if userID > 0 {
+		query += " AND o.user_id = ?"
+		args = append(args, userID)
+	}
+	if customerID > 0 {
+		query += " AND o.customer_id = ?"
+		args = append(args, customerID)
+	}
+	query += " ORDER BY o.created_at DESC LIMIT ? OFFSET ?"
+	args = append(args, pageSize, (page-1)*pageSize)
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var opportunities []model.Opportunity
+	for rows.Next() {
+		opportunity, err := scanOpportunity(rows)
+		if err != nil {
+			continue
+		}
+		opportunities = append(opportunities, opportunity)
+	}
+	return opportunities, nil
+}
+
+func (s *OpportunityService) Update(id int64, opportunity *model.Opportunity, ownerID int64) error {
+	current, err := s.GetByID(id, ownerID)
+	if err != nil {
+		return err
+	}
+	if opportunity.StageID == 0 {
+		opportunity.StageID = current.StageID
+	}
+	if opportunity.UserID == 0 {
+		opportunity.UserID = current.UserID
+	}
+	if opportunity.CustomerID == 0 {
+		opportunity.CustomerID = current.CustomerID
+	}
+	if err := checkCustomer(database.DB, opportunity.CustomerID, ownerID); err != nil {
+		return err
+	}
+
+	var count int
+	err = database.DB.QueryRow("SELECT COUNT(*) FROM pipeline_stages WHERE id = ?", opportunity.StageID).Scan(&count)
+	if err != nil || count == 0 {
+		return errors.New("销售阶段不存在")
+	}
+
+	_, err = database.DB.Exec(
+		"UPDATE opportunities SET name=?, customer_id=?, stage_id=?, amount=?, expected_close_date=?, notes=?, user_id=?, updated_at=CURRENT_TIMESTAMP WHERE id=?",
+		opportunity.Name, opportunity.CustomerID, opportunity.StageID, opportunity.Amount, opportunity.ExpectedCloseDate, opportunity.Notes, opportunity.UserID, id,
+	)
+	return err
+}
+
+// MoveStage 推进商机到指定阶段
+func (s *OpportunityService) MoveStage(id, stageID, ownerID int64) error {
+	var count int
+	err := database.DB.QueryRow("SELECT COUNT(*) FROM pipeline_stages WHERE id = ?", stageID).Scan(&count)
+	if err != nil || count == 0 {
+		return errors.New("销售阶段不存在")
+	}
+
+	filter, args := ownerFilter("user_id", ownerID)
+	result, err := database.DB.Exec(
+		"UPDATE opportunities SET stage_id=?, updated_at=CURRENT_TIMESTAMP WHERE id=?"+filter,
+		append([]interface{}{stageID, id}, args...)...,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return errors.New("商机不存在")
+	}
+	return nil
+}
+
+func (s *OpportunityService) Delete(id, ownerID int64) error {
+	filter, args := ownerFilter("user_id", ownerID)
+	result, err := database.DB.Exec("DELETE FROM opportunities WHERE id = ?"+filter, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return err
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return errors.New("商机不存在")
+	}
+	return nil
+}
+
+// Summary 按阶段和负责人汇总商机金额及加权预测，可按预计成交日期范围筛选。
+// 阶段汇总包含全部阶段，负责人汇总只统计未结束（非赢单/输单）的商机
+func (s *OpportunityService) Summary(startDate, endDate string, ownerID int64) (*model.PipelineSummary, error) {
+	filter, args := ownerFilter("o.user_id", ownerID)
+	if startDate != "" {
+		filter += " AND o.expected_close_date >= ?"
+		args = append(args, startDate)
+	}
+	if endDate != "" {
+		filter += " AND o.expected_close_date <= ?"
+		args = append(args, endDate)
+	}
+
+	summary := &model.PipelineSummary{}
+
+	rows, err := database.DB.Query(`
+		SELECT s.id, s.name, s.probability, COUNT(o.id), COALESCE(SUM(o.amount), 0)
+		FROM pipeline_stages s LEFT JOIN opportunities o ON o.stage_id = s.id`+filter+`
+		GROUP BY s.id ORDER BY s.sort_order, s.id`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var f model.StageForecast
+		if err := rows.Scan(&f.StageID, &f.StageName, &f.Probability, &f.Count, &f.Amount); err != nil {
+			return nil, err
+		}
+		f.Amount = roundMoney(f.Amount)
+		f.WeightedAmount = roundMoney(f.Amount * float64(f.Probability) / 100)
+		summary.ByStage = append(summary.ByStage, f)
+		summary.TotalAmount += f.Amount
+		summary.TotalWeighted += f.WeightedAmount
+	}
+	rows.Close()
+
+	userRows, err := database.DB.Query(`
+		SELECT o.user_id, COALESCE(u.username, ''), COUNT(o.id), SUM(o.amount), SUM(o.amount * s.probability / 100.0)
+		FROM opportunities o
+		JOIN pipeline_stages s ON s.id = o.stage_id
+		LEFT JOIN users u ON u.id = o.user_id
+		WHERE s.is_won = 0 AND s.is_lost = 0`+filter+`
+		GROUP BY o.user_id ORDER BY 5 DESC`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer userRows.Close()
+
+	for userRows.Next() {
+		var f model.UserForecast
+		if err := userRows.Scan(&f.UserID, &f.Username, &f.Count, &f.Amount, &f.WeightedAmount); err != nil {
+			return nil, err
+		}
+		f.Amount = roundMoney(f.Amount)
+		f.WeightedAmount = roundMoney(f.WeightedAmount)
+		summary.ByUser = append(summary.ByUser, f)
+	}
+
+	summary.TotalAmount = roundMoney(summary.TotalAmount)
+	summary.TotalWeighted = roundMoney(summary.TotalWeighted)
+	return summary, userRows.Err()
+}
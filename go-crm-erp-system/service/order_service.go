@@ -1,117 +1,385 @@
 package service
 
 import (
+	"crm-erp-system/config"
 	"crm-erp-system/database"
 	"crm-erp-system/model"
+	"database/sql"
 	"errors"
 	"fmt"
+	"math"
+	"strings"
 	"time"
 )
 
-type OrderService struct{}
+type OrderService struct {
+	inventoryService InventoryService
+}
+
+// orderTransitions 订单状态流转表：pending→confirmed→shipped→completed，发货前可取消
+var orderTransitions = map[string][]string{
+	"pending":   {"confirmed", "cancelled"},
+	"confirmed": {"shipped", "cancelled"},
+	"shipped":   {"completed"},
+	"completed": {},
+	"cancelled": {},
+}
+
+// CanTransition 判断订单能否从 from 状态流转到 to 状态
+func CanTransition(from, to string) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+const orderColumns = "id, order_no, customer_id, subtotal, discount_amount, tax_rate, tax_amount, total_amount, status, user_id, created_at, updated_at"
+
+func (s *OrderService) Create(req *model.CreateOrderRequest, userID, ownerID int64) (int64, error) {
+	order := model.Order{
+		// 生成订单号
+		OrderNo:    fmt.Sprintf("ORD%d", time.Now().UnixNano()),
+		CustomerID: req.CustomerID,
+		TaxRate:    config.AppConfig.TaxRate,
+		Status:     "pending",
+		UserID:     userID,
+		Items:      req.Items,
+	}
+	if req.TaxRate != nil {
+		order.TaxRate = *req.TaxRate
+	}
 
-func (s *OrderService) Create(order *model.Order, userID int64) (int64, error) {
-	// 生成订单号
-	order.OrderNo = fmt.Sprintf("ORD%d", time.Now().UnixNano())
-	order.UserID = userID
-	order.Status = "pending"
-	order.TotalAmount = order.UnitPrice * float64(order.Quantity)
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
 
 	// 检查客户是否存在
-	var count int
-	err := database.DB.QueryRow("SELECT COUNT(*) FROM customers WHERE id = ?", order.CustomerID).Scan(&count)
-	if err != nil || count == 0 {
-		return 0, errors.New("客户不存在")
+	if err := checkCustomer(tx, order.CustomerID, ownerID); err != nil {
+		return 0, err
 	}
 
-	// 检查产品是否存在
-	err = database.DB.QueryRow("SELECT COUNT(*) FROM products WHERE id = ?", order.ProductID).Scan(&count)
-	if err != nil || count == 0 {
-		return 0, errors.New("产品不存在")
+	for i := range order.Items {
+		item := &order.Items[i]
+
+		// 检查产品是否存在，未指定单价时使用产品标价
+		var price float64
+		if err := tx.QueryRow("SELECT price FROM products WHERE id = ?", item.ProductID).Scan(&price); err != nil {
+			return 0, fmt.Errorf("产品不存在: %d", item.ProductID)
+		}
+		if item.UnitPrice == 0 {
+			item.UnitPrice = price
+		}
+
+		lineTotal := roundMoney(item.UnitPrice * float64(item.Quantity))
+		if item.Discount > lineTotal {
+			return 0, fmt.Errorf("产品 %d 的折扣不能超过行金额", item.ProductID)
+		}
+		item.Discount = roundMoney(item.Discount)
+		item.Amount = roundMoney(lineTotal - item.Discount)
+
+		order.Subtotal += lineTotal
+		order.DiscountAmount += item.Discount
+
+		// 预留库存，可用库存不足时拒绝下单
+		warehouseID, err := s.inventoryService.Reserve(tx, item.ProductID, item.WarehouseID, item.Quantity)
+		if err != nil {
+			return 0, fmt.Errorf("产品 %d: %v", item.ProductID, err)
+		}
+		item.WarehouseID = warehouseID
 	}
 
-	result, err := database.DB.Exec(
-		"INSERT INTO orders (order_no, customer_id, product_id, quantity, unit_price, total_amount, status, user_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
-		order.OrderNo, order.CustomerID, order.ProductID, order.Quantity, order.UnitPrice, order.TotalAmount, order.Status, order.UserID,
+	// 金额由服务端根据明细计算
+	order.Subtotal = roundMoney(order.Subtotal)
+	order.DiscountAmount = roundMoney(order.DiscountAmount)
+	taxable := order.Subtotal - order.DiscountAmount
+	order.TaxAmount = roundMoney(taxable * order.TaxRate)
+	order.TotalAmount = roundMoney(taxable + order.TaxAmount)
+
+	result, err := tx.Exec(
+		"INSERT INTO orders (order_no, customer_id, subtotal, discount_amount, tax_rate, tax_amount, total_amount, status, user_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		order.OrderNo, order.CustomerID, order.Subtotal, order.DiscountAmount, order.TaxRate, order.TaxAmount, order.TotalAmount, order.Status, order.UserID,
 	)
 	if err != nil {
 		return 0, err
 	}
-	return result.LastInsertId()
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, item := range order.Items {
+		if _, err := tx.Exec(
+			"INSERT INTO order_items (order_id, product_id, warehouse_id, quantity, unit_price, discount, amount) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			id, item.ProductID, item.WarehouseID, item.Quantity, item.UnitPrice, item.Discount, item.Amount,
+		); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := s.recordStatus(tx, id, "", order.Status, userID, "创建订单"); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return id, nil
 }
 
-func (s *OrderService) GetByID(id int64) (*model.Order, error) {
+func (s *OrderService) GetByID(id, ownerID int64) (*model.Order, error) {
+	filter, args := ownerFilter("user_id", ownerID)
 	var order model.Order
 	err := database.DB.QueryRow(
-		"SELECT id, order_no, customer_id, product_id, quantity, unit_price, total_amount, status, user_id, created_at, updated_at FROM orders WHERE id = ?",
-		id,
-	).Scan(&order.ID, &order.OrderNo, &order.CustomerID, &order.ProductID, &order.Quantity, &order.UnitPrice, &order.TotalAmount, &order.Status, &order.UserID, &order.CreatedAt, &order.UpdatedAt)
+		"SELECT "+orderColumns+" FROM orders WHERE id = ?"+filter,
+		append([]interface{}{id}, args...)...,
+	).Scan(&order.ID, &order.OrderNo, &order.CustomerID, &order.Subtotal, &order.DiscountAmount, &order.TaxRate, &order.TaxAmount, &order.TotalAmount, &order.Status, &order.UserID, &order.CreatedAt, &order.UpdatedAt)
 
 	if err != nil {
 		return nil, errors.New("订单不存在")
 	}
+
+	items, err := s.loadItems(order.ID)
+	if err != nil {
+		return nil, err
+	}
+	order.Items = items[order.ID]
 	return &order, nil
 }
 
-func (s *OrderService) List(page, pageSize int) ([]model.Order, error) {
+func (s *OrderService) List(page, pageSize int, ownerID int64) ([]model.Order, error) {
 	offset := (page - 1) * pageSize
-	rows, err := database.DB.Query(
-		"SELECT id, order_no, customer_id, product_id, quantity, unit_price, total_amount, status, user_id, created_at, updated_at FROM orders ORDER BY created_at DESC LIMIT ? OFFSET ?",
-		pageSize, offset,
+	filter, args := ownerFilter("user_id", ownerID)
+	return s.queryOrders(
+		"SELECT "+orderColumns+" FROM orders WHERE 1=1"+filter+" ORDER BY created_at DESC LIMIT ? OFFSET ?",
+		append(args, pageSize, offset)...,
+	)
+}
+
+// ListByCustomer 获取客户的全部订单（含明细）
+func (s *OrderService) ListByCustomer(customerID int64) ([]model.Order, error) {
+	return s.queryOrders(
+		"SELECT "+orderColumns+" FROM orders WHERE customer_id = ? ORDER BY created_at DESC",
+		customerID,
 	)
+}
+
+// queryOrders 查询订单列表并批量加载明细
+func (s *OrderService) queryOrders(query string, args ...interface{}) ([]model.Order, error) {
+	rows, err := database.DB.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	var orders []model.Order
+	var ids []int64
 	for rows.Next() {
 		var order model.Order
-		if err := rows.Scan(&order.ID, &order.OrderNo, &order.CustomerID, &order.ProductID, &order.Quantity, &order.UnitPrice, &order.TotalAmount, &order.Status, &order.UserID, &order.CreatedAt, &order.UpdatedAt); err != nil {
+		if err := rows.Scan(&order.ID, &order.OrderNo, &order.CustomerID, &order.Subtotal, &order.DiscountAmount, &order.TaxRate, &order.TaxAmount, &order.TotalAmount, &order.Status, &order.UserID, &order.CreatedAt, &order.UpdatedAt); err != nil {
 			continue
 		}
 		orders = append(orders, order)
+		ids = append(ids, order.ID)
+	}
+	rows.Close()
+
+	items, err := s.loadItems(ids...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range orders {
+		orders[i].Items = items[orders[i].ID]
 	}
 	return orders, nil
 }
 
-func (s *OrderService) UpdateStatus(id int64, status string) error {
-	validStatuses := map[string]bool{
-		"pending":   true,
-		"confirmed": true,
-		"shipped":   true,
-		"completed": true,
-		"cancelled": true,
+// loadItems 批量查询订单明细，按订单ID分组
+func (s *OrderService) loadItems(orderIDs ...int64) (map[int64][]model.OrderItem, error) {
+	items := make(map[int64][]model.OrderItem)
+	if len(orderIDs) == 0 {
+		return items, nil
 	}
 
-	if !validStatuses[status] {
-		return errors.New("无效的订单状态")
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(orderIDs)), ",")
+	args := make([]interface{}, len(orderIDs))
+	for i, id := range orderIDs {
+		args[i] = id
 	}
 
-	result, err := database.DB.Exec(
-		"UPDATE orders SET status=?, updated_at=CURRENT_TIMESTAMP WHERE id=?",
-		status, id,
+	rows, err := database.DB.Query(
+		"SELECT id, order_id, product_id, COALESCE(warehouse_id, 0), quantity, unit_price, discount, amount FROM order_items WHERE order_id IN ("+placeholders+") ORDER BY id",
+		args...,
 	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item model.OrderItem
+		if err := rows.Scan(&item.ID, &item.OrderID, &item.ProductID, &item.WarehouseID, &item.Quantity, &item.UnitPrice, &item.Discount, &item.Amount); err != nil {
+			continue
+		}
+		items[item.OrderID] = append(items[item.OrderID], item)
+	}
+	return items, rows.Err()
+}
+
+// txItems 在事务中查询订单明细，用于库存调整
+func (s *OrderService) txItems(tx *sql.Tx, orderID int64) ([]model.OrderItem, error) {
+	rows, err := tx.Query("SELECT id, order_id, product_id, COALESCE(warehouse_id, 0), quantity, COALESCE(stock_tracked, 1) FROM order_items WHERE order_id = ?", orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []model.OrderItem
+	for rows.Next() {
+		var item model.OrderItem
+		if err := rows.Scan(&item.ID, &item.OrderID, &item.ProductID, &item.WarehouseID, &item.Quantity, &item.StockTracked); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *OrderService) UpdateStatus(id int64, status string, userID int64, note string, ownerID int64) error {
+	if _, ok := orderTransitions[status]; !ok {
+		return errors.New("无效的订单状态")
+	}
+
+	tx, err := database.DB.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	affected, _ := result.RowsAffected()
-	if affected == 0 {
+	filter, args := ownerFilter("user_id", ownerID)
+	var current string
+	if err := tx.QueryRow("SELECT status FROM orders WHERE id = ?"+filter, append([]interface{}{id}, args...)...).Scan(&current); err != nil {
 		return errors.New("订单不存在")
 	}
-	return nil
+
+	if !CanTransition(current, status) {
+		return fmt.Errorf("订单状态不能从 %s 变更为 %s", current, status)
+	}
+
+	items, err := s.txItems(tx, id)
+	if err != nil {
+		return err
+	}
+
+	// 发货扣减库存，取消释放预留
+	for _, item := range items {
+		if err := s.inventoryService.ApplyOrderStatus(tx, item, current, status, userID); err != nil {
+			return err
+		}
+	}
+
+	// 以当前状态作为条件更新，防止并发请求重复流转
+	result, err := tx.Exec(
+		"UPDATE orders SET status=?, updated_at=CURRENT_TIMESTAMP WHERE id=? AND status=?",
+		status, id, current,
+	)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errors.New("订单状态已变更，请刷新后重试")
+	}
+
+	if err := s.recordStatus(tx, id, current, status, userID, note); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (s *OrderService) Delete(id int64) error {
-	result, err := database.DB.Exec("DELETE FROM orders WHERE id = ?", id)
+// History 查询订单状态变更记录
+func (s *OrderService) History(id, ownerID int64) ([]model.OrderStatusHistory, error) {
+	filter, args := ownerFilter("user_id", ownerID)
+	var count int
+	err := database.DB.QueryRow("SELECT COUNT(*) FROM orders WHERE id = ?"+filter, append([]interface{}{id}, args...)...).Scan(&count)
+	if err != nil || count == 0 {
+		return nil, errors.New("订单不存在")
+	}
+
+	rows, err := database.DB.Query(
+		"SELECT id, order_id, COALESCE(from_status, ''), to_status, user_id, COALESCE(note, ''), created_at FROM order_status_history WHERE order_id = ? ORDER BY id",
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []model.OrderStatusHistory
+	for rows.Next() {
+		var h model.OrderStatusHistory
+		if err := rows.Scan(&h.ID, &h.OrderID, &h.FromStatus, &h.ToStatus, &h.UserID, &h.Note, &h.CreatedAt); err != nil {
+			continue
+		}
+		history = append(history, h)
+	}
+	return history, nil
+}
+
+func (s *OrderService) recordStatus(tx *sql.Tx, orderID int64, from, to string, userID int64, note string) error {
+	_, err := tx.Exec(
+		"INSERT INTO order_status_history (order_id, from_status, to_status, user_id, note) VALUES (?, ?, ?, ?, ?)",
+		orderID, from, to, userID, note,
+	)
+	return err
+}
+
+func (s *OrderService) Delete(id, ownerID int64) error {
+	tx, err := database.DB.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	affected, _ := result.RowsAffected()
-	if affected == 0 {
+	filter, args := ownerFilter("user_id", ownerID)
+	var status string
+	if err := tx.QueryRow("SELECT status FROM orders WHERE id = ?"+filter, append([]interface{}{id}, args...)...).Scan(&status); err != nil {
 		return errors.New("订单不存在")
 	}
-	return nil
+
+	items, err := s.txItems(tx, id)
+	if err != nil {
+		return err
+	}
+
+	// 删除未发货订单时释放其预留库存
+	if stockStateOf(status) == stockReserved {
+		for _, item := range items {
+			if !item.StockTracked {
+				continue
+			}
+			if err := s.inventoryService.Release(tx, item.ProductID, item.WarehouseID, item.Quantity); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM order_status_history WHERE order_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM order_items WHERE order_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM orders WHERE id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// roundMoney 金额保留两位小数
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
 }
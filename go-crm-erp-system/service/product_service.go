@@ -19,9 +19,13 @@ func (s *ProductService) Create(product *model.Product) (int64, error) {
 		return 0, errors.New("SKU已存在")
 	}
 
+	if product.LeadTimeDays == 0 {
+		product.LeadTimeDays = 7
+	}
+
 	result, err := database.DB.Exec(
-		"INSERT INTO products (name, sku, description, price, cost, category) VALUES (?, ?, ?, ?, ?, ?)",
-		product.Name, product.SKU, product.Description, product.Price, product.Cost, product.Category,
+		"INSERT INTO products (name, sku, description, price, cost, category, reorder_point, safety_stock, lead_time_days) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		product.Name, product.SKU, product.Description, product.Price, product.Cost, product.Category, product.ReorderPoint, product.SafetyStock, product.LeadTimeDays,
 	)
 	if err != nil {
 		return 0, err
@@ -32,9 +36,9 @@ func (s *ProductService) Create(product *model.Product) (int64, error) {
 func (s *ProductService) GetByID(id int64) (*model.Product, error) {
 	var product model.Product
 	err := database.DB.QueryRow(
-		"SELECT id, name, sku, description, price, cost, category, created_at, updated_at FROM products WHERE id = ?",
+		"SELECT id, name, sku, description, price, cost, category, reorder_point, safety_stock, lead_time_days, created_at, updated_at FROM products WHERE id = ?",
 		id,
-	).Scan(&product.ID, &product.Name, &product.SKU, &product.Description, &product.Price, &product.Cost, &product.Category, &product.CreatedAt, &product.UpdatedAt)
+	).Scan(&product.ID, &product.Name, &product.SKU, &product.Description, &product.Price, &product.Cost, &product.Category, &product.ReorderPoint, &product.SafetyStock, &product.LeadTimeDays, &product.CreatedAt, &product.UpdatedAt)
 
 	if err != nil {
 		return nil, errors.New("产品不存在")
@@ -45,7 +49,7 @@ func (s *ProductService) GetByID(id int64) (*model.Product, error) {
 func (s *ProductService) List(page, pageSize int) ([]model.Product, error) {
 	offset := (page - 1) * pageSize
 	rows, err := database.DB.Query(
-		"SELECT id, name, sku, description, price, cost, category, created_at, updated_at FROM products ORDER BY created_at DESC LIMIT ? OFFSET ?",
+		"SELECT id, name, sku, description, price, cost, category, reorder_point, safety_stock, lead_time_days, created_at, updated_at FROM products ORDER BY created_at DESC LIMIT ? OFFSET ?",
 		pageSize, offset,
 	)
 	if err != nil {
@@ -56,7 +60,7 @@ func (s *ProductService) List(page, pageSize int) ([]model.Product, error) {
 	var products []model.Product
 	for rows.Next() {
 		var product model.Product
-		if err := rows.Scan(&product.ID, &product.Name, &product.SKU, &product.Description, &product.Price, &product.Cost, &product.Category, &product.CreatedAt, &product.UpdatedAt); err != nil {
+		if err := rows.Scan(&product.ID, &product.Name, &product.SKU, &product.Description, &product.Price, &product.Cost, &product.Category, &product.ReorderPoint, &product.SafetyStock, &product.LeadTimeDays, &product.CreatedAt, &product.UpdatedAt); err != nil {
 			continue
 		}
 		products = append(products, product)
@@ -64,10 +68,11 @@ func (s *ProductService) List(page, pageSize int) ([]model.Product, error) {
 	return products, nil
 }
 
+// Update 更新产品信息，未填写采购周期时保留原值
 func (s *ProductService) Update(id int64, product *model.Product) error {
 	result, err := database.DB.Exec(
-		"UPDATE products SET name=?, description=?, price=?, cost=?, category=?, updated_at=CURRENT_TIMESTAMP WHERE id=?",
-		product.Name, product.Description, product.Price, product.Cost, product.Category, id,
+		"UPDATE products SET name=?, description=?, price=?, cost=?, category=?, reorder_point=?, safety_stock=?, lead_time_days=COALESCE(NULLIF(?, 0), lead_time_days), updated_at=CURRENT_TIMESTAMP WHERE id=?",
+		product.Name, product.Description, product.Price, product.Cost, product.Category, product.ReorderPoint, product.SafetyStock, product.LeadTimeDays, id,
 	)
 	if err != nil {
 		return err
@@ -0,0 +1,275 @@
+package service
+
+import (
+	"crm-erp-system/database"
+	"crm-erp-system/model"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type PurchaseOrderService struct {
+	inventoryService InventoryService
+}
+
+const purchaseOrderColumns = "id, po_no, supplier_id, warehouse_id, total_amount, status, COALESCE(expected_date, ''), COALESCE(note, ''), user_id, created_at, updated_at"
+
+func (s *PurchaseOrderService) Create(req *model.CreatePurchaseOrderRequest, userID int64) (int64, error) {
+	po := model.PurchaseOrder{
+		PONo:         fmt.Sprintf("PO%d", time.Now().UnixNano()),
+		SupplierID:   req.SupplierID,
+		WarehouseID:  req.WarehouseID,
+		Status:       model.PurchasePending,
+		ExpectedDate: req.ExpectedDate,
+		Note:         req.Note,
+		UserID:       userID,
+		Items:        req.Items,
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var count int
+	err = tx.QueryRow("SELECT COUNT(*) FROM suppliers WHERE id = ?", po.SupplierID).Scan(&count)
+	if err != nil || count == 0 {
+		return 0, errors.New("供应商不存在")
+	}
+
+	// 未指定收货仓库时使用默认仓库
+	if po.WarehouseID == 0 {
+		err = tx.QueryRow("SELECT id FROM warehouses WHERE code = ?", database.DefaultWarehouseCode).Scan(&po.WarehouseID)
+	} else {
+		err = tx.QueryRow("SELECT id FROM warehouses WHERE id = ?", po.WarehouseID).Scan(&po.WarehouseID)
+	}
+	if err != nil {
+		return 0, errors.New("仓库不存在")
+	}
+
+	for i := range po.Items {
+		item := &po.Items[i]
+		err = tx.QueryRow("SELECT COUNT(*) FROM products WHERE id = ?", item.ProductID).Scan(&count)
+		if err != nil || count == 0 {
+			return 0, fmt.Errorf("产品不存在: %d", item.ProductID)
+		}
+		item.Amount = roundMoney(item.UnitCost * float64(item.Quantity))
+		po.TotalAmount += item.Amount
+	}
+	po.TotalAmount = roundMoney(po.TotalAmount)
+
+	result, err := tx.Exec(
+		"INSERT INTO purchase_orders (po_no, supplier_id, warehouse_id, total_amount, status, expected_date, note, user_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		po.PONo, po.SupplierID, po.WarehouseID, po.TotalAmount, po.Status, po.ExpectedDate, po.Note, po.UserID,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, item := range po.Items {
+		if _, err := tx.Exec(
+			"INSERT INTO purchase_order_items (purchase_order_id, product_id, quantity, unit_cost, amount) VALUES (?, ?, ?, ?, ?)",
+			id, item.ProductID, item.Quantity, item.UnitCost, item.Amount,
+		); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (s *PurchaseOrderService) GetByID(id int64) (*model.PurchaseOrder, error) {
+	var po model.PurchaseOrder
+	err := database.DB.QueryRow(
+		"SELECT "+purchaseOrderColumns+" FROM purchase_orders WHERE id = ?",
+		id,
+	).Scan(&po.ID, &po.PONo, &po.SupplierID, &po.WarehouseID, &po.TotalAmount, &po.Status, &po.ExpectedDate, &po.Note, &po.UserID, &po.CreatedAt, &po.UpdatedAt)
+
+	if err != nil {
+		return nil, errors.New("采购单不存在")
+	}
+
+	rows, err := database.DB.Query(
+		"SELECT id, purchase_order_id, product_id, quantity, received_quantity, unit_cost, amount FROM purchase_order_items WHERE purchase_order_id = ? ORDER BY id",
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item model.PurchaseOrderItem
+		if err := rows.Scan(&item.ID, &item.PurchaseOrderID, &item.ProductID, &item.Quantity, &item.ReceivedQuantity, &item.UnitCost, &item.Amount); err != nil {
+			continue
+		}
+		po.Items = append(po.Items, item)
+	}
+	return &po, nil
+}
+
+func (s *PurchaseOrderService) List(supplierID int64, status string, page, pageSize int) ([]model.PurchaseOrder, error) {
+	query := "SELECT " + purchaseOrderColumns + " FROM purchase_orders WHERE 1=1"
+	var args []interface{}
+	if supplierID > 0 {
+		query += " AND supplier_id = ?"
+		args = append(args, supplierID)
+	}
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY created_at DESC LIMIT ? OFFSET ?"
+	args = append(args, pageSize, (page-1)*pageSize)
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []model.PurchaseOrder
+	for rows.Next() {
+		var po model.PurchaseOrder
+		if err := rows.Scan(&po.ID, &po.PONo, &po.SupplierID, &po.WarehouseID, &po.TotalAmount, &po.Status, &po.ExpectedDate, &po.Note, &po.UserID, &po.CreatedAt, &po.UpdatedAt); err != nil {
+			continue
+		}
+		orders = append(orders, po)
+	}
+	return orders, nil
+}
+
+// Receive 采购收货：累加明细已收数量，入库并记流水，同时记录供应商采购价并更新产品成本。
+// 分多次收货时每个采购单的每个产品只记录一次采购价
+func (s *PurchaseOrderService) Receive(id int64, req *model.ReceiveRequest, userID int64) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var supplierID, warehouseID int64
+	var status string
+	err = tx.QueryRow("SELECT supplier_id, warehouse_id, status FROM purchase_orders WHERE id = ?", id).Scan(&supplierID, &warehouseID, &status)
+	if err != nil {
+		return errors.New("采购单不存在")
+	}
+	if status != model.PurchasePending && status != model.PurchasePartial {
+		return errors.New("采购单当前状态不允许收货")
+	}
+
+	// 可指定本次收货的仓库，默认收入采购单仓库
+	if req.WarehouseID > 0 {
+		var count int
+		err = tx.QueryRow("SELECT COUNT(*) FROM warehouses WHERE id = ?", req.WarehouseID).Scan(&count)
+		if err != nil || count == 0 {
+			return errors.New("仓库不存在")
+		}
+		warehouseID = req.WarehouseID
+	}
+
+	for _, r := range req.Items {
+		var productID int64
+		var unitCost float64
+		err := tx.QueryRow(
+			"SELECT product_id, unit_cost FROM purchase_order_items WHERE id = ? AND purchase_order_id = ?",
+			r.ItemID, id,
+		).Scan(&productID, &unitCost)
+		if err != nil {
+			return fmt.Errorf("采购明细不存在: %d", r.ItemID)
+		}
+
+		// 收货数量不能超过未收数量
+		result, err := tx.Exec(
+			"UPDATE purchase_order_items SET received_quantity = received_quantity + ? WHERE id = ? AND quantity - received_quantity >= ?",
+			r.Quantity, r.ItemID, r.Quantity,
+		)
+		if err != nil {
+			return err
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return fmt.Errorf("明细 %d 收货数量超过未收数量", r.ItemID)
+		}
+
+		if err := s.inventoryService.Receive(tx, &model.StockMovement{
+			ProductID:   productID,
+			WarehouseID: warehouseID,
+			Quantity:    r.Quantity,
+			RefType:     "purchase_order",
+			RefID:       id,
+			Note:        req.Note,
+			UserID:      userID,
+		}); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(
+			`INSERT INTO supplier_prices (supplier_id, product_id, price, purchase_order_id, purchase_order_item_id) VALUES (?, ?, ?, ?, ?)
+				ON CONFLICT(purchase_order_item_id) DO NOTHING`,
+			supplierID, productID, unitCost, id, r.ItemID,
+		); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE products SET cost=?, updated_at=CURRENT_TIMESTAMP WHERE id=?", unitCost, productID); err != nil {
+			return err
+		}
+	}
+
+	var remaining int
+	err = tx.QueryRow("SELECT COALESCE(SUM(quantity - received_quantity), 0) FROM purchase_order_items WHERE purchase_order_id = ?", id).Scan(&remaining)
+	if err != nil {
+		return err
+	}
+	status = model.PurchasePartial
+	if remaining == 0 {
+		status = model.PurchaseReceived
+	}
+
+	if _, err := tx.Exec("UPDATE purchase_orders SET status=?, updated_at=CURRENT_TIMESTAMP WHERE id=?", status, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Cancel 取消尚未收货的采购单
+func (s *PurchaseOrderService) Cancel(id int64) error {
+	result, err := database.DB.Exec(
+		"UPDATE purchase_orders SET status=?, updated_at=CURRENT_TIMESTAMP WHERE id=? AND status=?",
+		model.PurchaseCancelled, id, model.PurchasePending,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return errors.New("采购单不存在或已收货，无法取消")
+	}
+	return nil
+}
+
+// Close 关闭部分收货的采购单，剩余未收数量不再收货
+func (s *PurchaseOrderService) Close(id int64) error {
+	result, err := database.DB.Exec(
+		"UPDATE purchase_orders SET status=?, updated_at=CURRENT_TIMESTAMP WHERE id=? AND status=?",
+		model.PurchaseClosed, id, model.PurchasePartial,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return errors.New("采购单不存在或不是部分收货状态，无法关闭")
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"crm-erp-system/config"
+	"crm-erp-system/database"
+	"crm-erp-system/model"
+	"log"
+	"math"
+	"time"
+)
+
+type StockAlertService struct{}
+
+// Check 检查所有设置了补货点的产品，可用库存不高于补货点的产品生成或刷新预警，已恢复的预警自动关闭
+func (s *StockAlertService) Check() error {
+	days := config.AppConfig.SalesVelocityDays
+	since := time.Now().UTC().AddDate(0, 0, -days).Format("2006-01-02 15:04:05")
+
+	rows, err := database.DB.Query(`
+		SELECT p.id, p.reorder_point, p.safety_stock, p.lead_time_days,
+			COALESCE((SELECT SUM(quantity - reserved) FROM inventory WHERE product_id = p.id), 0),
+			COALESCE((SELECT SUM(oi.quantity) FROM order_items oi JOIN orders o ON o.id = oi.order_id
+				WHERE oi.product_id = p.id AND o.status <> 'cancelled' AND o.created_at >= ?), 0)
+		FROM products p WHERE p.reorder_point > 0`,
+		since,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var alerts []model.StockAlert
+	for rows.Next() {
+		var alert model.StockAlert
+		var leadTimeDays, sold int
+		if err := rows.Scan(&alert.ProductID, &alert.ReorderPoint, &alert.SafetyStock, &leadTimeDays, &alert.Available, &sold); err != nil {
+			return err
+		}
+		if alert.Available > alert.ReorderPoint {
+			continue
+		}
+
+		alert.DailyVelocity = float64(sold) / float64(days)
+		alert.SuggestedQuantity = suggestReorderQuantity(alert.Available, alert.ReorderPoint, alert.SafetyStock, leadTimeDays, days, alert.DailyVelocity)
+		alerts = append(alerts, alert)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 先将所有未关闭预警标记为待确认，仍低于阈值的会在下面重新打开
+	if _, err := tx.Exec("UPDATE stock_alerts SET status = 'checking' WHERE status = 'open'"); err != nil {
+		return err
+	}
+
+	for _, alert := range alerts {
+		if _, err := tx.Exec(`
+			INSERT INTO stock_alerts (product_id, available, reorder_point, safety_stock, daily_velocity, suggested_quantity, status)
+			VALUES (?, ?, ?, ?, ?, ?, 'open')
+			ON CONFLICT(product_id) DO UPDATE SET
+				available = excluded.available,
+				reorder_point = excluded.reorder_point,
+				safety_stock = excluded.safety_stock,
+				daily_velocity = excluded.daily_velocity,
+				suggested_quantity = excluded.suggested_quantity,
+				triggered_at = CASE WHEN stock_alerts.status = 'checking' THEN stock_alerts.triggered_at ELSE CURRENT_TIMESTAMP END,
+				status = 'open',
+				resolved_at = NULL,
+				updated_at = CURRENT_TIMESTAMP`,
+			alert.ProductID, alert.Available, alert.ReorderPoint, alert.SafetyStock, alert.DailyVelocity, alert.SuggestedQuantity,
+		); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE stock_alerts SET status = 'resolved', resolved_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE status = 'checking'",
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// suggestReorderQuantity 计算建议补货量：补足到可覆盖采购周期加一个统计周期销量的库存，再加上安全库存；
+// 没有销售记录时至少补到补货点加安全库存
+func suggestReorderQuantity(available, reorderPoint, safetyStock, leadTimeDays, velocityDays int, dailyVelocity float64) int {
+	target := int(math.Ceil(dailyVelocity*float64(leadTimeDays+velocityDays))) + safetyStock
+	if target < reorderPoint+safetyStock {
+		target = reorderPoint + safetyStock
+	}
+	if target <= available {
+		return 0
+	}
+	return target - available
+}
+
+// List 获取当前未关闭的库存预警
+func (s *StockAlertService) List() ([]model.StockAlert, error) {
+	rows, err := database.DB.Query(`
+		SELECT a.id, a.product_id, p.name, p.sku, a.available, a.reorder_point, a.safety_stock,
+			a.daily_velocity, a.suggested_quantity, a.status, a.triggered_at, a.updated_at
+		FROM stock_alerts a JOIN products p ON p.id = a.product_id
+		WHERE a.status = 'open'
+		ORDER BY a.available - a.reorder_point, a.triggered_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []model.StockAlert
+	for rows.Next() {
+		var alert model.StockAlert
+		if err := rows.Scan(&alert.ID, &alert.ProductID, &alert.ProductName, &alert.SKU, &alert.Available, &alert.ReorderPoint, &alert.SafetyStock,
+			&alert.DailyVelocity, &alert.SuggestedQuantity, &alert.Status, &alert.TriggeredAt, &alert.UpdatedAt); err != nil {
+			continue
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
+
+// StartAlertChecker 启动后台库存预警检查，返回用于停止检查的函数
+func StartAlertChecker(interval time.Duration) func() {
+	service := &StockAlertService{}
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			if err := service.Check(); err != nil {
+				log.Printf("库存预警检查失败: %v", err)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"crm-erp-system/database"
+	"crm-erp-system/model"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type StockMovementService struct{}
+
+// recordMovement 在事务中追加一条库存流水，结存取库存表更新后的数量
+func recordMovement(tx *sql.Tx, m *model.StockMovement) error {
+	if m.Quantity == 0 {
+		return nil
+	}
+
+	err := tx.QueryRow(
+		"SELECT quantity FROM inventory WHERE product_id = ? AND warehouse_id = ?",
+		m.ProductID, m.WarehouseID,
+	).Scan(&m.BalanceAfter)
+	if err != nil {
+		return errors.New("库存记录不存在")
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO stock_movements (product_id, warehouse_id, type, quantity, balance_after, ref_type, ref_id, note, user_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		m.ProductID, m.WarehouseID, m.Type, m.Quantity, m.BalanceAfter, m.RefType, m.RefID, m.Note, m.UserID,
+	)
+	return err
+}
+
+// List 按产品、仓库、类型和日期范围查询库存流水
+func (s *StockMovementService) List(q *model.MovementQuery) ([]model.StockMovement, error) {
+	query := "SELECT id, product_id, warehouse_id, type, quantity, balance_after, COALESCE(ref_type, ''), COALESCE(ref_id, 0), COALESCE(note, ''), COALESCE(user_id, 0), created_at FROM stock_movements WHERE 1=1"
+	var args []interface{}
+
+	if q.ProductID > 0 {
+		query += " AND product_id = ?"
+		args = append(args, q.ProductID)
+	}
+	if q.WarehouseID > 0 {
+		query += " AND warehouse_id = ?"
+		args = append(args, q.WarehouseID)
+	}
+	if q.Type != "" {
+		query += " AND type = ?"
+		args = append(args, q.Type)
+	}
+	if q.StartDate != "" {
+		start, err := time.Parse("2006-01-02", q.StartDate)
+		if err != nil {
+			return nil, errors.New("开始日期格式应为 YYYY-MM-DD")
+		}
+		query += " AND created_at >= ?"
+		args = append(args, start.Format("2006-01-02 15:04:05"))
+	}
+	if q.EndDate != "" {
+		end, err := time.Parse("2006-01-02", q.EndDate)
+		if err != nil {
+			return nil, errors.New("结束日期格式应为 YYYY-MM-DD")
+		}
+		// 结束日期当天包含在内
+		query += " AND created_at < ?"
+		args = append(args, end.AddDate(0, 0, 1).Format("2006-01-02 15:04:05"))
+	}
+
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+	if q.PageSize <= 0 {
+		q.PageSize = 20
+	}
+	query += " ORDER BY id DESC LIMIT ? OFFSET ?"
+	args = append(args, q.PageSize, (q.Page-1)*q.PageSize)
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var movements []model.StockMovement
+	for rows.Next() {
+		var m model.StockMovement
+		if err := rows.Scan(&m.ID, &m.ProductID, &m.WarehouseID, &m.Type, &m.Quantity, &m.BalanceAfter, &m.RefType, &m.RefID, &m.Note, &m.UserID, &m.CreatedAt); err != nil {
+			continue
+		}
+		movements = append(movements, m)
+	}
+	return movements, nil
+}
+
+// Reconcile 将库存表数量与流水汇总核对，返回不一致的记录
+func (s *StockMovementService) Reconcile() ([]model.StockDiscrepancy, error) {
+	rows, err := database.DB.Query(`
+		SELECT i.product_id, i.warehouse_id, i.quantity, COALESCE(SUM(m.quantity), 0) AS ledger
+		FROM inventory i
+		LEFT JOIN stock_movements m ON m.product_id = i.product_id AND m.warehouse_id = i.warehouse_id
+		GROUP BY i.id
+		HAVING i.quantity <> ledger
+		ORDER BY i.product_id, i.warehouse_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var discrepancies []model.StockDiscrepancy
+	for rows.Next() {
+		var d model.StockDiscrepancy
+		if err := rows.Scan(&d.ProductID, &d.WarehouseID, &d.Quantity, &d.LedgerQuantity); err != nil {
+			continue
+		}
+		d.Difference = d.Quantity - d.LedgerQuantity
+		discrepancies = append(discrepancies, d)
+	}
+	return discrepancies, nil
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"crm-erp-system/database"
+	"crm-erp-system/model"
+	"errors"
+)
+
+type SupplierService struct{}
+
+func (s *SupplierService) Create(supplier *model.Supplier) (int64, error) {
+	if supplier.Status == "" {
+		supplier.Status = "active"
+	}
+
+	result, err := database.DB.Exec(
+		"INSERT INTO suppliers (name, contact, phone, email, address, status) VALUES (?, ?, ?, ?, ?, ?)",
+		supplier.Name, supplier.Contact, supplier.Phone, supplier.Email, supplier.Address, supplier.Status,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (s *SupplierService) GetByID(id int64) (*model.Supplier, error) {
+	var supplier model.Supplier
+	err := database.DB.QueryRow(
+		"SELECT id, name, COALESCE(contact, ''), COALESCE(phone, ''), COALESCE(email, ''), COALESCE(address, ''), status, created_at, updated_at FROM suppliers WHERE id = ?",
+		id,
+	).Scan(&supplier.ID, &supplier.Name, &supplier.Contact, &supplier.Phone, &supplier.Email, &supplier.Address, &supplier.Status, &supplier.CreatedAt, &supplier.UpdatedAt)
+
+	if err != nil {
+		return nil, errors.New("供应商不存在")
+	}
+	return &supplier, nil
+}
+
+func (s *SupplierService) List(page, pageSize int) ([]model.Supplier, error) {
+	offset := (page - 1) * pageSize
+	rows, err := database.DB.Query(
+		"SELECT id, name, COALESCE(contact, ''), COALESCE(phone, ''), COALESCE(email, ''), COALESCE(address, ''), status, created_at, updated_at FROM suppliers ORDER BY created_at DESC LIMIT ? OFFSET ?",
+		pageSize, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suppliers []model.Supplier
+	for rows.Next() {
+		var supplier model.Supplier
+		if err := rows.Scan(&supplier.ID, &supplier.Name, &supplier.Contact, &supplier.Phone, &supplier.Email, &supplier.Address, &supplier.Status, &supplier.CreatedAt, &supplier.UpdatedAt); err != nil {
+			continue
+		}
+		suppliers = append(suppliers, supplier)
+	}
+	return suppliers, nil
+}
+
+func (s *SupplierService) Update(id int64, supplier *model.Supplier) error {
+	if supplier.Status == "" {
+		supplier.Status = "active"
+	}
+
+	result, err := database.DB.Exec(
+		"UPDATE suppliers SET name=?, contact=?, phone=?, email=?, address=?, status=?, updated_at=CURRENT_TIMESTAMP WHERE id=?",
+		supplier.Name, supplier.Contact, supplier.Phone, supplier.Email, supplier.Address, supplier.Status, id,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return errors.New("供应商不存在")
+	}
+	return nil
+}
+
+func (s *SupplierService) Delete(id int64) error {
+	// 有采购单的供应商不允许删除
+	var count int
+	err := database.DB.QueryRow("SELECT COUNT(*) FROM purchase_orders WHERE supplier_id = ?", id).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("供应商存在采购单，无法删除")
+	}
+
+	result, err := database.DB.Exec("DELETE FROM suppliers WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return errors.New("供应商不存在")
+	}
+	_, err = database.DB.Exec("DELETE FROM supplier_prices WHERE supplier_id = ?", id)
+	return err
+}
+
+// AddPrice 记录供应商报价
+func (s *SupplierService) AddPrice(supplierID int64, price *model.SupplierPrice) (int64, error) {
+	var count int
+	err := database.DB.QueryRow("SELECT COUNT(*) FROM suppliers WHERE id = ?", supplierID).Scan(&count)
+	if err != nil || count == 0 {
+		return 0, errors.New("供应商不存在")
+	}
+	err = database.DB.QueryRow("SELECT COUNT(*) FROM products WHERE id = ?", price.ProductID).Scan(&count)
+	if err != nil || count == 0 {
+		return 0, errors.New("产品不存在")
+	}
+
+	result, err := database.DB.Exec(
+		"INSERT INTO supplier_prices (supplier_id, product_id, price, note) VALUES (?, ?, ?, ?)",
+		supplierID, price.ProductID, price.Price, price.Note,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// Prices 查询供应商价格历史，可按产品筛选
+func (s *SupplierService) Prices(supplierID, productID int64) ([]model.SupplierPrice, error) {
+	query := "SELECT id, supplier_id, product_id, price, COALESCE(purchase_order_id, 0), COALESCE(note, ''), created_at FROM supplier_prices WHERE supplier_id = ?"
+	args := []interface{}{supplierID}
+	if productID > 0 {
+		query += " AND product_id = ?"
+		args = append(args, productID)
+	}
+
+	rows, err := database.DB.Query(query+" ORDER BY id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prices []model.SupplierPrice
+	for rows.Next() {
+		var price model.SupplierPrice
+		if err := rows.Scan(&price.ID, &price.SupplierID, &price.ProductID, &price.Price, &price.PurchaseOrderID, &price.Note, &price.CreatedAt); err != nil {
+			continue
+		}
+		prices = append(prices, price)
+	}
+	return prices, nil
+}
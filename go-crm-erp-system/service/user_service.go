@@ -13,36 +13,45 @@ import (
 type UserService struct{}
 
 func (s *UserService) Register(req *model.RegisterRequest) error {
+	// 加密密码
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	// 检查用户名是否存在
 	var count int
-	err := database.DB.QueryRow("SELECT COUNT(*) FROM users WHERE username = ?", req.Username).Scan(&count)
-	if err != nil {
+	if err := tx.QueryRow("SELECT COUNT(*) FROM users WHERE username = ?", req.Username).Scan(&count); err != nil {
 		return err
 	}
 	if count > 0 {
 		return errors.New("用户名已存在")
 	}
 
-	// 加密密码
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
+	// 第一个注册的用户为管理员，其余默认为销售代表，由管理员分配角色。
+	// 在插入语句中判断是否已有用户，并发注册时也只会产生一个管理员
+	if _, err := tx.Exec(
+		`INSERT INTO users (username, password, email, phone, role)
+			SELECT ?, ?, ?, ?, CASE WHEN EXISTS (SELECT 1 FROM users) THEN ? ELSE ? END`,
+		req.Username, string(hashedPassword), req.Email, req.Phone, model.RoleSalesRep, model.RoleAdmin,
+	); err != nil {
 		return err
 	}
-
-	// 插入用户
-	_, err = database.DB.Exec(
-		"INSERT INTO users (username, password, email, phone) VALUES (?, ?, ?, ?)",
-		req.Username, string(hashedPassword), req.Email, req.Phone,
-	)
-	return err
+	return tx.Commit()
 }
 
 func (s *UserService) Login(req *model.LoginRequest) (string, error) {
 	var user model.User
 	err := database.DB.QueryRow(
-		"SELECT id, username, password FROM users WHERE username = ?",
+		"SELECT id, username, password, role FROM users WHERE username = ?",
 		req.Username,
-	).Scan(&user.ID, &user.Username, &user.Password)
+	).Scan(&user.ID, &user.Username, &user.Password, &user.Role)
 
 	if err != nil {
 		return "", errors.New("用户名或密码错误")
@@ -54,7 +63,7 @@ func (s *UserService) Login(req *model.LoginRequest) (string, error) {
 	}
 
 	// 生成JWT
-	token, err := s.generateToken(user.ID, user.Username)
+	token, err := s.generateToken(user.ID, user.Username, user.Role)
 	if err != nil {
 		return "", err
 	}
@@ -62,10 +71,11 @@ func (s *UserService) Login(req *model.LoginRequest) (string, error) {
 	return token, nil
 }
 
-func (s *UserService) generateToken(userID int64, username string) (string, error) {
+func (s *UserService) generateToken(userID int64, username, role string) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id":  userID,
 		"username": username,
+		"role":     role,
 		"exp":      time.Now().Add(time.Hour * 24 * 7).Unix(), // 7天过期
 	}
 
@@ -76,12 +86,63 @@ func (s *UserService) generateToken(userID int64, username string) (string, erro
 func (s *UserService) GetUserInfo(userID int64) (*model.User, error) {
 	var user model.User
 	err := database.DB.QueryRow(
-		"SELECT id, username, email, phone, created_at FROM users WHERE id = ?",
+		"SELECT id, username, email, phone, role, created_at FROM users WHERE id = ?",
 		userID,
-	).Scan(&user.ID, &user.Username, &user.Email, &user.Phone, &user.CreatedAt)
+	).Scan(&user.ID, &user.Username, &user.Email, &user.Phone, &user.Role, &user.CreatedAt)
 
 	if err != nil {
 		return nil, errors.New("用户不存在")
 	}
 	return &user, nil
 }
+
+func (s *UserService) List(page, pageSize int) ([]model.User, error) {
+	offset := (page - 1) * pageSize
+	rows, err := database.DB.Query(
+		"SELECT id, username, COALESCE(email, ''), COALESCE(phone, ''), role, created_at, updated_at FROM users ORDER BY id LIMIT ? OFFSET ?",
+		pageSize, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []model.User
+	for rows.Next() {
+		var user model.User
+		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Phone, &user.Role, &user.CreatedAt, &user.UpdatedAt); err != nil {
+			continue
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// UpdateRole 修改用户角色，新角色在用户的下一次请求立即生效；不能撤销最后一个管理员
+func (s *UserService) UpdateRole(id int64, role string) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current string
+	if err := tx.QueryRow("SELECT role FROM users WHERE id = ?", id).Scan(&current); err != nil {
+		return errors.New("用户不存在")
+	}
+
+	if current == model.RoleAdmin && role != model.RoleAdmin {
+		var admins int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM users WHERE role = ?", model.RoleAdmin).Scan(&admins); err != nil {
+			return err
+		}
+		if admins <= 1 {
+			return errors.New("至少需要保留一个管理员")
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE users SET role=?, updated_at=CURRENT_TIMESTAMP WHERE id=?", role, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
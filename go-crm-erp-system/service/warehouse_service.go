@@ -0,0 +1,120 @@
+package service
+
+import (
+	"crm-erp-system/database"
+	"crm-erp-system/model"
+	"errors"
+)
+
+type WarehouseService struct{}
+
+func (s *WarehouseService) Create(warehouse *model.Warehouse) (int64, error) {
+	// 检查编码是否存在
+	var count int
+	err := database.DB.QueryRow("SELECT COUNT(*) FROM warehouses WHERE code = ?", warehouse.Code).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		return 0, errors.New("仓库编码已存在")
+	}
+
+	if warehouse.Status == "" {
+		warehouse.Status = "active"
+	}
+
+	result, err := database.DB.Exec(
+		"INSERT INTO warehouses (code, name, address, status) VALUES (?, ?, ?, ?)",
+		warehouse.Code, warehouse.Name, warehouse.Address, warehouse.Status,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (s *WarehouseService) GetByID(id int64) (*model.Warehouse, error) {
+	var warehouse model.Warehouse
+	err := database.DB.QueryRow(
+		"SELECT id, code, name, COALESCE(address, ''), status, created_at, updated_at FROM warehouses WHERE id = ?",
+		id,
+	).Scan(&warehouse.ID, &warehouse.Code, &warehouse.Name, &warehouse.Address, &warehouse.Status, &warehouse.CreatedAt, &warehouse.UpdatedAt)
+
+	if err != nil {
+		return nil, errors.New("仓库不存在")
+	}
+	return &warehouse, nil
+}
+
+func (s *WarehouseService) List() ([]model.Warehouse, error) {
+	rows, err := database.DB.Query(
+		"SELECT id, code, name, COALESCE(address, ''), status, created_at, updated_at FROM warehouses ORDER BY id",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var warehouses []model.Warehouse
+	for rows.Next() {
+		var warehouse model.Warehouse
+		if err := rows.Scan(&warehouse.ID, &warehouse.Code, &warehouse.Name, &warehouse.Address, &warehouse.Status, &warehouse.CreatedAt, &warehouse.UpdatedAt); err != nil {
+			continue
+		}
+		warehouses = append(warehouses, warehouse)
+	}
+	return warehouses, nil
+}
+
+func (s *WarehouseService) Update(id int64, warehouse *model.Warehouse) error {
+	if warehouse.Status == "" {
+		warehouse.Status = "active"
+	}
+
+	result, err := database.DB.Exec(
+		"UPDATE warehouses SET name=?, address=?, status=?, updated_at=CURRENT_TIMESTAMP WHERE id=?",
+		warehouse.Name, warehouse.Address, warehouse.Status, id,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return errors.New("仓库不存在")
+	}
+	return nil
+}
+
+func (s *WarehouseService) Delete(id int64) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 仓库内仍有库存时不允许删除
+	var count int
+	err = tx.QueryRow("SELECT COUNT(*) FROM inventory WHERE warehouse_id = ? AND (quantity > 0 OR reserved > 0)", id).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("仓库内仍有库存，无法删除")
+	}
+
+	if _, err := tx.Exec("DELETE FROM inventory WHERE warehouse_id = ?", id); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("DELETE FROM warehouses WHERE id = ? AND code <> ?", id, database.DefaultWarehouseCode)
+	if err != nil {
+		return err
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return errors.New("仓库不存在或为默认仓库")
+	}
+	return tx.Commit()
+}
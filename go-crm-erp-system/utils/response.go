@@ -45,6 +45,13 @@ func Unauthorized(c *gin.Context, message string) {
 	})
 }
 
+func Forbidden(c *gin.Context, message string) {
+	c.JSON(http.StatusForbidden, Response{
+		Code:    403,
+		Message: message,
+	})
+}
+
 func NotFound(c *gin.Context, message string) {
 	Error(c, 404, message)
 }
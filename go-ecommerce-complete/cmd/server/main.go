@@ -6,6 +6,7 @@ import (
 	"ecommerce/internal/middleware"
 	"log"
 	"net/http"
+	"time"
 )
 
 func main() {
@@ -14,6 +15,9 @@ func main() {
 	}
 	defer database.DB.Close()
 
+	stopSweeper := middleware.Sessions.StartSweeper(10 * time.Minute)
+	defer stopSweeper()
+
 	fs := http.FileServer(http.Dir("web/static"))
 	http.Handle("/static/", http.StripPrefix("/static/", fs))
 
@@ -33,11 +37,16 @@ func main() {
 	http.HandleFunc("/api/login", handlers.Login)
 	http.HandleFunc("/api/logout", handlers.Logout)
 	http.HandleFunc("/api/current-user", handlers.CurrentUser)
+	http.HandleFunc("/api/change-password", middleware.AuthMiddleware(handlers.ChangePassword))
 
 	http.HandleFunc("/api/products", handlers.GetProducts)
 	http.HandleFunc("/api/product/", handlers.GetProductDetail)
 	http.HandleFunc("/api/categories", handlers.GetCategories)
 
+	http.HandleFunc("/api/reviews", handlers.GetProductReviews)
+	http.HandleFunc("/api/reviews/create", middleware.AuthMiddleware(handlers.CreateReview))
+	http.HandleFunc("/api/reviews/reply", middleware.AuthMiddleware(handlers.ReplyReview))
+
 	http.HandleFunc("/api/cart", middleware.AuthMiddleware(handlers.GetCart))
 	http.HandleFunc("/api/cart/add", middleware.AuthMiddleware(handlers.AddToCart))
 	http.HandleFunc("/api/cart/update", middleware.AuthMiddleware(handlers.UpdateCart))
@@ -47,6 +56,7 @@ func main() {
 	http.HandleFunc("/api/orders/create", middleware.AuthMiddleware(handlers.CreateOrder))
 	http.HandleFunc("/api/orders/pay", middleware.AuthMiddleware(handlers.PayOrder))
 	http.HandleFunc("/api/orders/cancel", middleware.AuthMiddleware(handlers.CancelOrder))
+	http.HandleFunc("/api/orders/confirm", middleware.AuthMiddleware(handlers.ConfirmReceipt))
 
 	http.HandleFunc("/api/addresses", middleware.AuthMiddleware(handlers.GetAddresses))
 	http.HandleFunc("/api/addresses/add", middleware.AuthMiddleware(handlers.AddAddress))
@@ -57,9 +67,13 @@ func main() {
 	http.HandleFunc("/api/seller/products/update", middleware.RoleMiddleware("seller")(handlers.SellerUpdateProduct))
 	http.HandleFunc("/api/seller/products/delete", middleware.RoleMiddleware("seller")(handlers.SellerDeleteProduct))
 	http.HandleFunc("/api/seller/orders", middleware.RoleMiddleware("seller")(handlers.SellerGetOrders))
+	http.HandleFunc("/api/seller/orders/ship", middleware.RoleMiddleware("seller")(handlers.SellerShipOrder))
 
 	http.HandleFunc("/api/admin/products/approve", middleware.RoleMiddleware("admin")(handlers.AdminApproveProduct))
 	http.HandleFunc("/api/admin/products/reject", middleware.RoleMiddleware("admin")(handlers.AdminRejectProduct))
+	http.HandleFunc("/api/admin/reviews", middleware.RoleMiddleware("admin")(handlers.AdminGetReviews))
+	http.HandleFunc("/api/admin/reviews/approve", middleware.RoleMiddleware("admin")(handlers.AdminApproveReview))
+	http.HandleFunc("/api/admin/reviews/reject", middleware.RoleMiddleware("admin")(handlers.AdminRejectReview))
 	http.HandleFunc("/api/admin/users", middleware.RoleMiddleware("admin")(handlers.AdminGetUsers))
 	http.HandleFunc("/api/admin/stats", middleware.RoleMiddleware("admin")(handlers.AdminGetStats))
 
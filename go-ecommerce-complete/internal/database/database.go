@@ -1,20 +1,23 @@
 package database
 
 import (
-	"crypto/sha256"
 	"database/sql"
+	"ecommerce/internal/password"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
-	_ "modernc.org/sqlite"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
 )
 
 var DB *sql.DB
 
 func InitDB() error {
 	var err error
-	DB, err = sql.Open("sqlite", "./ecommerce.db")
+	// 事务开始即获取写锁（BEGIN IMMEDIATE），并发写事务排队等待而不是返回 SQLITE_BUSY
+	DB, err = sql.Open("sqlite", "file:./ecommerce.db?_pragma=busy_timeout(5000)&_txlock=immediate")
 	if err != nil {
 		return err
 	}
@@ -24,6 +27,9 @@ func InitDB() error {
 	if err = createTables(); err != nil {
 		return err
 	}
+	if err = migrateTables(); err != nil {
+		return err
+	}
 	if err = insertInitialData(); err != nil {
 		return err
 	}
@@ -104,6 +110,7 @@ func createTables() error {
 			paid_at TIMESTAMP,
 			shipped_at TIMESTAMP,
 			completed_at TIMESTAMP,
+			idempotency_key TEXT,
 			FOREIGN KEY (user_id) REFERENCES users(id)
 		)`,
 		`CREATE TABLE IF NOT EXISTS order_items (
@@ -114,6 +121,8 @@ func createTables() error {
 			product_price REAL NOT NULL,
 			quantity INTEGER NOT NULL,
 			subtotal REAL NOT NULL,
+			tracking_number TEXT,
+			shipped_at TIMESTAMP,
 			FOREIGN KEY (order_id) REFERENCES orders(id),
 			FOREIGN KEY (product_id) REFERENCES products(id)
 		)`,
@@ -122,6 +131,7 @@ func createTables() error {
 			user_id INTEGER NOT NULL,
 			product_id INTEGER NOT NULL,
 			order_id INTEGER NOT NULL,
+			order_item_id INTEGER,
 			rating INTEGER NOT NULL CHECK(rating >= 1 AND rating <= 5),
 			comment TEXT,
 			status TEXT DEFAULT 'approved',
@@ -133,6 +143,7 @@ func createTables() error {
 		`CREATE TABLE IF NOT EXISTS review_replies (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			review_id INTEGER NOT NULL,
+			parent_id INTEGER DEFAULT 0,
 			user_id INTEGER NOT NULL,
 			content TEXT NOT NULL,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
@@ -148,9 +159,65 @@ func createTables() error {
 	return nil
 }
 
-func hashPassword(password string) string {
-	hash := sha256.Sum256([]byte(password))
-	return fmt.Sprintf("%x", hash)
+// migrateTables 为旧版本数据库补充新增的列和索引
+func migrateTables() error {
+	columns := []struct {
+		table, column, definition string
+	}{
+		{"reviews", "order_item_id", "INTEGER"},
+		{"review_replies", "parent_id", "INTEGER DEFAULT 0"},
+		{"order_items", "tracking_number", "TEXT"},
+		{"order_items", "shipped_at", "TIMESTAMP"},
+		{"orders", "idempotency_key", "TEXT"},
+	}
+	for _, c := range columns {
+		if err := addColumnIfNotExists(c.table, c.column, c.definition); err != nil {
+			return fmt.Errorf("升级%s表失败: %v", c.table, err)
+		}
+	}
+	indexes := []string{
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_orders_idempotency ON orders(user_id, idempotency_key)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_reviews_order_item ON reviews(order_item_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_reviews_product ON reviews(product_id, status)`,
+		`CREATE INDEX IF NOT EXISTS idx_review_replies_review ON review_replies(review_id)`,
+	}
+	for _, query := range indexes {
+		if _, err := DB.Exec(query); err != nil {
+			return fmt.Errorf("创建索引失败: %v", err)
+		}
+	}
+	return nil
+}
+
+func addColumnIfNotExists(table, column, definition string) error {
+	rows, err := DB.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	_, err = DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// IsUniqueViolation 判断错误是否为违反唯一约束，用于把并发重复写入转换为业务错误
+func IsUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
 }
 
 func insertInitialData() error {
@@ -170,9 +237,13 @@ func insertInitialData() error {
 		{"customer1", "customer123", "customer1@ecommerce.com", "customer"},
 	}
 	for _, u := range users {
-		_, err := DB.Exec(
+		hashedPassword, err := password.Hash(u.password)
+		if err != nil {
+			return err
+		}
+		_, err = DB.Exec(
 			"INSERT INTO users (username, password, email, role) VALUES (?, ?, ?, ?)",
-			u.username, hashPassword(u.password), u.email, u.role,
+			u.username, hashedPassword, u.email, u.role,
 		)
 		if err != nil {
 			return err
@@ -11,10 +11,9 @@ import (
 )
 
 func SellerGetProducts(w http.ResponseWriter, r *http.Request) {
-	cookie, _ := r.Cookie("session_id")
-	session := middleware.Sessions[cookie.Value]
-	userID := session["user_id"].(int)
-	role := session["role"].(string)
+	session := middleware.CurrentSession(r)
+	userID := session.UserID
+	role := session.Role
 	var query string
 	var args []interface{}
 	if role == "seller" {
@@ -45,9 +44,7 @@ func SellerGetProducts(w http.ResponseWriter, r *http.Request) {
 }
 
 func SellerAddProduct(w http.ResponseWriter, r *http.Request) {
-	cookie, _ := r.Cookie("session_id")
-	session := middleware.Sessions[cookie.Value]
-	sellerID := session["user_id"].(int)
+	sellerID := middleware.CurrentSession(r).UserID
 	var product models.Product
 	json.NewDecoder(r.Body).Decode(&product)
 	if product.ImageURL == "" {
@@ -68,9 +65,7 @@ func SellerAddProduct(w http.ResponseWriter, r *http.Request) {
 }
 
 func SellerUpdateProduct(w http.ResponseWriter, r *http.Request) {
-	cookie, _ := r.Cookie("session_id")
-	session := middleware.Sessions[cookie.Value]
-	sellerID := session["user_id"].(int)
+	sellerID := middleware.CurrentSession(r).UserID
 	productID := r.URL.Query().Get("id")
 	var product models.Product
 	json.NewDecoder(r.Body).Decode(&product)
@@ -90,9 +85,7 @@ func SellerUpdateProduct(w http.ResponseWriter, r *http.Request) {
 }
 
 func SellerDeleteProduct(w http.ResponseWriter, r *http.Request) {
-	cookie, _ := r.Cookie("session_id")
-	session := middleware.Sessions[cookie.Value]
-	sellerID := session["user_id"].(int)
+	sellerID := middleware.CurrentSession(r).UserID
 	productID := r.URL.Query().Get("id")
 	_, err := database.DB.Exec("DELETE FROM products WHERE id = ? AND seller_id = ?", productID, sellerID)
 	if err != nil {
@@ -106,13 +99,11 @@ func SellerDeleteProduct(w http.ResponseWriter, r *http.Request) {
 }
 
 func SellerGetOrders(w http.ResponseWriter, r *http.Request) {
-	cookie, _ := r.Cookie("session_id")
-	session := middleware.Sessions[cookie.Value]
-	sellerID := session["user_id"].(int)
+	sellerID := middleware.CurrentSession(r).UserID
 	rows, err := database.DB.Query(`
 		SELECT DISTINCT o.id, o.order_no, o.user_id, o.total_amount, o.status, o.payment_method,
 			   o.shipping_address, o.receiver_name, o.receiver_phone, o.shipping_method,
-			   o.tracking_number, o.created_at, o.paid_at, o.shipped_at, o.completed_at
+			   COALESCE(o.tracking_number, ''), o.created_at, o.paid_at, o.shipped_at, o.completed_at
 		FROM orders o
 		JOIN order_items oi ON o.id = oi.order_id
 		JOIN products p ON oi.product_id = p.id
@@ -131,14 +122,16 @@ func SellerGetOrders(w http.ResponseWriter, r *http.Request) {
 			&o.ShippingAddress, &o.ReceiverName, &o.ReceiverPhone, &o.ShippingMethod,
 			&o.TrackingNumber, &o.CreatedAt, &o.PaidAt, &o.ShippedAt, &o.CompletedAt)
 		itemRows, _ := database.DB.Query(`
-			SELECT oi.id, oi.order_id, oi.product_id, oi.product_name, oi.product_price, oi.quantity, oi.subtotal
+			SELECT oi.id, oi.order_id, oi.product_id, oi.product_name, oi.product_price, oi.quantity, oi.subtotal,
+				   COALESCE(oi.tracking_number, ''), oi.shipped_at
 			FROM order_items oi
 			JOIN products p ON oi.product_id = p.id
 			WHERE oi.order_id = ? AND p.seller_id = ?
 		`, o.ID, sellerID)
 		for itemRows.Next() {
 			var item models.OrderItem
-			itemRows.Scan(&item.ID, &item.OrderID, &item.ProductID, &item.ProductName, &item.ProductPrice, &item.Quantity, &item.Subtotal)
+			itemRows.Scan(&item.ID, &item.OrderID, &item.ProductID, &item.ProductName, &item.ProductPrice, &item.Quantity, &item.Subtotal,
+				&item.TrackingNumber, &item.ShippedAt)
 			o.Items = append(o.Items, item)
 		}
 		itemRows.Close()
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"database/sql"
+	"ecommerce/internal/database"
+	"ecommerce/internal/middleware"
+	"ecommerce/internal/models"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var errInvalidTransition = errors.New("订单状态不正确")
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// transitionOrder 在状态守卫下更新订单状态，set 为附加的赋值子句（以逗号开头），
+// 订单当前状态不能流转到 to 时返回 errInvalidTransition
+func transitionOrder(db execer, orderID interface{}, to string, set string, args ...interface{}) error {
+	sources := models.TransitionSources(to)
+	if len(sources) == 0 {
+		return errInvalidTransition
+	}
+	placeholders := strings.Trim(strings.Repeat("?,", len(sources)), ",")
+	query := "UPDATE orders SET status = ?" + set + " WHERE id = ? AND status IN (" + placeholders + ")"
+	params := append([]interface{}{to}, args...)
+	params = append(params, orderID)
+	for _, s := range sources {
+		params = append(params, s)
+	}
+	result, err := db.Exec(query, params...)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errInvalidTransition
+	}
+	return nil
+}
+
+// SellerShipOrder 商家发货。多商家订单中每个商家只发自己的商品，
+// 全部商品发货后订单变为已发货，否则为部分发货
+func SellerShipOrder(w http.ResponseWriter, r *http.Request) {
+	sellerID := middleware.CurrentSession(r).UserID
+	orderID := r.URL.Query().Get("id")
+	var req struct {
+		TrackingNumber string `json:"tracking_number"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		middleware.JSONError(w, "请求格式错误", http.StatusBadRequest)
+		return
+	}
+	req.TrackingNumber = strings.TrimSpace(req.TrackingNumber)
+	if req.TrackingNumber == "" {
+		middleware.JSONError(w, "请填写物流单号", http.StatusBadRequest)
+		return
+	}
+	tx, err := database.DB.Begin()
+	if err != nil {
+		middleware.JSONError(w, "数据库错误", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	var total, unshipped int
+	err = tx.QueryRow(`
+		SELECT COUNT(*), COUNT(CASE WHEN oi.shipped_at IS NULL THEN 1 END)
+		FROM order_items oi
+		JOIN products p ON oi.product_id = p.id
+		WHERE oi.order_id = ? AND p.seller_id = ?
+	`, orderID, sellerID).Scan(&total, &unshipped)
+	if err != nil {
+		middleware.JSONError(w, "数据库错误", http.StatusInternalServerError)
+		return
+	}
+	if total == 0 {
+		middleware.JSONError(w, "订单不存在", http.StatusNotFound)
+		return
+	}
+	if unshipped == 0 {
+		middleware.JSONError(w, "商品已发货", http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	_, err = tx.Exec(`
+		UPDATE order_items SET tracking_number = ?, shipped_at = ?
+		WHERE order_id = ? AND shipped_at IS NULL
+		AND product_id IN (SELECT id FROM products WHERE seller_id = ?)
+	`, req.TrackingNumber, now, orderID, sellerID)
+	if err != nil {
+		middleware.JSONError(w, "发货失败", http.StatusInternalServerError)
+		return
+	}
+	var remaining int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM order_items WHERE order_id = ? AND shipped_at IS NULL", orderID).Scan(&remaining); err != nil {
+		middleware.JSONError(w, "数据库错误", http.StatusInternalServerError)
+		return
+	}
+	status := models.OrderShipped
+	if remaining > 0 {
+		status = models.OrderPartiallyShipped
+	}
+	// 订单上汇总所有包裹的物流单号
+	err = transitionOrder(tx, orderID, status, `,
+		tracking_number = CASE WHEN COALESCE(tracking_number, '') = '' THEN ? ELSE tracking_number || ',' || ? END,
+		shipped_at = COALESCE(shipped_at, ?)`, req.TrackingNumber, req.TrackingNumber, now)
+	if err == errInvalidTransition {
+		middleware.JSONError(w, "订单当前状态不能发货", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		middleware.JSONError(w, "发货失败", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		middleware.JSONError(w, "发货失败", http.StatusInternalServerError)
+		return
+	}
+	middleware.JSON(w, map[string]interface{}{
+		"success": true,
+		"message": "发货成功",
+		"status":  status,
+	})
+}
+
+// ConfirmReceipt 买家确认收货，订单全部发货后才能确认
+func ConfirmReceipt(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.CurrentSession(r).UserID
+	orderID := r.URL.Query().Get("id")
+	var status string
+	err := database.DB.QueryRow("SELECT status FROM orders WHERE id = ? AND user_id = ?", orderID, userID).Scan(&status)
+	if err == sql.ErrNoRows {
+		middleware.JSONError(w, "订单不存在", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		middleware.JSONError(w, "数据库错误", http.StatusInternalServerError)
+		return
+	}
+	if status == models.OrderPartiallyShipped {
+		middleware.JSONError(w, "订单还有商品未发货", http.StatusBadRequest)
+		return
+	}
+	err = transitionOrder(database.DB, orderID, models.OrderCompleted, ", completed_at = ?", time.Now())
+	if err == errInvalidTransition {
+		middleware.JSONError(w, "订单状态不正确", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		middleware.JSONError(w, "操作失败", http.StatusInternalServerError)
+		return
+	}
+	middleware.JSON(w, map[string]interface{}{
+		"success": true,
+		"message": "已确认收货",
+	})
+}
@@ -1,13 +1,14 @@
 package handlers
 
 import (
-	"crypto/sha256"
 	"database/sql"
 	"encoding/json"
 	"ecommerce/internal/database"
 	"ecommerce/internal/middleware"
 	"ecommerce/internal/models"
+	"ecommerce/internal/password"
 	"fmt"
+	"log"
 	"math/rand"
 	"net/http"
 	"strconv"
@@ -30,6 +31,10 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		middleware.JSONError(w, "请填写完整信息", http.StatusBadRequest)
 		return
 	}
+	if err := password.Validate(req.Password, req.Username); err != nil {
+		middleware.JSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	var exists int
 	err := database.DB.QueryRow("SELECT COUNT(*) FROM users WHERE username = ?", req.Username).Scan(&exists)
 	if err != nil {
@@ -49,8 +54,11 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		middleware.JSONError(w, "邮箱已被注册", http.StatusBadRequest)
 		return
 	}
-	hash := sha256.Sum256([]byte(req.Password))
-	hashedPassword := fmt.Sprintf("%x", hash)
+	hashedPassword, err := password.Hash(req.Password)
+	if err != nil {
+		middleware.JSONError(w, "注册失败", http.StatusInternalServerError)
+		return
+	}
 	_, err = database.DB.Exec(
 		"INSERT INTO users (username, password, email, phone, role) VALUES (?, ?, ?, ?, 'customer')",
 		req.Username, hashedPassword, req.Email, req.Phone,
@@ -74,38 +82,40 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		middleware.JSONError(w, "请求格式错误", http.StatusBadRequest)
 		return
 	}
-	hash := sha256.Sum256([]byte(req.Password))
-	hashedPassword := fmt.Sprintf("%x", hash)
 	var user models.User
 	err := database.DB.QueryRow(
-		"SELECT id, username, email, role, status FROM users WHERE username = ? AND password = ?",
-		req.Username, hashedPassword,
-	).Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.Status)
-	if err == sql.ErrNoRows {
-		middleware.JSONError(w, "用户名或密码错误", http.StatusUnauthorized)
+		"SELECT id, username, password, email, role, status FROM users WHERE username = ?",
+		req.Username,
+	).Scan(&user.ID, &user.Username, &user.Password, &user.Email, &user.Role, &user.Status)
+	if err != nil && err != sql.ErrNoRows {
+		middleware.JSONError(w, "数据库错误", http.StatusInternalServerError)
 		return
 	}
-	if err != nil {
-		middleware.JSONError(w, "数据库错误", http.StatusInternalServerError)
+	// 用户不存在时 user.Password 为空，Verify 仍会执行一次 bcrypt 比较
+	ok, needsUpgrade := password.Verify(user.Password, req.Password)
+	if err == sql.ErrNoRows || !ok {
+		middleware.JSONError(w, "用户名或密码错误", http.StatusUnauthorized)
 		return
 	}
+	if needsUpgrade {
+		// 旧版本的 SHA-256 哈希在登录成功后透明升级为 bcrypt
+		if upgraded, err := password.Hash(req.Password); err == nil {
+			if _, err := database.DB.Exec("UPDATE users SET password = ? WHERE id = ? AND password = ?",
+				upgraded, user.ID, user.Password); err != nil {
+				log.Printf("升级用户 %d 的密码哈希失败: %v", user.ID, err)
+			}
+		}
+	}
 	if user.Status != "active" {
 		middleware.JSONError(w, "账户已被冻结", http.StatusForbidden)
 		return
 	}
-	sessionID := fmt.Sprintf("%d-%d", user.ID, time.Now().Unix())
-	middleware.Sessions[sessionID] = map[string]interface{}{
-		"user_id":  user.ID,
-		"username": user.Username,
-		"role":     user.Role,
+	session, err := middleware.Sessions.Create(user.ID, user.Username, user.Role)
+	if err != nil {
+		middleware.JSONError(w, "登录失败", http.StatusInternalServerError)
+		return
 	}
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
-		Value:    sessionID,
-		Path:     "/",
-		MaxAge:   86400 * 7,
-		HttpOnly: true,
-	})
+	middleware.SetSessionCookie(w, session)
 	middleware.JSON(w, map[string]interface{}{
 		"success": true,
 		"message": "登录成功",
@@ -119,36 +129,85 @@ func Login(w http.ResponseWriter, r *http.Request) {
 }
 
 func Logout(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie("session_id")
+	cookie, err := r.Cookie(middleware.SessionCookieName)
 	if err == nil {
-		delete(middleware.Sessions, cookie.Value)
+		middleware.Sessions.Delete(cookie.Value)
 	}
-	http.SetCookie(w, &http.Cookie{
-		Name:   "session_id",
-		Value:  "",
-		Path:   "/",
-		MaxAge: -1,
-	})
+	middleware.ClearSessionCookie(w)
 	middleware.JSON(w, map[string]interface{}{
 		"success": true,
 		"message": "已退出登录",
 	})
 }
 
-func CurrentUser(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie("session_id")
+func ChangePassword(w http.ResponseWriter, r *http.Request) {
+	session := middleware.CurrentSession(r)
+	var req struct {
+		OldPassword string `json:"old_password"`
+		NewPassword string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		middleware.JSONError(w, "请求格式错误", http.StatusBadRequest)
+		return
+	}
+	var current string
+	err := database.DB.QueryRow("SELECT password FROM users WHERE id = ?", session.UserID).Scan(&current)
+	if err == sql.ErrNoRows {
+		middleware.JSONError(w, "用户不存在", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		middleware.JSONError(w, "数据库错误", http.StatusInternalServerError)
+		return
+	}
+	if ok, _ := password.Verify(current, req.OldPassword); !ok {
+		middleware.JSONError(w, "原密码错误", http.StatusBadRequest)
+		return
+	}
+	if req.NewPassword == req.OldPassword {
+		middleware.JSONError(w, "新密码不能与原密码相同", http.StatusBadRequest)
+		return
+	}
+	if err := password.Validate(req.NewPassword, session.Username); err != nil {
+		middleware.JSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hashedPassword, err := password.Hash(req.NewPassword)
+	if err != nil {
+		middleware.JSONError(w, "修改失败", http.StatusInternalServerError)
+		return
+	}
+	if _, err := database.DB.Exec("UPDATE users SET password = ? WHERE id = ?", hashedPassword, session.UserID); err != nil {
+		middleware.JSONError(w, "修改失败", http.StatusInternalServerError)
+		return
+	}
+	// 修改密码后其他设备上的会话全部失效，当前设备换发新会话
+	middleware.Sessions.DeleteUser(session.UserID)
+	newSession, err := middleware.Sessions.Create(session.UserID, session.Username, session.Role)
 	if err != nil {
+		middleware.ClearSessionCookie(w)
+	} else {
+		middleware.SetSessionCookie(w, newSession)
+	}
+	middleware.JSON(w, map[string]interface{}{
+		"success": true,
+		"message": "密码修改成功",
+	})
+}
+
+func CurrentUser(w http.ResponseWriter, r *http.Request) {
+	if _, err := r.Cookie(middleware.SessionCookieName); err != nil {
 		middleware.JSONError(w, "未登录", http.StatusUnauthorized)
 		return
 	}
-	session, ok := middleware.Sessions[cookie.Value]
-	if !ok {
+	session := middleware.LookupSession(w, r)
+	if session == nil {
 		middleware.JSONError(w, "会话已过期", http.StatusUnauthorized)
 		return
 	}
-	userID := session["user_id"].(int)
+	userID := session.UserID
 	var user models.User
-	err = database.DB.QueryRow(
+	err := database.DB.QueryRow(
 		"SELECT id, username, email, phone, role FROM users WHERE id = ?",
 		userID,
 	).Scan(&user.ID, &user.Username, &user.Email, &user.Phone, &user.Role)
@@ -235,26 +294,24 @@ func GetProductDetail(w http.ResponseWriter, r *http.Request) {
 		middleware.JSONError(w, "数据库错误", http.StatusInternalServerError)
 		return
 	}
-	rows, err := database.DB.Query(`
-		SELECT r.id, r.user_id, r.product_id, r.order_id, r.rating, r.comment, r.status, r.created_at, u.username
-		FROM reviews r
-		JOIN users u ON r.user_id = u.id
-		WHERE r.product_id = ? AND r.status = 'approved'
-		ORDER BY r.created_at DESC
-	`, productID)
-	var reviews []models.Review
+	reviews, err := queryReviews(`WHERE r.product_id = ? AND r.status = 'approved'`, []interface{}{product.ID}, 10, 0)
 	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var r models.Review
-			rows.Scan(&r.ID, &r.UserID, &r.ProductID, &r.OrderID, &r.Rating, &r.Comment, &r.Status, &r.CreatedAt, &r.Username)
-			reviews = append(reviews, r)
-		}
+		err = loadReviewReplies(reviews)
+	}
+	if err != nil {
+		middleware.JSONError(w, "数据库错误", http.StatusInternalServerError)
+		return
+	}
+	summary, err := getRatingSummary(product.ID)
+	if err != nil {
+		middleware.JSONError(w, "数据库错误", http.StatusInternalServerError)
+		return
 	}
 	middleware.JSON(w, map[string]interface{}{
 		"success": true,
 		"product": product,
 		"reviews": reviews,
+		"rating":  summary,
 	})
 }
 
@@ -278,9 +335,7 @@ func GetCategories(w http.ResponseWriter, r *http.Request) {
 }
 
 func GetCart(w http.ResponseWriter, r *http.Request) {
-	cookie, _ := r.Cookie("session_id")
-	session := middleware.Sessions[cookie.Value]
-	userID := session["user_id"].(int)
+	userID := middleware.CurrentSession(r).UserID
 	rows, err := database.DB.Query(`
 		SELECT c.id, c.user_id, c.product_id, c.quantity, c.created_at,
 			   p.name, p.price, p.stock, p.image_url
@@ -307,9 +362,7 @@ func GetCart(w http.ResponseWriter, r *http.Request) {
 }
 
 func AddToCart(w http.ResponseWriter, r *http.Request) {
-	cookie, _ := r.Cookie("session_id")
-	session := middleware.Sessions[cookie.Value]
-	userID := session["user_id"].(int)
+	userID := middleware.CurrentSession(r).UserID
 	var req struct {
 		ProductID int `json:"product_id"`
 		Quantity  int `json:"quantity"`
@@ -360,9 +413,7 @@ func AddToCart(w http.ResponseWriter, r *http.Request) {
 }
 
 func UpdateCart(w http.ResponseWriter, r *http.Request) {
-	cookie, _ := r.Cookie("session_id")
-	session := middleware.Sessions[cookie.Value]
-	userID := session["user_id"].(int)
+	userID := middleware.CurrentSession(r).UserID
 	var req struct {
 		CartItemID int `json:"cart_item_id"`
 		Quantity   int `json:"quantity"`
@@ -401,9 +452,7 @@ func UpdateCart(w http.ResponseWriter, r *http.Request) {
 }
 
 func RemoveFromCart(w http.ResponseWriter, r *http.Request) {
-	cookie, _ := r.Cookie("session_id")
-	session := middleware.Sessions[cookie.Value]
-	userID := session["user_id"].(int)
+	userID := middleware.CurrentSession(r).UserID
 	var req struct {
 		CartItemID int `json:"cart_item_id"`
 	}
@@ -422,18 +471,50 @@ func RemoveFromCart(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// CreateOrder 在单个事务中完成下单：写入订单和明细、扣减库存、清理购物车，任一步失败整体回滚。
+// 客户端可通过 Idempotency-Key 请求头或 idempotency_key 字段传入幂等键，同一用户重复提交相同的键只会创建一个订单
 func CreateOrder(w http.ResponseWriter, r *http.Request) {
-	cookie, _ := r.Cookie("session_id")
-	session := middleware.Sessions[cookie.Value]
-	userID := session["user_id"].(int)
+	userID := middleware.CurrentSession(r).UserID
 	var req struct {
-		CartItemIDs   []int  `json:"cart_item_ids"`
-		AddressID     int    `json:"address_id"`
-		PaymentMethod string `json:"payment_method"`
+		CartItemIDs    []int  `json:"cart_item_ids"`
+		AddressID      int    `json:"address_id"`
+		PaymentMethod  string `json:"payment_method"`
+		IdempotencyKey string `json:"idempotency_key"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		middleware.JSONError(w, "请求格式错误", http.StatusBadRequest)
+		return
+	}
+	idempotencyKey := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	if idempotencyKey == "" {
+		idempotencyKey = strings.TrimSpace(req.IdempotencyKey)
+	}
+	if len(idempotencyKey) > 64 {
+		middleware.JSONError(w, "幂等键过长", http.StatusBadRequest)
+		return
+	}
+	tx, err := database.DB.Begin()
+	if err != nil {
+		middleware.JSONError(w, "数据库错误", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	// 在事务内查询幂等键，等待写锁的重复请求能看到先提交的订单
+	if idempotencyKey != "" {
+		if orderID, orderNo, ok, err := findOrderByIdempotencyKey(tx, userID, idempotencyKey); err != nil {
+			middleware.JSONError(w, "数据库错误", http.StatusInternalServerError)
+			return
+		} else if ok {
+			writeOrderCreated(w, orderID, orderNo)
+			return
+		}
+	}
+	if len(req.CartItemIDs) == 0 {
+		middleware.JSONError(w, "请选择要结算的商品", http.StatusBadRequest)
+		return
 	}
-	json.NewDecoder(r.Body).Decode(&req)
 	var addr models.Address
-	err := database.DB.QueryRow(
+	err = tx.QueryRow(
 		"SELECT receiver_name, phone, province, city, district, detail_address FROM addresses WHERE id = ? AND user_id = ?",
 		req.AddressID, userID,
 	).Scan(&addr.ReceiverName, &addr.Phone, &addr.Province, &addr.City, &addr.District, &addr.DetailAddress)
@@ -441,6 +522,10 @@ func CreateOrder(w http.ResponseWriter, r *http.Request) {
 		middleware.JSONError(w, "地址不存在", http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		middleware.JSONError(w, "数据库错误", http.StatusInternalServerError)
+		return
+	}
 	placeholders := strings.Trim(strings.Repeat("?,", len(req.CartItemIDs)), ",")
 	args := make([]interface{}, len(req.CartItemIDs)+1)
 	args[0] = userID
@@ -448,61 +533,123 @@ func CreateOrder(w http.ResponseWriter, r *http.Request) {
 		args[i+1] = id
 	}
 	query := fmt.Sprintf(`
-		SELECT c.id, c.product_id, p.name, p.price, p.stock, c.quantity
+		SELECT c.id, c.product_id, p.name, p.price, c.quantity
 		FROM cart_items c
 		JOIN products p ON c.product_id = p.id
 		WHERE c.user_id = ? AND c.id IN (%s)
 	`, placeholders)
-	rows, err := database.DB.Query(query, args...)
+	rows, err := tx.Query(query, args...)
 	if err != nil {
 		middleware.JSONError(w, "数据库错误", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 	type cartItem struct {
 		ID        int
 		ProductID int
 		Name      string
 		Price     float64
-		Stock     int
 		Quantity  int
 	}
 	var items []cartItem
 	var totalAmount float64
 	for rows.Next() {
 		var item cartItem
-		rows.Scan(&item.ID, &item.ProductID, &item.Name, &item.Price, &item.Stock, &item.Quantity)
-		if item.Stock < item.Quantity {
-			middleware.JSONError(w, fmt.Sprintf("%s 库存不足", item.Name), http.StatusBadRequest)
+		if err := rows.Scan(&item.ID, &item.ProductID, &item.Name, &item.Price, &item.Quantity); err != nil {
+			rows.Close()
+			middleware.JSONError(w, "数据库错误", http.StatusInternalServerError)
 			return
 		}
 		totalAmount += item.Price * float64(item.Quantity)
 		items = append(items, item)
 	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		middleware.JSONError(w, "数据库错误", http.StatusInternalServerError)
+		return
+	}
 	if len(items) == 0 {
 		middleware.JSONError(w, "购物车为空", http.StatusBadRequest)
 		return
 	}
+	// 库存在扣减语句中校验，避免先查后扣之间被其他订单抢占
+	for _, item := range items {
+		result, err := tx.Exec(
+			"UPDATE products SET stock = stock - ? WHERE id = ? AND stock >= ?",
+			item.Quantity, item.ProductID, item.Quantity,
+		)
+		if err != nil {
+			middleware.JSONError(w, "创建订单失败", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			middleware.JSONError(w, fmt.Sprintf("%s 库存不足", item.Name), http.StatusBadRequest)
+			return
+		}
+	}
+	var key interface{}
+	if idempotencyKey != "" {
+		key = idempotencyKey
+	}
 	orderNo := fmt.Sprintf("ORD%s%04d", time.Now().Format("20060102150405"), rand.Intn(10000))
 	shippingAddress := fmt.Sprintf("%s %s %s %s", addr.Province, addr.City, addr.District, addr.DetailAddress)
-	result, err := database.DB.Exec(`
-		INSERT INTO orders (order_no, user_id, total_amount, status, payment_method, shipping_address, receiver_name, receiver_phone)
-		VALUES (?, ?, ?, 'pending_payment', ?, ?, ?, ?)
-	`, orderNo, userID, totalAmount, req.PaymentMethod, shippingAddress, addr.ReceiverName, addr.Phone)
+	result, err := tx.Exec(`
+		INSERT INTO orders (order_no, user_id, total_amount, status, payment_method, shipping_address, receiver_name, receiver_phone, idempotency_key)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, orderNo, userID, totalAmount, models.OrderPendingPayment, req.PaymentMethod, shippingAddress, addr.ReceiverName, addr.Phone, key)
+	if err != nil {
+		tx.Rollback()
+		// 并发提交相同幂等键时唯一索引冲突，返回先创建的订单
+		if idempotencyKey != "" {
+			if orderID, orderNo, ok, _ := findOrderByIdempotencyKey(database.DB, userID, idempotencyKey); ok {
+				writeOrderCreated(w, orderID, orderNo)
+				return
+			}
+		}
+		middleware.JSONError(w, "创建订单失败", http.StatusInternalServerError)
+		return
+	}
+	orderID, err := result.LastInsertId()
 	if err != nil {
 		middleware.JSONError(w, "创建订单失败", http.StatusInternalServerError)
 		return
 	}
-	orderID, _ := result.LastInsertId()
 	for _, item := range items {
 		subtotal := item.Price * float64(item.Quantity)
-		database.DB.Exec(
+		_, err := tx.Exec(
 			"INSERT INTO order_items (order_id, product_id, product_name, product_price, quantity, subtotal) VALUES (?, ?, ?, ?, ?, ?)",
 			orderID, item.ProductID, item.Name, item.Price, item.Quantity, subtotal,
 		)
-		database.DB.Exec("UPDATE products SET stock = stock - ? WHERE id = ?", item.Quantity, item.ProductID)
+		if err != nil {
+			middleware.JSONError(w, "创建订单失败", http.StatusInternalServerError)
+			return
+		}
+	}
+	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM cart_items WHERE user_id = ? AND id IN (%s)", placeholders), args...); err != nil {
+		middleware.JSONError(w, "创建订单失败", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		middleware.JSONError(w, "创建订单失败", http.StatusInternalServerError)
+		return
+	}
+	writeOrderCreated(w, orderID, orderNo)
+}
+
+func findOrderByIdempotencyKey(db queryRower, userID int, key string) (orderID int64, orderNo string, ok bool, err error) {
+	err = db.QueryRow(
+		"SELECT id, order_no FROM orders WHERE user_id = ? AND idempotency_key = ?",
+		userID, key,
+	).Scan(&orderID, &orderNo)
+	if err == sql.ErrNoRows {
+		return 0, "", false, nil
 	}
-	database.DB.Exec(fmt.Sprintf("DELETE FROM cart_items WHERE user_id = ? AND id IN (%s)", placeholders), args...)
+	if err != nil {
+		return 0, "", false, err
+	}
+	return orderID, orderNo, true, nil
+}
+
+func writeOrderCreated(w http.ResponseWriter, orderID int64, orderNo string) {
 	middleware.JSON(w, map[string]interface{}{
 		"success":  true,
 		"message":  "订单创建成功",
@@ -512,12 +659,10 @@ func CreateOrder(w http.ResponseWriter, r *http.Request) {
 }
 
 func GetOrders(w http.ResponseWriter, r *http.Request) {
-	cookie, _ := r.Cookie("session_id")
-	session := middleware.Sessions[cookie.Value]
-	userID := session["user_id"].(int)
+	userID := middleware.CurrentSession(r).UserID
 	rows, err := database.DB.Query(`
 		SELECT id, order_no, user_id, total_amount, status, payment_method, shipping_address, 
-			   receiver_name, receiver_phone, shipping_method, tracking_number, created_at, paid_at, shipped_at, completed_at
+			   receiver_name, receiver_phone, shipping_method, COALESCE(tracking_number, ''), created_at, paid_at, shipped_at, completed_at
 		FROM orders
 		WHERE user_id = ?
 		ORDER BY created_at DESC
@@ -534,12 +679,13 @@ func GetOrders(w http.ResponseWriter, r *http.Request) {
 			&o.ShippingAddress, &o.ReceiverName, &o.ReceiverPhone, &o.ShippingMethod,
 			&o.TrackingNumber, &o.CreatedAt, &o.PaidAt, &o.ShippedAt, &o.CompletedAt)
 		itemRows, _ := database.DB.Query(
-			"SELECT id, order_id, product_id, product_name, product_price, quantity, subtotal FROM order_items WHERE order_id = ?",
+			"SELECT id, order_id, product_id, product_name, product_price, quantity, subtotal, COALESCE(tracking_number, ''), shipped_at FROM order_items WHERE order_id = ?",
 			o.ID,
 		)
 		for itemRows.Next() {
 			var item models.OrderItem
-			itemRows.Scan(&item.ID, &item.OrderID, &item.ProductID, &item.ProductName, &item.ProductPrice, &item.Quantity, &item.Subtotal)
+			itemRows.Scan(&item.ID, &item.OrderID, &item.ProductID, &item.ProductName, &item.ProductPrice, &item.Quantity, &item.Subtotal,
+				&item.TrackingNumber, &item.ShippedAt)
 			o.Items = append(o.Items, item)
 		}
 		itemRows.Close()
@@ -552,9 +698,7 @@ func GetOrders(w http.ResponseWriter, r *http.Request) {
 }
 
 func PayOrder(w http.ResponseWriter, r *http.Request) {
-	cookie, _ := r.Cookie("session_id")
-	session := middleware.Sessions[cookie.Value]
-	userID := session["user_id"].(int)
+	userID := middleware.CurrentSession(r).UserID
 	orderID := r.URL.Query().Get("id")
 	var status string
 	err := database.DB.QueryRow("SELECT status FROM orders WHERE id = ? AND user_id = ?", orderID, userID).Scan(&status)
@@ -562,15 +706,15 @@ func PayOrder(w http.ResponseWriter, r *http.Request) {
 		middleware.JSONError(w, "订单不存在", http.StatusNotFound)
 		return
 	}
-	if status != "pending_payment" {
+	if !models.CanTransition(status, models.OrderPendingShipment) {
+		middleware.JSONError(w, "订单状态不正确", http.StatusBadRequest)
+		return
+	}
+	err = transitionOrder(database.DB, orderID, models.OrderPendingShipment, ", paid_at = ?", time.Now())
+	if err == errInvalidTransition {
 		middleware.JSONError(w, "订单状态不正确", http.StatusBadRequest)
 		return
 	}
-	now := time.Now()
-	_, err = database.DB.Exec(
-		"UPDATE orders SET status = 'pending_shipment', paid_at = ? WHERE id = ?",
-		now, orderID,
-	)
 	if err != nil {
 		middleware.JSONError(w, "支付失败", http.StatusInternalServerError)
 		return
@@ -582,9 +726,7 @@ func PayOrder(w http.ResponseWriter, r *http.Request) {
 }
 
 func CancelOrder(w http.ResponseWriter, r *http.Request) {
-	cookie, _ := r.Cookie("session_id")
-	session := middleware.Sessions[cookie.Value]
-	userID := session["user_id"].(int)
+	userID := middleware.CurrentSession(r).UserID
 	orderID := r.URL.Query().Get("id")
 	var status string
 	err := database.DB.QueryRow("SELECT status FROM orders WHERE id = ? AND user_id = ?", orderID, userID).Scan(&status)
@@ -592,22 +734,40 @@ func CancelOrder(w http.ResponseWriter, r *http.Request) {
 		middleware.JSONError(w, "订单不存在", http.StatusNotFound)
 		return
 	}
-	if status != "pending_payment" && status != "pending_shipment" {
+	if !models.CanTransition(status, models.OrderCancelled) {
 		middleware.JSONError(w, "该订单不能取消", http.StatusBadRequest)
 		return
 	}
-	rows, _ := database.DB.Query("SELECT product_id, quantity FROM order_items WHERE order_id = ?", orderID)
-	for rows.Next() {
-		var productID, quantity int
-		rows.Scan(&productID, &quantity)
-		database.DB.Exec("UPDATE products SET stock = stock + ? WHERE id = ?", quantity, productID)
+	tx, err := database.DB.Begin()
+	if err != nil {
+		middleware.JSONError(w, "取消失败", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	// 先在状态守卫下取消订单，保证并发取消时库存只回补一次
+	err = transitionOrder(tx, orderID, models.OrderCancelled, "")
+	if err == errInvalidTransition {
+		middleware.JSONError(w, "该订单不能取消", http.StatusBadRequest)
+		return
 	}
-	rows.Close()
-	_, err = database.DB.Exec("UPDATE orders SET status = 'cancelled' WHERE id = ?", orderID)
 	if err != nil {
 		middleware.JSONError(w, "取消失败", http.StatusInternalServerError)
 		return
 	}
+	_, err = tx.Exec(`
+		UPDATE products SET stock = stock + (
+			SELECT SUM(quantity) FROM order_items WHERE order_id = ? AND product_id = products.id
+		)
+		WHERE id IN (SELECT product_id FROM order_items WHERE order_id = ?)
+	`, orderID, orderID)
+	if err != nil {
+		middleware.JSONError(w, "取消失败", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		middleware.JSONError(w, "取消失败", http.StatusInternalServerError)
+		return
+	}
 	middleware.JSON(w, map[string]interface{}{
 		"success": true,
 		"message": "订单已取消",
@@ -615,9 +775,7 @@ func CancelOrder(w http.ResponseWriter, r *http.Request) {
 }
 
 func GetAddresses(w http.ResponseWriter, r *http.Request) {
-	cookie, _ := r.Cookie("session_id")
-	session := middleware.Sessions[cookie.Value]
-	userID := session["user_id"].(int)
+	userID := middleware.CurrentSession(r).UserID
 	rows, err := database.DB.Query(`
 		SELECT id, user_id, receiver_name, phone, province, city, district, detail_address, is_default, created_at
 		FROM addresses
@@ -644,9 +802,7 @@ func GetAddresses(w http.ResponseWriter, r *http.Request) {
 }
 
 func AddAddress(w http.ResponseWriter, r *http.Request) {
-	cookie, _ := r.Cookie("session_id")
-	session := middleware.Sessions[cookie.Value]
-	userID := session["user_id"].(int)
+	userID := middleware.CurrentSession(r).UserID
 	var addr models.Address
 	json.NewDecoder(r.Body).Decode(&addr)
 	if addr.IsDefault {
@@ -671,9 +827,7 @@ func AddAddress(w http.ResponseWriter, r *http.Request) {
 }
 
 func DeleteAddress(w http.ResponseWriter, r *http.Request) {
-	cookie, _ := r.Cookie("session_id")
-	session := middleware.Sessions[cookie.Value]
-	userID := session["user_id"].(int)
+	userID := middleware.CurrentSession(r).UserID
 	addressID := r.URL.Query().Get("id")
 	_, err := database.DB.Exec("DELETE FROM addresses WHERE id = ? AND user_id = ?", addressID, userID)
 	if err != nil {
@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"database/sql"
+	"ecommerce/internal/database"
+	"ecommerce/internal/middleware"
+	"ecommerce/internal/models"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const maxReviewLength = 500
+
+func CreateReview(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.CurrentSession(r).UserID
+	var req struct {
+		OrderItemID int    `json:"order_item_id"`
+		Rating      int    `json:"rating"`
+		Comment     string `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		middleware.JSONError(w, "请求格式错误", http.StatusBadRequest)
+		return
+	}
+	req.Comment = strings.TrimSpace(req.Comment)
+	if req.Rating < 1 || req.Rating > 5 {
+		middleware.JSONError(w, "评分必须在1到5之间", http.StatusBadRequest)
+		return
+	}
+	if utf8.RuneCountInString(req.Comment) > maxReviewLength {
+		middleware.JSONError(w, fmt.Sprintf("评价内容不能超过%d字", maxReviewLength), http.StatusBadRequest)
+		return
+	}
+	var orderID, productID int
+	var status string
+	err := database.DB.QueryRow(`
+		SELECT oi.order_id, oi.product_id, o.status
+		FROM order_items oi
+		JOIN orders o ON oi.order_id = o.id
+		WHERE oi.id = ? AND o.user_id = ?
+	`, req.OrderItemID, userID).Scan(&orderID, &productID, &status)
+	if err == sql.ErrNoRows {
+		middleware.JSONError(w, "订单商品不存在", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		middleware.JSONError(w, "数据库错误", http.StatusInternalServerError)
+		return
+	}
+	if status != "completed" {
+		middleware.JSONError(w, "订单完成后才能评价", http.StatusBadRequest)
+		return
+	}
+	// 新评价进入待审核队列，审核通过后才对外展示。
+	// 每个订单商品只能评价一次，由 order_item_id 唯一索引保证，并发重复提交时插入失败
+	result, err := database.DB.Exec(`
+		INSERT INTO reviews (user_id, product_id, order_id, order_item_id, rating, comment, status)
+		VALUES (?, ?, ?, ?, ?, ?, 'pending')
+	`, userID, productID, orderID, req.OrderItemID, req.Rating, req.Comment)
+	if database.IsUniqueViolation(err) {
+		middleware.JSONError(w, "该商品已评价", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		middleware.JSONError(w, "评价失败", http.StatusInternalServerError)
+		return
+	}
+	reviewID, _ := result.LastInsertId()
+	middleware.JSON(w, map[string]interface{}{
+		"success":   true,
+		"message":   "评价已提交，审核通过后展示",
+		"review_id": reviewID,
+	})
+}
+
+func GetProductReviews(w http.ResponseWriter, r *http.Request) {
+	productID, _ := strconv.Atoi(r.URL.Query().Get("product_id"))
+	if productID <= 0 {
+		middleware.JSONError(w, "商品ID错误", http.StatusBadRequest)
+		return
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage := 10
+	summary, err := getRatingSummary(productID)
+	if err != nil {
+		middleware.JSONError(w, "数据库错误", http.StatusInternalServerError)
+		return
+	}
+	reviews, err := queryReviews(`WHERE r.product_id = ? AND r.status = 'approved'`,
+		[]interface{}{productID}, perPage, (page-1)*perPage)
+	if err != nil {
+		middleware.JSONError(w, "数据库错误", http.StatusInternalServerError)
+		return
+	}
+	if err := loadReviewReplies(reviews); err != nil {
+		middleware.JSONError(w, "数据库错误", http.StatusInternalServerError)
+		return
+	}
+	middleware.JSON(w, map[string]interface{}{
+		"success": true,
+		"reviews": reviews,
+		"rating":  summary,
+		"total":   summary.Count,
+		"page":    page,
+		"pages":   (summary.Count + perPage - 1) / perPage,
+	})
+}
+
+func ReplyReview(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.CurrentSession(r).UserID
+	var req struct {
+		ReviewID int    `json:"review_id"`
+		ParentID int    `json:"parent_id"`
+		Content  string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		middleware.JSONError(w, "请求格式错误", http.StatusBadRequest)
+		return
+	}
+	req.Content = strings.TrimSpace(req.Content)
+	if req.Content == "" {
+		middleware.JSONError(w, "回复内容不能为空", http.StatusBadRequest)
+		return
+	}
+	if utf8.RuneCountInString(req.Content) > maxReviewLength {
+		middleware.JSONError(w, fmt.Sprintf("回复内容不能超过%d字", maxReviewLength), http.StatusBadRequest)
+		return
+	}
+	var status string
+	err := database.DB.QueryRow("SELECT status FROM reviews WHERE id = ?", req.ReviewID).Scan(&status)
+	if err == sql.ErrNoRows || (err == nil && status != "approved") {
+		middleware.JSONError(w, "评价不存在", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		middleware.JSONError(w, "数据库错误", http.StatusInternalServerError)
+		return
+	}
+	if req.ParentID != 0 {
+		var parentReviewID int
+		err := database.DB.QueryRow("SELECT review_id FROM review_replies WHERE id = ?", req.ParentID).Scan(&parentReviewID)
+		if err == sql.ErrNoRows || (err == nil && parentReviewID != req.ReviewID) {
+			middleware.JSONError(w, "回复的内容不存在", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			middleware.JSONError(w, "数据库错误", http.StatusInternalServerError)
+			return
+		}
+	}
+	result, err := database.DB.Exec(
+		"INSERT INTO review_replies (review_id, parent_id, user_id, content) VALUES (?, ?, ?, ?)",
+		req.ReviewID, req.ParentID, userID, req.Content,
+	)
+	if err != nil {
+		middleware.JSONError(w, "回复失败", http.StatusInternalServerError)
+		return
+	}
+	replyID, _ := result.LastInsertId()
+	middleware.JSON(w, map[string]interface{}{
+		"success":  true,
+		"message":  "回复成功",
+		"reply_id": replyID,
+	})
+}
+
+func AdminGetReviews(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "pending"
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage := 20
+	var total int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM reviews WHERE status = ?", status).Scan(&total); err != nil {
+		middleware.JSONError(w, "数据库错误", http.StatusInternalServerError)
+		return
+	}
+	reviews, err := queryReviews(`WHERE r.status = ?`, []interface{}{status}, perPage, (page-1)*perPage)
+	if err != nil {
+		middleware.JSONError(w, "数据库错误", http.StatusInternalServerError)
+		return
+	}
+	middleware.JSON(w, map[string]interface{}{
+		"success": true,
+		"reviews": reviews,
+		"total":   total,
+		"page":    page,
+		"pages":   (total + perPage - 1) / perPage,
+	})
+}
+
+func AdminApproveReview(w http.ResponseWriter, r *http.Request) {
+	setReviewStatus(w, r, "approved", "评价已审核通过")
+}
+
+func AdminRejectReview(w http.ResponseWriter, r *http.Request) {
+	setReviewStatus(w, r, "rejected", "评价已拒绝")
+}
+
+func setReviewStatus(w http.ResponseWriter, r *http.Request, status, message string) {
+	reviewID := r.URL.Query().Get("id")
+	result, err := database.DB.Exec("UPDATE reviews SET status = ? WHERE id = ?", status, reviewID)
+	if err != nil {
+		middleware.JSONError(w, "操作失败", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		middleware.JSONError(w, "评价不存在", http.StatusNotFound)
+		return
+	}
+	middleware.JSON(w, map[string]interface{}{
+		"success": true,
+		"message": message,
+	})
+}
+
+// queryReviews 按条件查询评价列表，where 中的评价表别名为 r
+func queryReviews(where string, args []interface{}, limit, offset int) ([]models.Review, error) {
+	rows, err := database.DB.Query(`
+		SELECT r.id, r.user_id, r.product_id, r.order_id, COALESCE(r.order_item_id, 0), r.rating,
+			   COALESCE(r.comment, ''), r.status, r.created_at, u.username, p.name
+		FROM reviews r
+		JOIN users u ON r.user_id = u.id
+		JOIN products p ON r.product_id = p.id
+		`+where+`
+		ORDER BY r.created_at DESC, r.id DESC
+		LIMIT ? OFFSET ?
+	`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	reviews := []models.Review{}
+	for rows.Next() {
+		var rv models.Review
+		if err := rows.Scan(&rv.ID, &rv.UserID, &rv.ProductID, &rv.OrderID, &rv.OrderItemID, &rv.Rating,
+			&rv.Comment, &rv.Status, &rv.CreatedAt, &rv.Username, &rv.ProductName); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, rv)
+	}
+	return reviews, rows.Err()
+}
+
+// loadReviewReplies 加载评价下的回复，并按 parent_id 组装成楼中楼结构
+func loadReviewReplies(reviews []models.Review) error {
+	if len(reviews) == 0 {
+		return nil
+	}
+	placeholders := strings.Trim(strings.Repeat("?,", len(reviews)), ",")
+	args := make([]interface{}, len(reviews))
+	for i, rv := range reviews {
+		args[i] = rv.ID
+	}
+	rows, err := database.DB.Query(fmt.Sprintf(`
+		SELECT rr.id, rr.review_id, COALESCE(rr.parent_id, 0), rr.user_id, rr.content, rr.created_at,
+			   u.username, rr.user_id = p.seller_id
+		FROM review_replies rr
+		JOIN users u ON rr.user_id = u.id
+		JOIN reviews r ON rr.review_id = r.id
+		JOIN products p ON r.product_id = p.id
+		WHERE rr.review_id IN (%s)
+		ORDER BY rr.created_at, rr.id
+	`, placeholders), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	// 顶层回复以评价ID为键，楼中楼回复以父回复ID为键
+	topLevel := make(map[int][]models.ReviewReply)
+	children := make(map[int][]models.ReviewReply)
+	for rows.Next() {
+		var reply models.ReviewReply
+		if err := rows.Scan(&reply.ID, &reply.ReviewID, &reply.ParentID, &reply.UserID, &reply.Content,
+			&reply.CreatedAt, &reply.Username, &reply.IsSeller); err != nil {
+			return err
+		}
+		if reply.ParentID == 0 {
+			topLevel[reply.ReviewID] = append(topLevel[reply.ReviewID], reply)
+		} else {
+			children[reply.ParentID] = append(children[reply.ParentID], reply)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	var attach func(replies []models.ReviewReply) []models.ReviewReply
+	attach = func(replies []models.ReviewReply) []models.ReviewReply {
+		for i := range replies {
+			replies[i].Replies = attach(children[replies[i].ID])
+		}
+		return replies
+	}
+	for i := range reviews {
+		reviews[i].Replies = attach(topLevel[reviews[i].ID])
+	}
+	return nil
+}
+
+// getRatingSummary 统计商品已审核评价的数量、平均分和各星级分布
+func getRatingSummary(productID int) (models.RatingSummary, error) {
+	summary := models.RatingSummary{Distribution: map[int]int{1: 0, 2: 0, 3: 0, 4: 0, 5: 0}}
+	rows, err := database.DB.Query(
+		"SELECT rating, COUNT(*) FROM reviews WHERE product_id = ? AND status = 'approved' GROUP BY rating",
+		productID,
+	)
+	if err != nil {
+		return summary, err
+	}
+	defer rows.Close()
+	sum := 0
+	for rows.Next() {
+		var rating, count int
+		if err := rows.Scan(&rating, &count); err != nil {
+			return summary, err
+		}
+		summary.Distribution[rating] = count
+		summary.Count += count
+		sum += rating * count
+	}
+	if summary.Count > 0 {
+		summary.Average = math.Round(float64(sum)/float64(summary.Count)*10) / 10
+	}
+	return summary, rows.Err()
+}
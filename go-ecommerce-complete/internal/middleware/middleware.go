@@ -5,37 +5,25 @@ import (
 	"net/http"
 )
 
-var Sessions = make(map[string]map[string]interface{})
-
 func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("session_id")
-		if err != nil {
+		if _, err := r.Cookie(SessionCookieName); err != nil {
 			http.Error(w, "未登录", http.StatusUnauthorized)
 			return
 		}
-		if _, ok := Sessions[cookie.Value]; !ok {
+		session := LookupSession(w, r)
+		if session == nil {
 			http.Error(w, "会话已过期", http.StatusUnauthorized)
 			return
 		}
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, withSession(r, session))
 	}
 }
 
 func RoleMiddleware(roles ...string) func(http.HandlerFunc) http.HandlerFunc {
 	return func(next http.HandlerFunc) http.HandlerFunc {
-		return func(w http.ResponseWriter, r *http.Request) {
-			cookie, err := r.Cookie("session_id")
-			if err != nil {
-				http.Error(w, "未登录", http.StatusUnauthorized)
-				return
-			}
-			session, ok := Sessions[cookie.Value]
-			if !ok {
-				http.Error(w, "会话已过期", http.StatusUnauthorized)
-				return
-			}
-			userRole := session["role"].(string)
+		return AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+			userRole := CurrentSession(r).Role
 			allowed := false
 			for _, role := range roles {
 				if userRole == role {
@@ -48,7 +36,7 @@ func RoleMiddleware(roles ...string) func(http.HandlerFunc) http.HandlerFunc {
 				return
 			}
 			next.ServeHTTP(w, r)
-		}
+		})
 	}
 }
 
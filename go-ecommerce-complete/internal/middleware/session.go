@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	SessionCookieName = "session_id"
+	SessionTTL        = 7 * 24 * time.Hour
+)
+
+type Session struct {
+	ID        string
+	UserID    int
+	Username  string
+	Role      string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// SessionStore 是并发安全的内存会话存储，会话在有效期内被访问时自动续期
+type SessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*Session
+	ttl      time.Duration
+}
+
+var Sessions = NewSessionStore(SessionTTL)
+
+func NewSessionStore(ttl time.Duration) *SessionStore {
+	return &SessionStore{
+		sessions: make(map[string]*Session),
+		ttl:      ttl,
+	}
+}
+
+// Create 为用户创建会话，会话ID为32字节的密码学随机数
+func (s *SessionStore) Create(userID int, username, role string) (*Session, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	session := &Session{
+		ID:        hex.EncodeToString(buf),
+		UserID:    userID,
+		Username:  username,
+		Role:      role,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+	s.mu.Lock()
+	s.sessions[session.ID] = session
+	s.mu.Unlock()
+	copied := *session
+	return &copied, nil
+}
+
+// Get 返回未过期的会话副本。剩余有效期不足一半时续期，renewed 表示本次是否续期
+func (s *SessionStore) Get(id string) (session *Session, renewed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.sessions[id]
+	if !ok {
+		return nil, false
+	}
+	now := time.Now()
+	if !now.Before(stored.ExpiresAt) {
+		delete(s.sessions, id)
+		return nil, false
+	}
+	if stored.ExpiresAt.Sub(now) < s.ttl/2 {
+		stored.ExpiresAt = now.Add(s.ttl)
+		renewed = true
+	}
+	copied := *stored
+	return &copied, renewed
+}
+
+func (s *SessionStore) Delete(id string) {
+	s.mu.Lock()
+	delete(s.sessions, id)
+	s.mu.Unlock()
+}
+
+// DeleteUser 删除用户的全部会话，用于修改密码、冻结账户等场景
+func (s *SessionStore) DeleteUser(userID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, session := range s.sessions {
+		if session.UserID == userID {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// Sweep 清理已过期的会话，返回清理数量
+func (s *SessionStore) Sweep() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	count := 0
+	for id, session := range s.sessions {
+		if !now.Before(session.ExpiresAt) {
+			delete(s.sessions, id)
+			count++
+		}
+	}
+	return count
+}
+
+// StartSweeper 定期清理过期会话，返回停止函数
+func (s *SessionStore) StartSweeper(interval time.Duration) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				s.Sweep()
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+func SetSessionCookie(w http.ResponseWriter, session *Session) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    session.ID,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		MaxAge:   int(time.Until(session.ExpiresAt).Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func ClearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:   SessionCookieName,
+		Value:  "",
+		Path:   "/",
+		MaxAge: -1,
+	})
+}
+
+// LookupSession 根据请求中的 Cookie 查找会话，续期时同步刷新 Cookie
+func LookupSession(w http.ResponseWriter, r *http.Request) *Session {
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil {
+		return nil
+	}
+	session, renewed := Sessions.Get(cookie.Value)
+	if session != nil && renewed {
+		SetSessionCookie(w, session)
+	}
+	return session
+}
+
+type sessionContextKey struct{}
+
+// CurrentSession 返回认证中间件放入请求上下文的会话，未经过认证中间件时返回 nil
+func CurrentSession(r *http.Request) *Session {
+	session, _ := r.Context().Value(sessionContextKey{}).(*Session)
+	return session
+}
+
+func withSession(r *http.Request, session *Session) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), sessionContextKey{}, session))
+}
@@ -81,33 +81,46 @@ type Order struct {
 }
 
 type OrderItem struct {
-	ID           int     `json:"id"`
-	OrderID      int     `json:"order_id"`
-	ProductID    int     `json:"product_id"`
-	ProductName  string  `json:"product_name"`
-	ProductPrice float64 `json:"product_price"`
-	Quantity     int     `json:"quantity"`
-	Subtotal     float64 `json:"subtotal"`
+	ID             int        `json:"id"`
+	OrderID        int        `json:"order_id"`
+	ProductID      int        `json:"product_id"`
+	ProductName    string     `json:"product_name"`
+	ProductPrice   float64    `json:"product_price"`
+	Quantity       int        `json:"quantity"`
+	Subtotal       float64    `json:"subtotal"`
+	TrackingNumber string     `json:"tracking_number"`
+	ShippedAt      *time.Time `json:"shipped_at"`
 }
 
 type Review struct {
+	ID          int           `json:"id"`
+	UserID      int           `json:"user_id"`
+	ProductID   int           `json:"product_id"`
+	OrderID     int           `json:"order_id"`
+	OrderItemID int           `json:"order_item_id"`
+	Rating      int           `json:"rating"`
+	Comment     string        `json:"comment"`
+	Status      string        `json:"status"`
+	CreatedAt   time.Time     `json:"created_at"`
+	Username    string        `json:"username,omitempty"`
+	ProductName string        `json:"product_name,omitempty"`
+	Replies     []ReviewReply `json:"replies,omitempty"`
+}
+
+type ReviewReply struct {
 	ID        int           `json:"id"`
+	ReviewID  int           `json:"review_id"`
+	ParentID  int           `json:"parent_id"`
 	UserID    int           `json:"user_id"`
-	ProductID int           `json:"product_id"`
-	OrderID   int           `json:"order_id"`
-	Rating    int           `json:"rating"`
-	Comment   string        `json:"comment"`
-	Status    string        `json:"status"`
+	Content   string        `json:"content"`
 	CreatedAt time.Time     `json:"created_at"`
 	Username  string        `json:"username,omitempty"`
+	IsSeller  bool          `json:"is_seller"`
 	Replies   []ReviewReply `json:"replies,omitempty"`
 }
 
-type ReviewReply struct {
-	ID        int       `json:"id"`
-	ReviewID  int       `json:"review_id"`
-	UserID    int       `json:"user_id"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
-	Username  string    `json:"username,omitempty"`
+type RatingSummary struct {
+	Count        int         `json:"count"`
+	Average      float64     `json:"average"`
+	Distribution map[int]int `json:"distribution"`
 }
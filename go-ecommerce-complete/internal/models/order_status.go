@@ -0,0 +1,42 @@
+package models
+
+const (
+	OrderPendingPayment   = "pending_payment"
+	OrderPendingShipment  = "pending_shipment"
+	OrderPartiallyShipped = "partially_shipped"
+	OrderShipped          = "shipped"
+	OrderCompleted        = "completed"
+	OrderCancelled        = "cancelled"
+)
+
+// orderTransitions 定义订单状态机中允许的状态流转，已完成和已取消为终态
+var orderTransitions = map[string][]string{
+	OrderPendingPayment:   {OrderPendingShipment, OrderCancelled},
+	OrderPendingShipment:  {OrderPartiallyShipped, OrderShipped, OrderCancelled},
+	OrderPartiallyShipped: {OrderPartiallyShipped, OrderShipped},
+	OrderShipped:          {OrderCompleted},
+}
+
+// CanTransition 判断订单能否从 from 状态流转到 to 状态
+func CanTransition(from, to string) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionSources 返回可以流转到 to 状态的所有状态，用于更新语句的状态条件
+func TransitionSources(to string) []string {
+	var sources []string
+	for from, nexts := range orderTransitions {
+		for _, next := range nexts {
+			if next == to {
+				sources = append(sources, from)
+				break
+			}
+		}
+	}
+	return sources
+}
@@ -0,0 +1,90 @@
+package password
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	MinLength = 8
+	// bcrypt 只使用密码的前72个字节
+	MaxLength = 72
+	Cost      = bcrypt.DefaultCost
+)
+
+// Hash 使用 bcrypt 生成带随机盐的密码哈希
+func Hash(plain string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(plain), Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// dummyHash 没有可校验的哈希时用于比较，使各种情况下的校验耗时一致，
+// 避免通过登录响应时间判断用户名是否存在
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), Cost)
+
+// Verify 校验密码。旧版本使用无盐 SHA-256 保存的哈希同样可以校验，
+// 此时 needsUpgrade 为 true，调用方应在登录成功后用 Hash 重新生成哈希。
+// hash 为空（用户不存在）时同样执行一次 bcrypt 比较并返回 false
+func Verify(hash, plain string) (ok, needsUpgrade bool) {
+	if hash == "" {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(plain))
+		return false, false
+	}
+	if isLegacyHash(hash) {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(plain))
+		legacy := fmt.Sprintf("%x", sha256.Sum256([]byte(plain)))
+		return subtle.ConstantTimeCompare([]byte(hash), []byte(legacy)) == 1, true
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(plain)) != nil {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return true, err != nil || cost < Cost
+}
+
+func isLegacyHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	for _, c := range hash {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate 检查密码强度：长度8到72字节，同时包含字母和数字，且不能与用户名相同
+func Validate(plain, username string) error {
+	if len(plain) < MinLength {
+		return fmt.Errorf("密码长度不能少于%d位", MinLength)
+	}
+	if len(plain) > MaxLength {
+		return fmt.Errorf("密码长度不能超过%d个字节", MaxLength)
+	}
+	var hasLetter, hasDigit bool
+	for _, c := range plain {
+		switch {
+		case unicode.IsLetter(c):
+			hasLetter = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return errors.New("密码必须同时包含字母和数字")
+	}
+	if strings.EqualFold(plain, username) {
+		return errors.New("密码不能与用户名相同")
+	}
+	return nil
+}
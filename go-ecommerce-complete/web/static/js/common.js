@@ -134,6 +134,7 @@ function formatDate(dateString) {
 const ORDER_STATUS = {
     'pending_payment': { text: '待支付', class: 'warning' },
     'pending_shipment': { text: '待发货', class: 'info' },
+    'partially_shipped': { text: '部分发货', class: 'info' },
     'shipped': { text: '已发货', class: 'info' },
     'completed': { text: '已完成', class: 'success' },
     'cancelled': { text: '已取消', class: 'secondary' }